@@ -0,0 +1,56 @@
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+// Package ahmbin stores parsed documents in a compact binary form, which loads without parsing.
+//
+// An encoded document starts with the magic bytes "AHMB" and a format version.
+// All the strings in it, from proc names to source names, are kept once in a table that follows.
+// The nodes come after that, each one prefixed with its length,
+// so that a subtree can be found and decoded without decoding what comes before it.
+//
+// Integers are unsigned varints, as written by encoding/binary.
+// The layout of the nodes is:
+//
+//	node      = length body
+//	body      = kind span (proc | text | paragraph)
+//	span      = string position position
+//	position  = line column offset
+//	proc      = string string count arg* count attr* count node*
+//	arg       = string quoted span
+//	attr      = string string quoted span
+//	text      = string
+//	paragraph = count node*
+//
+// Here a string is an index into the table and quoted is a single byte, 0 or 1.
+package ahmbin
+
+import "fmt"
+
+// Version is the version of the format written by Encode.
+// Documents in other versions are not decoded.
+const Version = 1
+
+const magic = "AHMB"
+
+// A Kind is the type of an encoded node.
+type Kind byte
+
+const (
+	Proc Kind = iota + 1
+	Text
+	Paragraph
+)
+
+func (kind Kind) String() string {
+	switch kind {
+	case Proc:
+		return "proc"
+	case Text:
+		return "text"
+	case Paragraph:
+		return "paragraph"
+	default:
+		return fmt.Sprintf("Kind(%d)", byte(kind))
+	}
+}
@@ -0,0 +1,187 @@
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package ahmbin_test
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/pkg/errors"
+	"github.com/szabba/ahm"
+	"github.com/szabba/ahm/ahmbin"
+	. "github.com/szabba/ahm/ahmtest"
+	"github.com/szabba/ahm/assert"
+)
+
+func TestExamplesDecodeIntoTheSameTreesWithTheSameSpans(t *testing.T) {
+	paths, _ := filepath.Glob(filepath.Join("..", "examples", "*.ahm"))
+	for _, path := range paths {
+		t.Run(path, func(t *testing.T) {
+			// given
+			src, err := os.ReadFile(path)
+			assert.That(err == nil, t.Fatalf, "cannot read example: %s", err)
+			nodes, err := ahm.Config{Source: path, StructuredArgs: true}.NewBytesParser(src).ParseAll()
+			assert.That(err == nil, t.Fatalf, "cannot parse example: %s", err)
+
+			data, err := ahmbin.Marshal(nodes)
+			assert.That(err == nil, t.Fatalf, "cannot encode: %s", err)
+
+			// when
+			decoded, err := ahmbin.Unmarshal(data)
+
+			// then
+			assert.That(err == nil, t.Fatalf, "unexpected error: %s", err)
+			assert.That(reflect.DeepEqual(decoded, nodes), t.Errorf, "the decoded nodes differ")
+			ReportDiffs(t.Errorf, decoded, nodes)
+		})
+	}
+}
+
+func TestGeneratedDocumentsSurviveEncoding(t *testing.T) {
+	gen := NewGenerator(3)
+	for i := 0; i < 100; i++ {
+		// given
+		nodes := gen.Nodes()
+
+		var buf bytes.Buffer
+		err := ahmbin.Encode(&buf, nodes)
+		assert.That(err == nil, t.Fatalf, "cannot encode: %s", err)
+
+		// when
+		decoded, err := ahmbin.Unmarshal(buf.Bytes())
+
+		// then
+		assert.That(err == nil, t.Fatalf, "unexpected error: %s", err)
+		assert.That(reflect.DeepEqual(decoded, nodes), t.Fatalf, "the decoded nodes differ:\n%s", LineDiff(Render(decoded), Render(nodes)))
+	}
+}
+
+func TestStringsAreStoredOnce(t *testing.T) {
+	// given
+	var nodes []ahm.Node
+	for i := 0; i < 10; i++ {
+		nodes = append(nodes, P("A-LONG-PROC-NAME", "", T("text")))
+	}
+
+	// when
+	data, err := ahmbin.Marshal(nodes)
+
+	// then
+	assert.That(err == nil, t.Fatalf, "unexpected error: %s", err)
+	n := bytes.Count(data, []byte("A-LONG-PROC-NAME"))
+	assert.That(n == 1, t.Errorf, "the name is stored %d times", n)
+}
+
+func TestSubtreesCanBeFoundWithoutDecodingTheirSiblings(t *testing.T) {
+	// given
+	nodes := []ahm.Node{
+		P("PROLOGUE", "", T("long text")),
+		P("EPISODE", "",
+			P("CARD", "first"),
+			P("CARD", "second", T("text"))),
+	}
+	data, err := ahmbin.Marshal(nodes)
+	assert.That(err == nil, t.Fatalf, "cannot encode: %s", err)
+
+	doc, err := ahmbin.Decode(data)
+	assert.That(err == nil, t.Fatalf, "cannot decode: %s", err)
+
+	// when
+	episode := doc.Root(1)
+	cards, err := episode.Children()
+
+	// then
+	assert.That(err == nil, t.Fatalf, "unexpected error: %s", err)
+	assert.That(doc.Len() == 2, t.Errorf, "got %d roots, wanted 2", doc.Len())
+	assert.That(episode.Kind() == ahmbin.Proc && episode.Name() == "EPISODE", t.Errorf, "got %s %s, wanted the episode", episode.Kind(), episode.Name())
+	assert.That(len(cards) == 2, t.Fatalf, "got %d cards, wanted 2", len(cards))
+	second, err := cards[1].Node()
+	assert.That(err == nil, t.Fatalf, "unexpected error: %s", err)
+	ReportNodeDiffs(t.Errorf, second, P("CARD", "second", T("text")))
+}
+
+func TestOtherVersionsAreRejected(t *testing.T) {
+	// given
+	data := []byte("AHMB\x02\x00\x00")
+
+	// when
+	_, err := ahmbin.Decode(data)
+
+	// then
+	assert.That(err != nil && strings.Contains(err.Error(), "version 2"), t.Errorf, "got error %v, wanted one about the version", err)
+}
+
+func TestDamagedDataIsAnError(t *testing.T) {
+	// given
+	nodes, err := ahm.NewParser(strings.NewReader("@A title\n  @B{x} text\n\nsome @EM{text}")).ParseAll()
+	assert.That(err == nil, t.Fatalf, "cannot parse: %s", err)
+	data, err := ahmbin.Marshal(nodes)
+	assert.That(err == nil, t.Fatalf, "cannot encode: %s", err)
+
+	for i := 0; i < len(data); i++ {
+		// when
+		_, err := ahmbin.Unmarshal(data[:i])
+
+		// then
+		assert.That(errors.Cause(err) == ahmbin.ErrCorrupt, t.Fatalf, "the first %d bytes: got error %v, wanted %v", i, err, ahmbin.ErrCorrupt)
+	}
+}
+
+// FuzzUnmarshal checks that no data makes decoding panic.
+func FuzzUnmarshal(f *testing.F) {
+	gen := NewGenerator(5)
+	for i := 0; i < 10; i++ {
+		data, err := ahmbin.Marshal(gen.Nodes())
+		if err != nil {
+			f.Fatal(err)
+		}
+		f.Add(data)
+	}
+	f.Fuzz(func(t *testing.T, data []byte) {
+		doc, err := ahmbin.Decode(data)
+		if err != nil {
+			return
+		}
+		for i := 0; i < doc.Len(); i++ {
+			doc.Root(i).Name()
+			doc.Root(i).Children()
+		}
+		doc.Nodes()
+	})
+}
+
+func BenchmarkUnmarshal(b *testing.B) {
+	var src strings.Builder
+	gen := NewGenerator(1)
+	for src.Len() < 1<<20 {
+		doc, err := gen.Document()
+		if err != nil {
+			b.Fatal(err)
+		}
+		src.WriteString(doc)
+	}
+	nodes, err := ahm.NewParser(strings.NewReader(src.String())).ParseAll()
+	if err != nil {
+		b.Fatal(err)
+	}
+	data, err := ahmbin.Marshal(nodes)
+	if err != nil {
+		b.Fatal(err)
+	}
+
+	b.SetBytes(int64(src.Len()))
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		_, err := ahmbin.Unmarshal(data)
+		if err != nil {
+			b.Fatal(err)
+		}
+	}
+}
@@ -0,0 +1,334 @@
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package ahmbin
+
+import (
+	"encoding/binary"
+	"math"
+
+	"github.com/pkg/errors"
+	"github.com/szabba/ahm"
+	"github.com/szabba/ahm/position"
+)
+
+// ErrCorrupt is the cause of the errors for data that is not a well formed encoded document.
+var ErrCorrupt = errors.New("corrupt encoded document")
+
+// A Document is an encoded document, decoded as its parts are asked for.
+type Document struct {
+	data    []byte
+	strings []string
+	roots   []Item
+}
+
+// Decode reads the string table of a document and finds its top level nodes.
+// The nodes themselves are only decoded on demand.
+//
+// The document keeps using data, which must not be modified.
+func Decode(data []byte) (*Document, error) {
+	doc := &Document{data: data}
+	r := &reader{doc: doc, end: len(data)}
+
+	if len(data) < len(magic) || string(data[:len(magic)]) != magic {
+		return nil, errors.Wrap(ErrCorrupt, "missing magic bytes")
+	}
+	r.off = len(magic)
+	if version := r.uvarint(); r.err == nil && version != Version {
+		return nil, errors.Errorf("cannot decode version %d of the format, only %d", version, Version)
+	}
+
+	r.readStrings()
+	doc.roots = r.items()
+	if r.err == nil && r.off != r.end {
+		r.fail()
+	}
+	if r.err != nil {
+		return nil, r.err
+	}
+	return doc, nil
+}
+
+// Unmarshal decodes all the nodes of a document.
+func Unmarshal(data []byte) ([]ahm.Node, error) {
+	doc, err := Decode(data)
+	if err != nil {
+		return nil, err
+	}
+	return doc.Nodes()
+}
+
+// Len is the number of top level nodes.
+func (doc *Document) Len() int { return len(doc.roots) }
+
+// Root is the i-th top level node.
+func (doc *Document) Root(i int) Item { return doc.roots[i] }
+
+// Nodes decodes all the top level nodes.
+func (doc *Document) Nodes() ([]ahm.Node, error) {
+	nodes := make([]ahm.Node, len(doc.roots))
+	for i, item := range doc.roots {
+		node, err := item.Node()
+		if err != nil {
+			return nil, err
+		}
+		nodes[i] = node
+	}
+	return nodes, nil
+}
+
+// An Item is an encoded node.
+type Item struct {
+	doc        *Document
+	start, end int
+}
+
+func (item Item) reader() *reader {
+	return &reader{doc: item.doc, off: item.start, end: item.end}
+}
+
+func (item Item) Kind() Kind {
+	return Kind(item.doc.data[item.start])
+}
+
+// Name is the name of a proc, or "" for other kinds of nodes.
+func (item Item) Name() string {
+	if item.Kind() != Proc {
+		return ""
+	}
+	r := item.reader()
+	r.off++
+	r.span()
+	return r.string()
+}
+
+// Children finds the children of a proc or a paragraph, without decoding them.
+func (item Item) Children() ([]Item, error) {
+	r := item.reader()
+	switch Kind(r.byte()) {
+	case Proc:
+		r.span()
+		r.proc(&ahm.Proc{})
+	case Paragraph:
+		r.span()
+	default:
+		return nil, r.err
+	}
+	children := r.items()
+	return children, r.err
+}
+
+// Node decodes the node with its whole subtree.
+func (item Item) Node() (ahm.Node, error) {
+	r := item.reader()
+	node := r.node()
+	if r.err == nil && r.off != r.end {
+		r.fail()
+	}
+	if r.err != nil {
+		return nil, r.err
+	}
+	return node, nil
+}
+
+// reader decodes the part of a document that ends at end.
+//
+// After the first error, it only returns zero values.
+type reader struct {
+	doc      *Document
+	off, end int
+	err      error
+}
+
+func (r *reader) fail() {
+	if r.err == nil {
+		r.err = errors.Wrapf(ErrCorrupt, "at offset %d", r.off)
+	}
+}
+
+func (r *reader) readStrings() {
+	n := r.count()
+	lengths := make([]int, n)
+	start, total := r.off, 0
+	for i := range lengths {
+		lengths[i] = r.uvarint()
+		r.bytes(lengths[i])
+		total += lengths[i]
+	}
+	if r.err != nil {
+		return
+	}
+
+	// The strings share the memory of a single copy of the table.
+	table := string(r.doc.data[start:r.off])
+	r.doc.strings = make([]string, n)
+	at := 0
+	for i, length := range lengths {
+		_, size := binary.Uvarint(r.doc.data[start+at:])
+		at += size
+		r.doc.strings[i] = table[at : at+length]
+		at += length
+	}
+}
+
+// items finds nodes, preceded by their count, and skips past them.
+func (r *reader) items() []Item {
+	n := r.count()
+	items := make([]Item, 0, n)
+	for i := 0; i < n && r.err == nil; i++ {
+		size := r.uvarint()
+		start := r.off
+		r.bytes(size)
+		if size == 0 {
+			r.fail()
+		}
+		items = append(items, Item{doc: r.doc, start: start, end: r.off})
+	}
+	if r.err != nil {
+		return nil
+	}
+	return items
+}
+
+func (r *reader) node() ahm.Node {
+	switch Kind(r.byte()) {
+	case Proc:
+		proc := &ahm.Proc{Span: r.span()}
+		r.proc(proc)
+		proc.Children = r.nodes()
+		return proc
+	case Text:
+		text := &ahm.Text{Span: r.span()}
+		text.Text = r.string()
+		return text
+	case Paragraph:
+		para := &ahm.Paragraph{Span: r.span()}
+		para.Children = r.nodes()
+		return para
+	default:
+		r.fail()
+		return nil
+	}
+}
+
+// proc reads the fields of a proc between its span and its children.
+func (r *reader) proc(proc *ahm.Proc) {
+	proc.Name = r.string()
+	proc.Title = r.string()
+	if n := r.count(); n > 0 {
+		proc.Args = make([]ahm.Arg, n)
+		for i := range proc.Args {
+			proc.Args[i] = ahm.Arg{Value: r.string(), Quoted: r.bool(), Span: r.span()}
+		}
+	}
+	if n := r.count(); n > 0 {
+		proc.Attrs = make([]ahm.Attr, n)
+		for i := range proc.Attrs {
+			proc.Attrs[i] = ahm.Attr{Key: r.string(), Value: r.string(), Quoted: r.bool(), Span: r.span()}
+		}
+	}
+}
+
+func (r *reader) nodes() []ahm.Node {
+	items := r.items()
+	if len(items) == 0 {
+		return nil
+	}
+	nodes := make([]ahm.Node, len(items))
+	for i, item := range items {
+		sub := item.reader()
+		nodes[i] = sub.node()
+		if sub.err == nil && sub.off != sub.end {
+			sub.fail()
+		}
+		if sub.err != nil {
+			r.err = sub.err
+			return nil
+		}
+	}
+	return nodes
+}
+
+func (r *reader) span() ahm.Span {
+	source := r.string()
+	start, end := r.position(), r.position()
+	span, ok := position.SpanBetween(start, end)
+	if !ok {
+		r.fail()
+	}
+	return span.In(source)
+}
+
+func (r *reader) position() position.Position {
+	line, column, offset := r.uvarint(), r.uvarint(), r.uvarint()
+	if line == 0 || column == 0 {
+		r.fail()
+	}
+	return position.PositionAt(line, column, offset)
+}
+
+func (r *reader) string() string {
+	i := r.uvarint()
+	if i >= len(r.doc.strings) {
+		r.fail()
+		return ""
+	}
+	return r.doc.strings[i]
+}
+
+func (r *reader) bool() bool {
+	b := r.byte()
+	if b > 1 {
+		r.fail()
+	}
+	return b == 1
+}
+
+// count reads the number of elements that follow, each of which takes at least a byte.
+func (r *reader) count() int {
+	n := r.uvarint()
+	if n > r.end-r.off {
+		r.fail()
+		return 0
+	}
+	return n
+}
+
+func (r *reader) uvarint() int {
+	if r.err != nil {
+		return 0
+	}
+	v, size := binary.Uvarint(r.doc.data[r.off:r.end])
+	if size <= 0 || v > math.MaxInt32 {
+		r.fail()
+		return 0
+	}
+	r.off += size
+	return int(v)
+}
+
+func (r *reader) byte() byte {
+	if r.err != nil {
+		return 0
+	}
+	if r.off >= r.end {
+		r.fail()
+		return 0
+	}
+	b := r.doc.data[r.off]
+	r.off++
+	return b
+}
+
+// bytes skips n bytes.
+func (r *reader) bytes(n int) {
+	if r.err != nil {
+		return
+	}
+	if n > r.end-r.off {
+		r.fail()
+		return
+	}
+	r.off += n
+}
@@ -0,0 +1,152 @@
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package ahmbin
+
+import (
+	"bytes"
+	"encoding/binary"
+	"io"
+
+	"github.com/pkg/errors"
+	"github.com/szabba/ahm"
+	"github.com/szabba/ahm/position"
+)
+
+// Encode writes nodes to w in the binary form.
+func Encode(w io.Writer, nodes []ahm.Node) error {
+	data, err := Marshal(nodes)
+	if err != nil {
+		return err
+	}
+	_, err = w.Write(data)
+	return err
+}
+
+// Marshal encodes nodes in the binary form.
+func Marshal(nodes []ahm.Node) ([]byte, error) {
+	enc := &encoder{indices: map[string]int{}}
+
+	var body bytes.Buffer
+	enc.uvarint(&body, len(nodes))
+	for _, node := range nodes {
+		err := enc.node(&body, node)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	var out bytes.Buffer
+	out.WriteString(magic)
+	enc.uvarint(&out, Version)
+	enc.uvarint(&out, len(enc.strings))
+	for _, s := range enc.strings {
+		enc.uvarint(&out, len(s))
+		out.WriteString(s)
+	}
+	out.Write(body.Bytes())
+	return out.Bytes(), nil
+}
+
+type encoder struct {
+	strings []string
+	indices map[string]int
+	scratch [binary.MaxVarintLen64]byte
+}
+
+// node writes a node prefixed with its length.
+func (enc *encoder) node(out *bytes.Buffer, node ahm.Node) error {
+	var body bytes.Buffer
+	switch node := node.(type) {
+	case *ahm.Proc:
+		body.WriteByte(byte(Proc))
+		enc.span(&body, node.Span)
+		enc.string(&body, node.Name)
+		enc.string(&body, node.Title)
+		enc.uvarint(&body, len(node.Args))
+		for _, arg := range node.Args {
+			enc.string(&body, arg.Value)
+			enc.bool(&body, arg.Quoted)
+			enc.span(&body, arg.Span)
+		}
+		enc.uvarint(&body, len(node.Attrs))
+		for _, attr := range node.Attrs {
+			enc.string(&body, attr.Key)
+			enc.string(&body, attr.Value)
+			enc.bool(&body, attr.Quoted)
+			enc.span(&body, attr.Span)
+		}
+		err := enc.children(&body, node.Children)
+		if err != nil {
+			return err
+		}
+
+	case *ahm.Text:
+		body.WriteByte(byte(Text))
+		enc.span(&body, node.Span)
+		enc.string(&body, node.Text)
+
+	case *ahm.Paragraph:
+		body.WriteByte(byte(Paragraph))
+		enc.span(&body, node.Span)
+		err := enc.children(&body, node.Children)
+		if err != nil {
+			return err
+		}
+
+	default:
+		return errors.Errorf("cannot encode node %#v", node)
+	}
+
+	enc.uvarint(out, body.Len())
+	out.Write(body.Bytes())
+	return nil
+}
+
+func (enc *encoder) children(out *bytes.Buffer, children []ahm.Node) error {
+	enc.uvarint(out, len(children))
+	for _, child := range children {
+		err := enc.node(out, child)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (enc *encoder) span(out *bytes.Buffer, span ahm.Span) {
+	enc.string(out, span.Source)
+	enc.position(out, span.StartsAt())
+	enc.position(out, span.EndsBefore())
+}
+
+func (enc *encoder) position(out *bytes.Buffer, pos position.Position) {
+	enc.uvarint(out, pos.Line())
+	enc.uvarint(out, pos.Column())
+	enc.uvarint(out, pos.Offset())
+}
+
+// string writes the index of a string in the table, adding it there if need be.
+func (enc *encoder) string(out *bytes.Buffer, s string) {
+	i, ok := enc.indices[s]
+	if !ok {
+		i = len(enc.strings)
+		enc.indices[s] = i
+		enc.strings = append(enc.strings, s)
+	}
+	enc.uvarint(out, i)
+}
+
+func (enc *encoder) bool(out *bytes.Buffer, b bool) {
+	if b {
+		out.WriteByte(1)
+	} else {
+		out.WriteByte(0)
+	}
+}
+
+func (enc *encoder) uvarint(out *bytes.Buffer, n int) {
+	size := binary.PutUvarint(enc.scratch[:], uint64(n))
+	out.Write(enc.scratch[:size])
+}
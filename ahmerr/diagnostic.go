@@ -0,0 +1,93 @@
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package ahmerr
+
+import (
+	"bytes"
+	"fmt"
+
+	"github.com/szabba/ahm/position"
+)
+
+type Severity int
+
+const (
+	Error Severity = iota
+	Warning
+	Info
+)
+
+func (sev Severity) String() string {
+	switch sev {
+	case Error:
+		return "error"
+	case Warning:
+		return "warning"
+	case Info:
+		return "info"
+	default:
+		return fmt.Sprintf("Severity(%d)", int(sev))
+	}
+}
+
+// A Diagnostic is a problem found at a particular place in a document.
+type Diagnostic struct {
+	Severity Severity
+	Span     position.SpanIn
+	Message  string
+	// IncludedFrom lists the places the source of the span was included from, innermost first.
+	IncludedFrom []position.SpanIn
+}
+
+func Diagnosef(span position.SpanIn, msgFmt string, args ...interface{}) *Diagnostic {
+	return &Diagnostic{Span: span, Message: fmt.Sprintf(msgFmt, args...)}
+}
+
+func Warnf(span position.SpanIn, msgFmt string, args ...interface{}) *Diagnostic {
+	diag := Diagnosef(span, msgFmt, args...)
+	diag.Severity = Warning
+	return diag
+}
+
+func (diag *Diagnostic) Error() string {
+	var buf bytes.Buffer
+	fmt.Fprintf(&buf, "%s: %s: %s", diag.Span, diag.Severity, diag.Message)
+	for _, from := range diag.IncludedFrom {
+		fmt.Fprintf(&buf, "\n\tincluded from %s", from)
+	}
+	return buf.String()
+}
+
+// Diagnostics is an error made up of any number of diagnostics.
+type Diagnostics []*Diagnostic
+
+// Err returns nil when there are no diagnostics.
+func (diags Diagnostics) Err() error {
+	if len(diags) == 0 {
+		return nil
+	}
+	return diags
+}
+
+// HasErrors is true when at least one of the diagnostics has the Error severity.
+func (diags Diagnostics) HasErrors() bool {
+	for _, diag := range diags {
+		if diag.Severity == Error {
+			return true
+		}
+	}
+	return false
+}
+
+func (diags Diagnostics) Error() string {
+	var buf bytes.Buffer
+	for i, diag := range diags {
+		if i > 0 {
+			buf.WriteRune('\n')
+		}
+		buf.WriteString(diag.Error())
+	}
+	return buf.String()
+}
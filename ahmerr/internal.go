@@ -0,0 +1,27 @@
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package ahmerr
+
+import (
+	"fmt"
+
+	"github.com/szabba/ahm/position"
+)
+
+// An InternalError reports a broken invariant of the parser, found where it was parsing.
+//
+// It is a bug for any input to cause one.
+type InternalError struct {
+	Span    position.SpanIn
+	Message string
+}
+
+func Internalf(span position.SpanIn, msgFmt string, args ...interface{}) *InternalError {
+	return &InternalError{Span: span, Message: fmt.Sprintf(msgFmt, args...)}
+}
+
+func (err *InternalError) Error() string {
+	return fmt.Sprintf("%s: internal error: %s", err.Span, err.Message)
+}
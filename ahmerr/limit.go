@@ -0,0 +1,55 @@
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package ahmerr
+
+import (
+	"fmt"
+
+	"github.com/pkg/errors"
+	"github.com/szabba/ahm/position"
+)
+
+// A Limit is a resource the parser can be told not to use too much of.
+type Limit int
+
+const (
+	NestingDepth Limit = iota
+	LineLength
+	TokenCount
+	DocumentSize
+)
+
+func (limit Limit) String() string {
+	switch limit {
+	case NestingDepth:
+		return "nesting depth"
+	case LineLength:
+		return "line length"
+	case TokenCount:
+		return "token count"
+	case DocumentSize:
+		return "document size"
+	default:
+		return fmt.Sprintf("Limit(%d)", int(limit))
+	}
+}
+
+// A LimitError stops parsing input that would use more of a resource than allowed.
+type LimitError struct {
+	Limit Limit
+	Max   int
+	// Span is where the input went over the limit.
+	Span position.SpanIn
+}
+
+func (err *LimitError) Error() string {
+	return fmt.Sprintf("%s: %s exceeds the limit of %d", err.Span, err.Limit, err.Max)
+}
+
+// AsLimit finds the LimitError behind an error, if there is one.
+func AsLimit(err error) (*LimitError, bool) {
+	limit, ok := errors.Cause(err).(*LimitError)
+	return limit, ok
+}
@@ -0,0 +1,125 @@
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+// Package ahmgen compiles documents into Go source, so that programs can carry their content without parsing it.
+//
+// By default a document becomes a variable holding its nodes as ahm.Proc, ahm.Text and ahm.Paragraph literals.
+// Given a Schema, procs become values of Go structs generated from the schema instead.
+package ahmgen
+
+import (
+	"bytes"
+	"fmt"
+	"go/format"
+	"io"
+	"strconv"
+
+	"github.com/pkg/errors"
+	"github.com/szabba/ahm"
+)
+
+// A configuration of the generated code.
+type Config struct {
+	PackageName string
+	// VarName is the name of the variable holding the document.
+	VarName string
+	// Source names the document the code is generated from, in the header comment.
+	Source string
+	// Schema describes the types to generate, when it is not nil.
+	Schema Schema
+}
+
+// Generate writes a Go file holding the nodes.
+//
+// Spans are not kept.
+func (cfg Config) Generate(w io.Writer, nodes []ahm.Node) error {
+	var buf bytes.Buffer
+	fmt.Fprintf(&buf, "// Code generated by ahmgen from %s. DO NOT EDIT.\n\n", cfg.Source)
+	fmt.Fprintf(&buf, "package %s\n\n", cfg.PackageName)
+
+	var err error
+	if cfg.Schema == nil {
+		err = cfg.generateNodes(&buf, nodes)
+	} else {
+		err = cfg.Schema.generate(&buf, cfg.VarName, nodes)
+	}
+	if err != nil {
+		return err
+	}
+
+	src, err := format.Source(buf.Bytes())
+	if err != nil {
+		return errors.Wrap(err, "cannot format the generated code")
+	}
+	_, err = w.Write(src)
+	return err
+}
+
+func (cfg Config) generateNodes(buf *bytes.Buffer, nodes []ahm.Node) error {
+	fmt.Fprintf(buf, "import \"github.com/szabba/ahm\"\n\n")
+	fmt.Fprintf(buf, "var %s = ", cfg.VarName)
+	err := writeNodes(buf, nodes)
+	buf.WriteString("\n")
+	return err
+}
+
+func writeNodes(buf *bytes.Buffer, nodes []ahm.Node) error {
+	buf.WriteString("[]ahm.Node{\n")
+	for _, node := range nodes {
+		err := writeNode(buf, node)
+		if err != nil {
+			return err
+		}
+		buf.WriteString(",\n")
+	}
+	buf.WriteString("}")
+	return nil
+}
+
+func writeNode(buf *bytes.Buffer, node ahm.Node) error {
+	switch node := node.(type) {
+	case *ahm.Proc:
+		fmt.Fprintf(buf, "&ahm.Proc{Name: %s", strconv.Quote(node.Name))
+		if node.Title != "" {
+			fmt.Fprintf(buf, ", Title: %s", strconv.Quote(node.Title))
+		}
+		if len(node.Args) > 0 {
+			buf.WriteString(", Args: []ahm.Arg{")
+			for _, arg := range node.Args {
+				fmt.Fprintf(buf, "{Value: %s, Quoted: %t}, ", strconv.Quote(arg.Value), arg.Quoted)
+			}
+			buf.WriteString("}")
+		}
+		if len(node.Attrs) > 0 {
+			buf.WriteString(", Attrs: []ahm.Attr{")
+			for _, attr := range node.Attrs {
+				fmt.Fprintf(buf, "{Key: %s, Value: %s, Quoted: %t}, ", strconv.Quote(attr.Key), strconv.Quote(attr.Value), attr.Quoted)
+			}
+			buf.WriteString("}")
+		}
+		if len(node.Children) > 0 {
+			buf.WriteString(", Children: ")
+			err := writeNodes(buf, node.Children)
+			if err != nil {
+				return err
+			}
+		}
+		buf.WriteString("}")
+
+	case *ahm.Text:
+		fmt.Fprintf(buf, "&ahm.Text{Text: %s}", strconv.Quote(node.Text))
+
+	case *ahm.Paragraph:
+		buf.WriteString("&ahm.Paragraph{Children: ")
+		err := writeNodes(buf, node.Children)
+		if err != nil {
+			return err
+		}
+		buf.WriteString("}")
+
+	default:
+		return errors.Errorf("cannot generate code for node %#v", node)
+	}
+	return nil
+}
@@ -0,0 +1,116 @@
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package ahmgen_test
+
+import (
+	"bytes"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/szabba/ahm"
+	"github.com/szabba/ahm/ahmerr"
+	"github.com/szabba/ahm/ahmgen"
+	. "github.com/szabba/ahm/ahmtest"
+	"github.com/szabba/ahm/assert"
+)
+
+const doc = `@CARD start
+  Some @EM{text}.
+
+  More text.
+  @OPTION Go on
+@CARD end
+`
+
+func TestNodesAreGeneratedAsLiterals(t *testing.T) {
+	// given
+	nodes := Parse(t, ahm.Config{Source: "cards.ahm"}, doc)
+	cfg := ahmgen.Config{PackageName: "content", VarName: "Cards", Source: "cards.ahm"}
+
+	// when
+	var out bytes.Buffer
+	err := cfg.Generate(&out, nodes)
+
+	// then
+	assert.That(err == nil, t.Fatalf, "unexpected error: %s", err)
+	Golden(t, filepath.Join("testdata", "nodes.golden"), out.String())
+}
+
+func TestProcsAreGeneratedAsStructsFromASchema(t *testing.T) {
+	// given
+	nodes := Parse(t, ahm.Config{Source: "cards.ahm"}, doc)
+	schema, err := ahmgen.ReadSchema(strings.NewReader(`{
+		"CARD": {"Type": "Card", "Title": "Name", "Text": "Text", "Children": {"OPTION": "Options"}},
+		"OPTION": {"Type": "Option", "Title": "Label"}
+	}`))
+	assert.That(err == nil, t.Fatalf, "cannot read schema: %s", err)
+	cfg := ahmgen.Config{PackageName: "content", VarName: "Cards", Source: "cards.ahm", Schema: schema}
+
+	// when
+	var out bytes.Buffer
+	err = cfg.Generate(&out, nodes)
+
+	// then
+	assert.That(err == nil, t.Fatalf, "unexpected error: %s", err)
+	Golden(t, filepath.Join("testdata", "structs.golden"), out.String())
+}
+
+func TestProcsOutsideTheSchemaAreDiagnosed(t *testing.T) {
+	// given
+	nodes := Parse(t, ahm.Config{Source: "cards.ahm"}, doc)
+	schema := ahmgen.Schema{"CARD": {Name: "Card", Title: "Name", Text: "Text"}}
+	cfg := ahmgen.Config{PackageName: "content", VarName: "Cards", Schema: schema}
+
+	// when
+	err := cfg.Generate(new(bytes.Buffer), nodes)
+
+	// then
+	diag, ok := err.(*ahmerr.Diagnostic)
+	assert.That(ok, t.Fatalf, "got error %v, wanted a diagnostic", err)
+	assert.That(diag.Span.StartsAt().Line() == 5, t.Errorf, "got diagnostic %s, wanted it at the option", diag)
+}
+
+func TestTitlesWithoutAFieldAreDiagnosed(t *testing.T) {
+	// given
+	nodes := Parse(t, ahm.Config{Source: "cards.ahm"}, "@CARD start", "@CARD", "@CARD end")
+	schema := ahmgen.Schema{"CARD": {Name: "Card"}}
+	cfg := ahmgen.Config{PackageName: "content", VarName: "Cards", Schema: schema}
+
+	// when
+	err := cfg.Generate(new(bytes.Buffer), nodes)
+
+	// then
+	diag, ok := err.(*ahmerr.Diagnostic)
+	assert.That(ok, t.Fatalf, "got error %v, wanted a diagnostic", err)
+	assert.That(diag.Span.StartsAt().Line() == 1, t.Errorf, "got diagnostic %s, wanted it at the first card", diag)
+}
+
+func TestAttributesAreDiagnosed(t *testing.T) {
+	// given
+	nodes := Parse(t, ahm.Config{Source: "cards.ahm", StructuredArgs: true}, "@CARD start", "@CARD end final=yes")
+	schema := ahmgen.Schema{"CARD": {Name: "Card", Title: "Name"}}
+	cfg := ahmgen.Config{PackageName: "content", VarName: "Cards", Schema: schema}
+
+	// when
+	err := cfg.Generate(new(bytes.Buffer), nodes)
+
+	// then
+	diag, ok := err.(*ahmerr.Diagnostic)
+	assert.That(ok, t.Fatalf, "got error %v, wanted a diagnostic", err)
+	at := diag.Span.StartsAt()
+	assert.That(at.Line() == 2 && at.Column() == 11, t.Errorf, "got diagnostic %s, wanted it at the attribute", diag)
+}
+
+func TestSchemasNeedGoIdentifiers(t *testing.T) {
+	// given
+	src := `{"CARD": {"Type": "Card", "Title": "the name"}}`
+
+	// when
+	_, err := ahmgen.ReadSchema(strings.NewReader(src))
+
+	// then
+	assert.That(err != nil, t.Errorf, "expected an error")
+}
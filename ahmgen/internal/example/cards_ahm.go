@@ -0,0 +1,29 @@
+// Code generated by ahmgen from cards.ahm. DO NOT EDIT.
+
+package example
+
+import "github.com/szabba/ahm"
+
+var Cards = []ahm.Node{
+	&ahm.Proc{Name: "EPISODE", Title: "A New Hope", Children: []ahm.Node{
+		&ahm.Proc{Name: "CONDITIONS", Children: []ahm.Node{
+			&ahm.Proc{Name: "VALUE", Title: "Luke.yearning-to-leave 40"},
+			&ahm.Proc{Name: "VALUE", Title: "Force.disturbance 10"},
+		}},
+		&ahm.Proc{Name: "CARD", Title: "title-screen", Children: []ahm.Node{
+			&ahm.Text{Text: "A long time ago, in a Galaxy not so far from here.\n\n*Pompous music is playing.*"},
+			&ahm.Proc{Name: "CHOICE", Children: []ahm.Node{
+				&ahm.Proc{Name: "OPTION", Title: "\"Change the music theme to Star Trek!\"", Children: []ahm.Node{
+					&ahm.Proc{Name: "EFFECT", Children: []ahm.Node{
+						&ahm.Text{Text: "..."},
+					}},
+				}},
+				&ahm.Proc{Name: "OPTION", Title: "\"Change the music theme to Doctor Who!\"", Children: []ahm.Node{
+					&ahm.Proc{Name: "EFFECT", Children: []ahm.Node{
+						&ahm.Text{Text: "..."},
+					}},
+				}},
+			}},
+		}},
+	}},
+}
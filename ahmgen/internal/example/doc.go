@@ -0,0 +1,9 @@
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+// Package example holds code generated from examples/cards.ahm, which its tests check against the document.
+package example
+
+//go:generate go run ../../../cmd/ahmgen ../../../examples/cards.ahm
+//go:generate go run ../../../cmd/ahmgen -schema episodes.json -var Episodes -out episodes_ahm.go ../../../examples/cards.ahm
@@ -0,0 +1,74 @@
+// Code generated by ahmgen from cards.ahm. DO NOT EDIT.
+
+package example
+
+type Card struct {
+	Name    string
+	Text    string
+	Choices []Choice
+}
+
+type Choice struct {
+	Options []Option
+}
+
+type Conditions struct {
+	Values []Value
+}
+
+type Effect struct {
+	Script string
+}
+
+type Episode struct {
+	Name       string
+	Cards      []Card
+	Conditions []Conditions
+}
+
+type Option struct {
+	Label   string
+	Effects []Effect
+}
+
+type Value struct {
+	Setting string
+}
+
+var Episodes = []Episode{
+	{
+		Name: "A New Hope",
+		Cards: []Card{
+			{
+				Name: "title-screen",
+				Text: "A long time ago, in a Galaxy not so far from here.\n\n*Pompous music is playing.*",
+				Choices: []Choice{
+					{
+						Options: []Option{
+							{
+								Label: "\"Change the music theme to Star Trek!\"",
+								Effects: []Effect{
+									{Script: "..."},
+								},
+							},
+							{
+								Label: "\"Change the music theme to Doctor Who!\"",
+								Effects: []Effect{
+									{Script: "..."},
+								},
+							},
+						},
+					},
+				},
+			},
+		},
+		Conditions: []Conditions{
+			{
+				Values: []Value{
+					{Setting: "Luke.yearning-to-leave 40"},
+					{Setting: "Force.disturbance 10"},
+				},
+			},
+		},
+	},
+}
@@ -0,0 +1,43 @@
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package example
+
+import (
+	"os"
+	"testing"
+
+	"github.com/szabba/ahm"
+	"github.com/szabba/ahm/ahmtest"
+	"github.com/szabba/ahm/assert"
+)
+
+func TestGeneratedNodesAreTheParsedDocument(t *testing.T) {
+	// given
+	src, err := os.ReadFile("../../../examples/cards.ahm")
+	assert.That(err == nil, t.Fatalf, "cannot read example: %s", err)
+
+	// when
+	nodes, err := ahm.NewBytesParser(src).ParseAll()
+
+	// then
+	assert.That(err == nil, t.Fatalf, "cannot parse example: %s", err)
+	ahmtest.ReportDiffs(t.Errorf, Cards, nodes)
+}
+
+func TestGeneratedStructsFollowTheSchema(t *testing.T) {
+	// given
+	assert.That(len(Episodes) == 1, t.Fatalf, "got %d episodes, wanted 1", len(Episodes))
+	episode := Episodes[0]
+
+	// when
+	card := episode.Cards[0]
+
+	// then
+	assert.That(episode.Name == "A New Hope", t.Errorf, "got episode %q", episode.Name)
+	assert.That(len(episode.Conditions[0].Values) == 2, t.Errorf, "got values %v", episode.Conditions)
+	assert.That(card.Name == "title-screen", t.Errorf, "got card %q", card.Name)
+	assert.That(len(card.Choices[0].Options) == 2, t.Errorf, "got choices %v", card.Choices)
+	assert.That(card.Choices[0].Options[1].Effects[0].Script == "...", t.Errorf, "got options %v", card.Choices[0].Options)
+}
@@ -0,0 +1,240 @@
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package ahmgen
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"go/token"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/pkg/errors"
+	"github.com/szabba/ahm"
+	"github.com/szabba/ahm/ahmerr"
+)
+
+// A Schema maps proc names to the Go types generated for them.
+//
+// For example, with
+//
+//	{
+//	  "CARD":   {"Type": "Card", "Title": "Name", "Text": "Text", "Children": {"OPTION": "Options"}},
+//	  "OPTION": {"Type": "Option", "Title": "Label"}
+//	}
+//
+// each @CARD becomes a Card, with its title in the Name field, its text in Text and its @OPTIONs in Options.
+// Documents that do not fit the schema are errors.
+type Schema map[string]Type
+
+// A Type is a Go struct a proc becomes.
+type Type struct {
+	// Name is the name of the struct.
+	Name string `json:"Type"`
+	// Title is the string field holding the proc title, if any.
+	// Positional arguments are kept as part of the title, while attributes have no field to go in.
+	Title string `json:",omitempty"`
+	// Text is the string field holding the text of the proc, if any.
+	// Separate pieces of text are joined with blank lines.
+	Text string `json:",omitempty"`
+	// Children maps the names of child procs to the slice fields holding them.
+	Children map[string]string `json:",omitempty"`
+}
+
+// ReadSchema reads a schema in JSON.
+func ReadSchema(r io.Reader) (Schema, error) {
+	var schema Schema
+	err := json.NewDecoder(r).Decode(&schema)
+	if err != nil {
+		return nil, errors.Wrap(err, "cannot read schema")
+	}
+	return schema, schema.check()
+}
+
+func (schema Schema) check() error {
+	procs := map[string]string{}
+	for _, proc := range schema.procs() {
+		typ := schema[proc]
+		if other, ok := procs[typ.Name]; ok {
+			return errors.Errorf("both %s and %s use the type %s", other, proc, typ.Name)
+		}
+		procs[typ.Name] = proc
+
+		if !token.IsIdentifier(typ.Name) {
+			return errors.Errorf("%s: %q is not a Go identifier", proc, typ.Name)
+		}
+		used := map[string]bool{}
+		for _, field := range append([]string{typ.Title, typ.Text}, typ.fields()...) {
+			if field != "" && !token.IsIdentifier(field) {
+				return errors.Errorf("%s: %q is not a Go identifier", proc, field)
+			}
+			if field != "" && used[field] {
+				return errors.Errorf("%s: the field %s is used twice", proc, field)
+			}
+			used[field] = true
+		}
+		for _, child := range typ.childProcs() {
+			if _, ok := schema[child]; !ok {
+				return errors.Errorf("%s: the child %s has no type", proc, child)
+			}
+		}
+	}
+	return nil
+}
+
+// procs lists the procs of the schema in order.
+func (schema Schema) procs() []string {
+	procs := make([]string, 0, len(schema))
+	for proc := range schema {
+		procs = append(procs, proc)
+	}
+	sort.Strings(procs)
+	return procs
+}
+
+// childProcs lists the child procs in the order of their fields.
+func (typ Type) childProcs() []string {
+	procs := make([]string, 0, len(typ.Children))
+	for proc := range typ.Children {
+		procs = append(procs, proc)
+	}
+	sort.Slice(procs, func(i, j int) bool { return typ.Children[procs[i]] < typ.Children[procs[j]] })
+	return procs
+}
+
+func (typ Type) fields() []string {
+	var fields []string
+	for _, proc := range typ.childProcs() {
+		fields = append(fields, typ.Children[proc])
+	}
+	return fields
+}
+
+func (schema Schema) generate(buf *bytes.Buffer, varName string, nodes []ahm.Node) error {
+	err := schema.check()
+	if err != nil {
+		return err
+	}
+
+	for _, proc := range schema.procs() {
+		schema.writeType(buf, schema[proc])
+	}
+
+	var root string
+	for _, node := range nodes {
+		proc, ok := node.(*ahm.Proc)
+		if !ok {
+			return ahmerr.Diagnosef(ahm.SpanOf(node), "only procs can be at the top level")
+		}
+		if root == "" {
+			root = proc.Name
+		}
+		if proc.Name != root {
+			return ahmerr.Diagnosef(proc.Span, "@%s cannot be at the top level, after @%s", proc.Name, root)
+		}
+	}
+	if root == "" {
+		return errors.New("the document is empty")
+	}
+	typ, ok := schema[root]
+	if !ok {
+		return errors.Errorf("@%s has no type", root)
+	}
+
+	fmt.Fprintf(buf, "var %s = []%s{\n", varName, typ.Name)
+	for _, node := range nodes {
+		err := schema.writeValue(buf, node.(*ahm.Proc))
+		if err != nil {
+			return err
+		}
+		buf.WriteString(",\n")
+	}
+	buf.WriteString("}\n")
+	return nil
+}
+
+func (schema Schema) writeType(buf *bytes.Buffer, typ Type) {
+	fmt.Fprintf(buf, "type %s struct {\n", typ.Name)
+	if typ.Title != "" {
+		fmt.Fprintf(buf, "%s string\n", typ.Title)
+	}
+	if typ.Text != "" {
+		fmt.Fprintf(buf, "%s string\n", typ.Text)
+	}
+	for _, child := range typ.childProcs() {
+		fmt.Fprintf(buf, "%s []%s\n", typ.Children[child], schema[child].Name)
+	}
+	buf.WriteString("}\n\n")
+}
+
+// writeValue writes a proc as an element of a slice, with its type left out.
+func (schema Schema) writeValue(buf *bytes.Buffer, proc *ahm.Proc) error {
+	typ := schema[proc.Name]
+	if typ.Title == "" && proc.Title != "" {
+		return ahmerr.Diagnosef(proc.Span, "@%s cannot have a title", proc.Name)
+	}
+	if len(proc.Attrs) > 0 {
+		return ahmerr.Diagnosef(proc.Attrs[0].Span, "@%s cannot have attributes", proc.Name)
+	}
+
+	var fields []string
+	if proc.Title != "" {
+		fields = append(fields, fmt.Sprintf("%s: %s", typ.Title, strconv.Quote(proc.Title)))
+	}
+
+	var texts []string
+	children := map[string][]*ahm.Proc{}
+	for _, child := range proc.Children {
+		switch child := child.(type) {
+		case *ahm.Proc:
+			if _, ok := typ.Children[child.Name]; !ok {
+				return ahmerr.Diagnosef(child.Span, "@%s cannot be in @%s", child.Name, proc.Name)
+			}
+			children[child.Name] = append(children[child.Name], child)
+
+		default:
+			if typ.Text == "" {
+				return ahmerr.Diagnosef(ahm.SpanOf(child), "@%s cannot have text", proc.Name)
+			}
+			text, err := ahm.FormatString([]ahm.Node{child})
+			if err != nil {
+				return err
+			}
+			texts = append(texts, strings.TrimSuffix(text, "\n"))
+		}
+	}
+	if len(texts) > 0 {
+		fields = append(fields, fmt.Sprintf("%s: %s", typ.Text, strconv.Quote(strings.Join(texts, "\n\n"))))
+	}
+
+	if len(children) == 0 {
+		fmt.Fprintf(buf, "{%s}", strings.Join(fields, ", "))
+		return nil
+	}
+
+	buf.WriteString("{\n")
+	for _, field := range fields {
+		fmt.Fprintf(buf, "%s,\n", field)
+	}
+	for _, name := range typ.childProcs() {
+		if len(children[name]) == 0 {
+			continue
+		}
+		fmt.Fprintf(buf, "%s: []%s{\n", typ.Children[name], schema[name].Name)
+		for _, child := range children[name] {
+			err := schema.writeValue(buf, child)
+			if err != nil {
+				return err
+			}
+			buf.WriteString(",\n")
+		}
+		buf.WriteString("},\n")
+	}
+	buf.WriteString("}")
+	return nil
+}
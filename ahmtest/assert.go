@@ -0,0 +1,91 @@
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package ahmtest
+
+import (
+	"strings"
+
+	"github.com/szabba/ahm"
+)
+
+// ReportDiffs reports how the trees differ, ignoring spans.
+//
+// The report is a line diff of the rendered trees, passed to onErr, which is usually t.Errorf or t.Fatalf.
+// It returns whether the trees are equal.
+func ReportDiffs(onErr func(string, ...interface{}), got, want []ahm.Node) bool {
+	equal := len(got) == len(want)
+	for i := 0; equal && i < len(got); i++ {
+		equal = ahm.Equals(got[i], want[i])
+	}
+	if !equal {
+		onErr("the trees differ (-got +wanted):\n%s", LineDiff(Render(got), Render(want)))
+	}
+	return equal
+}
+
+// ReportNodeDiffs is ReportDiffs for a single node.
+func ReportNodeDiffs(onErr func(string, ...interface{}), got, want ahm.Node) bool {
+	return ReportDiffs(onErr, []ahm.Node{got}, []ahm.Node{want})
+}
+
+// ReportExactDiffs is ReportDiffs for trees that must have equal spans as well.
+//
+// The trees are compared by their renderings with spans.
+func ReportExactDiffs(onErr func(string, ...interface{}), got, want []ahm.Node) bool {
+	gotText, wantText := RenderWithSpans(got), RenderWithSpans(want)
+	equal := gotText == wantText
+	if !equal {
+		onErr("the trees differ (-got +wanted):\n%s", LineDiff(gotText, wantText))
+	}
+	return equal
+}
+
+// LineDiff shows how to turn one text into the other, line by line.
+//
+// Removed lines start with a minus, added ones with a plus, and the ones they share with a space.
+func LineDiff(old, updated string) string {
+	a, b := splitLines(old), splitLines(updated)
+
+	// common[i][j] is the length of the longest common subsequence of a[i:] and b[j:].
+	common := make([][]int, len(a)+1)
+	for i := range common {
+		common[i] = make([]int, len(b)+1)
+	}
+	for i := len(a) - 1; i >= 0; i-- {
+		for j := len(b) - 1; j >= 0; j-- {
+			if a[i] == b[j] {
+				common[i][j] = common[i+1][j+1] + 1
+			} else if common[i+1][j] >= common[i][j+1] {
+				common[i][j] = common[i+1][j]
+			} else {
+				common[i][j] = common[i][j+1]
+			}
+		}
+	}
+
+	var out strings.Builder
+	i, j := 0, 0
+	for i < len(a) || j < len(b) {
+		switch {
+		case i < len(a) && j < len(b) && a[i] == b[j]:
+			out.WriteString("  " + a[i] + "\n")
+			i, j = i+1, j+1
+		case j == len(b) || (i < len(a) && common[i+1][j] >= common[i][j+1]):
+			out.WriteString("- " + a[i] + "\n")
+			i++
+		default:
+			out.WriteString("+ " + b[j] + "\n")
+			j++
+		}
+	}
+	return out.String()
+}
+
+func splitLines(s string) []string {
+	if s == "" {
+		return nil
+	}
+	return strings.Split(strings.TrimSuffix(s, "\n"), "\n")
+}
@@ -0,0 +1,36 @@
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package ahmtest
+
+import (
+	"strings"
+
+	"github.com/szabba/ahm"
+)
+
+// P builds a proc.
+func P(name, title string, children ...ahm.Node) *ahm.Proc {
+	return &ahm.Proc{Name: name, Title: title, Children: children}
+}
+
+// T builds a text node.
+func T(text string) *ahm.Text {
+	return &ahm.Text{Text: text}
+}
+
+// Para builds a paragraph out of texts and inline procs.
+func Para(children ...ahm.Node) *ahm.Paragraph {
+	return &ahm.Paragraph{Children: children}
+}
+
+// Nodes lists nodes, for when a slice literal would be too noisy.
+func Nodes(nodes ...ahm.Node) []ahm.Node {
+	return nodes
+}
+
+// Lines joins lines of a document, without a newline after the last one.
+func Lines(lines ...string) string {
+	return strings.Join(lines, "\n")
+}
@@ -0,0 +1,155 @@
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package ahmtest
+
+import (
+	"math/rand"
+	"strings"
+	"testing"
+
+	"github.com/szabba/ahm"
+)
+
+// A Generator makes random valid documents.
+//
+// The parser and the printer agree on them: a printed document parses back into an equal tree.
+type Generator struct {
+	Rand *rand.Rand
+	// MaxDepth limits how deeply procs nest.
+	MaxDepth int
+	// MaxChildren limits the number of nodes in a block.
+	MaxChildren int
+}
+
+// NewGenerator creates a generator making the same documents for the same seed.
+func NewGenerator(seed int64) *Generator {
+	return &Generator{Rand: rand.New(rand.NewSource(seed)), MaxDepth: 4, MaxChildren: 5}
+}
+
+// Document prints a random document.
+func (g *Generator) Document() (string, error) {
+	return ahm.FormatString(g.Nodes())
+}
+
+// LargeDocument generates a document of at least size bytes, the same one every time, for benchmarks.
+func LargeDocument(tb testing.TB, size int) []byte {
+	tb.Helper()
+	var doc strings.Builder
+	gen := NewGenerator(1)
+	for doc.Len() < size {
+		part, err := gen.Document()
+		if err != nil {
+			tb.Fatal(err)
+		}
+		doc.WriteString(part)
+	}
+	return []byte(doc.String())
+}
+
+// Nodes makes the top level nodes of a random document.
+// Their spans are left empty.
+func (g *Generator) Nodes() []ahm.Node {
+	return g.block(0)
+}
+
+func (g *Generator) block(depth int) []ahm.Node {
+	n := 1 + g.Rand.Intn(g.MaxChildren)
+	nodes := make([]ahm.Node, 0, n)
+	prose := false
+	for i := 0; i < n; i++ {
+		// Prose after prose would be parsed as a single node.
+		if !prose && g.Rand.Intn(3) == 0 {
+			nodes = append(nodes, g.prose())
+			prose = true
+			continue
+		}
+		nodes = append(nodes, g.proc(depth))
+		prose = false
+	}
+	return nodes
+}
+
+func (g *Generator) proc(depth int) *ahm.Proc {
+	proc := &ahm.Proc{Name: g.name(), Title: g.words(g.Rand.Intn(4), false)}
+	if depth < g.MaxDepth && g.Rand.Intn(2) == 0 {
+		proc.Children = g.block(depth + 1)
+	}
+	return proc
+}
+
+// prose makes a text, or a paragraph with inline procs.
+func (g *Generator) prose() ahm.Node {
+	if g.Rand.Intn(2) == 0 {
+		return &ahm.Text{Text: g.lines()}
+	}
+
+	var parts []ahm.Node
+	n := 1 + g.Rand.Intn(4)
+	inlineFirst := g.Rand.Intn(2) == 0
+	for i := 0; i < n; i++ {
+		if (i%2 == 0) == inlineFirst {
+			parts = append(parts, &ahm.Proc{Name: g.name(), Title: g.inlineArg()})
+			continue
+		}
+		text := g.lines()
+		if i > 0 {
+			text = " " + text
+		}
+		if i < n-1 {
+			text += " "
+		}
+		parts = append(parts, &ahm.Text{Text: text})
+	}
+	if len(parts) == 1 {
+		if text, ok := parts[0].(*ahm.Text); ok {
+			return text
+		}
+	}
+	return &ahm.Paragraph{Children: parts}
+}
+
+// lines makes text spanning a few lines, some of them separated by blank lines.
+func (g *Generator) lines() string {
+	n := 1 + g.Rand.Intn(3)
+	var b strings.Builder
+	for i := 0; i < n; i++ {
+		if i > 0 {
+			b.WriteString("\n")
+			if g.Rand.Intn(4) == 0 {
+				b.WriteString("\n")
+			}
+		}
+		b.WriteString(g.words(1+g.Rand.Intn(5), true))
+	}
+	return b.String()
+}
+
+var (
+	names = []string{"CARD", "TODO", "H1", "CODE", "GO/FMT", "X-REF", "LI", "ÜBER"}
+	words = []string{"a", "text", "with", "some", "words", "zażółć", "日本語", "mail@home", "x=1", "\"quoted\"", "{braces}", "🙂", "end."}
+)
+
+func (g *Generator) name() string { return names[g.Rand.Intn(len(names))] }
+
+// words makes a line of n words, which never starts with whitespace or a proc mark.
+func (g *Generator) words(n int, nonEmpty bool) string {
+	if nonEmpty && n == 0 {
+		n = 1
+	}
+	picked := make([]string, n)
+	for i := range picked {
+		picked[i] = words[g.Rand.Intn(len(words))]
+	}
+	return strings.Join(picked, " ")
+}
+
+// inlineArg makes the argument of an inline proc, with balanced braces.
+func (g *Generator) inlineArg() string {
+	arg := g.words(g.Rand.Intn(3), false)
+	if g.Rand.Intn(3) == 0 {
+		arg = "\\frac{" + arg + "}{2}"
+	}
+	return arg
+}
@@ -0,0 +1,46 @@
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package ahmtest_test
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/szabba/ahm"
+	"github.com/szabba/ahm/ahmtest"
+	"github.com/szabba/ahm/assert"
+)
+
+func TestGeneratedDocumentsParseBackIntoTheSameTree(t *testing.T) {
+	gen := ahmtest.NewGenerator(1)
+	for i := 0; i < 500; i++ {
+		// given
+		nodes := gen.Nodes()
+
+		// when
+		doc, err := ahm.FormatString(nodes)
+
+		// then
+		assert.That(err == nil, t.Fatalf, "document %d: cannot print %# v: %s", i, nodes, err)
+		parsed, err := ahm.NewParser(strings.NewReader(doc)).ParseAll()
+		assert.That(err == nil, t.Fatalf, "document %d: cannot parse %q: %s", i, doc, err)
+		assert.That(len(parsed) == len(nodes), t.Fatalf, "document %d: got %d nodes from %q, wanted %d", i, len(parsed), doc, len(nodes))
+		for j := range nodes {
+			assert.That(ahm.Equals(parsed[j], nodes[j]), t.Fatalf, "document %d: node %d differs after parsing %q", i, j, doc)
+		}
+	}
+}
+
+func TestGeneratorsWithTheSameSeedAgree(t *testing.T) {
+	// given
+	left, right := ahmtest.NewGenerator(7), ahmtest.NewGenerator(7)
+
+	// when
+	a, _ := left.Document()
+	b, _ := right.Document()
+
+	// then
+	assert.That(a == b, t.Errorf, "got %q and %q", a, b)
+}
@@ -0,0 +1,47 @@
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package ahmtest
+
+import (
+	"flag"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/szabba/ahm"
+)
+
+var update = flag.Bool("update", false, "rewrite golden files with the current output")
+
+// Golden compares got with the contents of the golden file at path.
+//
+// When the tests run with -update, the file is rewritten instead.
+func Golden(t testing.TB, path, got string) {
+	t.Helper()
+	if *update {
+		err := os.MkdirAll(filepath.Dir(path), 0755)
+		if err == nil {
+			err = os.WriteFile(path, []byte(got), 0644)
+		}
+		if err != nil {
+			t.Fatalf("cannot update golden file: %s", err)
+		}
+		return
+	}
+
+	want, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("cannot read golden file (run with -update to create it): %s", err)
+	}
+	if got != string(want) {
+		t.Errorf("the output differs from %s (-got +wanted):\n%s", path, LineDiff(got, string(want)))
+	}
+}
+
+// GoldenTree compares the rendering of a tree with the golden file at path.
+func GoldenTree(t testing.TB, path string, nodes []ahm.Node) {
+	t.Helper()
+	Golden(t, path, Render(nodes))
+}
@@ -0,0 +1,22 @@
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package ahmtest
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/szabba/ahm"
+)
+
+// Parse parses the lines of a document, failing the test when they do not parse.
+func Parse(t testing.TB, cfg ahm.Config, lines ...string) []ahm.Node {
+	t.Helper()
+	nodes, err := cfg.NewParser(strings.NewReader(Lines(lines...))).ParseAll()
+	if err != nil {
+		t.Fatalf("unexpected parse error: %s", err)
+	}
+	return nodes
+}
@@ -0,0 +1,76 @@
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package ahmtest
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/szabba/ahm"
+)
+
+// Render lays out a tree a node per line, with children indented under their parents.
+//
+// Spans are left out, so that trees with the same content render the same.
+// Text is quoted, which keeps the rendering of a node on a single line.
+func Render(nodes []ahm.Node) string {
+	var b strings.Builder
+	render(&b, nodes, "", false)
+	return b.String()
+}
+
+// RenderWithSpans is Render with the span of each node, arg and attr after it, offsets included.
+func RenderWithSpans(nodes []ahm.Node) string {
+	var b strings.Builder
+	render(&b, nodes, "", true)
+	return b.String()
+}
+
+func render(b *strings.Builder, nodes []ahm.Node, indent string, spans bool) {
+	at := func(span ahm.Span) string {
+		if !spans {
+			return ""
+		}
+		start, end := span.Offsets()
+		return fmt.Sprintf(" at %s [%d,%d)", span, start, end)
+	}
+
+	for _, node := range nodes {
+		b.WriteString(indent)
+		switch node := node.(type) {
+		case *ahm.Proc:
+			b.WriteString("@" + node.Name)
+			if node.Title != "" {
+				b.WriteString(" " + strconv.Quote(node.Title))
+			}
+			b.WriteString(at(node.Span) + "\n")
+			for _, arg := range node.Args {
+				fmt.Fprintf(b, "%s  arg %s%s\n", indent, quoteIf(arg.Value, arg.Quoted), at(arg.Span))
+			}
+			for _, attr := range node.Attrs {
+				fmt.Fprintf(b, "%s  attr %s=%s%s\n", indent, attr.Key, quoteIf(attr.Value, attr.Quoted), at(attr.Span))
+			}
+			render(b, node.Children, indent+"  ", spans)
+
+		case *ahm.Text:
+			b.WriteString(strconv.Quote(node.Text) + at(node.Span) + "\n")
+
+		case *ahm.Paragraph:
+			b.WriteString("paragraph" + at(node.Span) + "\n")
+			render(b, node.Children, indent+"  ", spans)
+
+		default:
+			fmt.Fprintf(b, "%#v\n", node)
+		}
+	}
+}
+
+func quoteIf(s string, quoted bool) string {
+	if quoted {
+		return strconv.Quote(s)
+	}
+	return s
+}
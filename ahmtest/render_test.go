@@ -0,0 +1,99 @@
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package ahmtest
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/szabba/ahm"
+	"github.com/szabba/ahm/assert"
+)
+
+func TestRenderIndentsChildrenAndQuotesText(t *testing.T) {
+	// given
+	card := P("CARD", "x", T("some\ntext"))
+	card.Args = []ahm.Arg{{Value: "x"}}
+	card.Attrs = []ahm.Attr{{Key: "mood", Value: "a b", Quoted: true}}
+	nodes := []ahm.Node{card, Para(T("Energy is "), P("MATH", "E = mc^2"))}
+
+	want := Lines(
+		`@CARD "x"`,
+		`  arg x`,
+		`  attr mood="a b"`,
+		`  "some\ntext"`,
+		`paragraph`,
+		`  "Energy is "`,
+		`  @MATH "E = mc^2"`,
+		``)
+
+	// when
+	got := Render(nodes)
+
+	// then
+	assert.That(got == want, t.Errorf, "got\n%s\nwanted\n%s", got, want)
+}
+
+func TestLineDiffMarksRemovedAndAddedLines(t *testing.T) {
+	// given
+	old := Lines("a", "b", "c", "")
+	updated := Lines("a", "c", "d", "")
+
+	want := Lines("  a", "- b", "  c", "+ d", "")
+
+	// when
+	got := LineDiff(old, updated)
+
+	// then
+	assert.That(got == want, t.Errorf, "got\n%s\nwanted\n%s", got, want)
+}
+
+func TestReportDiffsIgnoresSpans(t *testing.T) {
+	// given
+	nodes, err := ahm.Config{Source: "x.ahm"}.NewParser(strings.NewReader("@CARD x\n  text")).ParseAll()
+	assert.That(err == nil, t.Fatalf, "unexpected error: %s", err)
+
+	reported := false
+	onErr := func(string, ...interface{}) { reported = true }
+
+	// when
+	equal := ReportDiffs(onErr, nodes, []ahm.Node{P("CARD", "x", T("text"))})
+
+	// then
+	assert.That(equal && !reported, t.Errorf, "got a difference between equal trees")
+}
+
+func TestReportDiffsShowsTheChangedNodes(t *testing.T) {
+	// given
+	got := []ahm.Node{P("CARD", "x", T("text"))}
+	want := []ahm.Node{P("CARD", "x", T("other text"))}
+
+	var report string
+	onErr := func(format string, args ...interface{}) { report = fmt.Sprintf(format, args...) }
+
+	// when
+	equal := ReportDiffs(onErr, got, want)
+
+	// then
+	assert.That(!equal, t.Fatalf, "the trees should differ")
+	assert.That(strings.Contains(report, `-   "text"`) && strings.Contains(report, `+   "other text"`), t.Errorf, "got report\n%s", report)
+}
+
+func TestReportExactDiffsShowsTheChangedSpans(t *testing.T) {
+	// given
+	got := Parse(t, ahm.Config{Source: "x.ahm"}, "@CARD x", "  text")
+	want := Parse(t, ahm.Config{Source: "x.ahm"}, "@CARD x", "", "  text")
+
+	var report string
+	onErr := func(format string, args ...interface{}) { report = fmt.Sprintf(format, args...) }
+
+	// when
+	equal := ReportExactDiffs(onErr, got, want)
+
+	// then
+	assert.That(!equal, t.Fatalf, "the trees should differ")
+	assert.That(strings.Contains(report, `-   "text" at x.ahm:2,3:2,7 [10,14)`), t.Errorf, "got report\n%s", report)
+}
@@ -0,0 +1,183 @@
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package ahm
+
+import (
+	"strings"
+	"unicode"
+
+	"github.com/szabba/ahm/ahmerr"
+	"github.com/szabba/ahm/position"
+)
+
+// An Arg is a positional proc argument.
+type Arg struct {
+	Value string
+	// Quoted is true when the argument was written as a quoted string.
+	Quoted bool
+	Span   Span
+}
+
+// An Attr is a key=value proc argument.
+type Attr struct {
+	Key, Value string
+	// Quoted is true when the value was written as a quoted string.
+	Quoted bool
+	Span   Span
+}
+
+// Arg returns the value of the i-th positional argument, or "" when there are fewer arguments.
+func (proc *Proc) Arg(i int) string {
+	if i < 0 || i >= len(proc.Args) {
+		return ""
+	}
+	return proc.Args[i].Value
+}
+
+// Attr returns the value of the last attribute with the given key.
+func (proc *Proc) Attr(key string) (string, bool) {
+	for i := len(proc.Attrs) - 1; i >= 0; i-- {
+		if proc.Attrs[i].Key == key {
+			return proc.Attrs[i].Value, true
+		}
+	}
+	return "", false
+}
+
+// argScanner splits a proc argument into whitespace-separated positional arguments and key=value attributes.
+//
+// Both positional arguments and attribute values can be quoted strings.
+// Within quotes, a backslash escapes a quote, a backslash, or one of n, t and r.
+type argScanner struct {
+	source string
+	runes  []rune
+	i      int
+	pos    position.Position
+
+	args  []Arg
+	attrs []Attr
+}
+
+func scanArgs(text string, start position.Position, source string) ([]Arg, []Attr, error) {
+	scanner := &argScanner{source: source, runes: []rune(text), pos: start}
+	err := scanner.run()
+	return scanner.args, scanner.attrs, err
+}
+
+func (scanner *argScanner) run() error {
+	for {
+		scanner.skipSpace()
+		if scanner.atEnd() {
+			return nil
+		}
+		err := scanner.scanOne()
+		if err != nil {
+			return err
+		}
+	}
+}
+
+func (scanner *argScanner) scanOne() error {
+	start := scanner.pos
+
+	if scanner.peek() == '"' {
+		value, err := scanner.scanQuoted()
+		if err != nil {
+			return err
+		}
+		scanner.args = append(scanner.args, Arg{Value: value, Quoted: true, Span: scanner.spanFrom(start)})
+		return nil
+	}
+
+	key := scanner.scanWhile(isAttrKeyRune)
+	if key == "" || scanner.atEnd() || scanner.peek() != '=' {
+		word := key + scanner.scanWhile(isNotSpace)
+		scanner.args = append(scanner.args, Arg{Value: word, Span: scanner.spanFrom(start)})
+		return nil
+	}
+	scanner.advance()
+
+	if scanner.atEnd() || scanner.peek() != '"' {
+		value := scanner.scanWhile(isNotSpace)
+		scanner.attrs = append(scanner.attrs, Attr{Key: key, Value: value, Span: scanner.spanFrom(start)})
+		return nil
+	}
+
+	value, err := scanner.scanQuoted()
+	if err != nil {
+		return err
+	}
+	scanner.attrs = append(scanner.attrs, Attr{Key: key, Value: value, Quoted: true, Span: scanner.spanFrom(start)})
+	return nil
+}
+
+func (scanner *argScanner) scanQuoted() (string, error) {
+	start := scanner.pos
+	scanner.advance()
+
+	var value strings.Builder
+	for !scanner.atEnd() {
+		r := scanner.advance()
+		switch r {
+		case '"':
+			if !scanner.atEnd() && !unicode.IsSpace(scanner.peek()) {
+				return "", ahmerr.Diagnosef(scanner.pos.StartSpan().In(scanner.source), "quoted string must be followed by a space")
+			}
+			return value.String(), nil
+
+		case '\\':
+			escPos := scanner.pos
+			if scanner.atEnd() {
+				break
+			}
+			esc, ok := argEscapes[scanner.advance()]
+			if !ok {
+				return "", ahmerr.Diagnosef(scanner.spanFrom(escPos), "unknown escape sequence")
+			}
+			value.WriteRune(esc)
+
+		default:
+			value.WriteRune(r)
+		}
+	}
+	return "", ahmerr.Diagnosef(scanner.spanFrom(start), "unterminated quoted string")
+}
+
+var argEscapes = map[rune]rune{
+	'"':  '"',
+	'\\': '\\',
+	'n':  '\n',
+	't':  '\t',
+	'r':  '\r',
+}
+
+func (scanner *argScanner) skipSpace() { scanner.scanWhile(unicode.IsSpace) }
+
+func (scanner *argScanner) scanWhile(p func(rune) bool) string {
+	from := scanner.i
+	for !scanner.atEnd() && p(scanner.peek()) {
+		scanner.advance()
+	}
+	return string(scanner.runes[from:scanner.i])
+}
+
+func (scanner *argScanner) atEnd() bool { return scanner.i >= len(scanner.runes) }
+
+func (scanner *argScanner) peek() rune { return scanner.runes[scanner.i] }
+
+func (scanner *argScanner) advance() rune {
+	r := scanner.runes[scanner.i]
+	scanner.i++
+	scanner.pos = scanner.pos.NextAfter(r)
+	return r
+}
+
+func (scanner *argScanner) spanFrom(start position.Position) position.SpanIn {
+	return position.SpanFromTo(start, scanner.pos).In(scanner.source)
+}
+
+func isNotSpace(r rune) bool { return !unicode.IsSpace(r) }
+
+func isAttrKeyRune(r rune) bool { return !unicode.IsSpace(r) && r != '=' }
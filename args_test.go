@@ -0,0 +1,139 @@
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package ahm_test
+
+import (
+	"io"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/pkg/errors"
+	"github.com/szabba/ahm"
+	"github.com/szabba/ahm/ahmerr"
+	. "github.com/szabba/ahm/ahmtest"
+	"github.com/szabba/ahm/assert"
+	"github.com/szabba/ahm/position"
+)
+
+func TestArgsAreNotStructuredByDefault(t *testing.T) {
+	// given
+	wantNode := P("VALUE", "Luke.yearning-to-leave 40")
+
+	input := strings.NewReader("@VALUE Luke.yearning-to-leave 40")
+
+	parser := ahm.NewParser(input)
+
+	// when
+	node, err := parser.Parse()
+
+	// then
+	assert.That(errors.Cause(err) == io.EOF, t.Fatalf, "got error %q, wanted %q", err, io.EOF)
+	ReportNodeDiffs(t.Fatalf, node, wantNode)
+}
+
+func TestPositionalArgsAreSplitOnWhitespace(t *testing.T) {
+	// given
+	wantNode := &ahm.Proc{
+		Name:  "VALUE",
+		Title: "Luke.yearning-to-leave  40",
+		Args: []ahm.Arg{
+			{Value: "Luke.yearning-to-leave", Span: argSpan(1, 8, 1, 30)},
+			{Value: "40", Span: argSpan(1, 32, 1, 34)},
+		},
+	}
+
+	input := strings.NewReader("@VALUE Luke.yearning-to-leave  40")
+
+	parser := ahm.Config{Source: "cards.ahm", StructuredArgs: true}.NewParser(input)
+
+	// when
+	node, err := parser.Parse()
+
+	// then
+	assert.That(errors.Cause(err) == io.EOF, t.Fatalf, "got error %q, wanted %q", err, io.EOF)
+	ReportNodeDiffs(t.Fatalf, node, wantNode)
+	proc := node.(*ahm.Proc)
+	assert.That(reflect.DeepEqual(proc.Args, wantNode.Args), t.Fatalf, "got args %v, wanted %v", proc.Args, wantNode.Args)
+	assert.That(reflect.DeepEqual(proc.Attrs, wantNode.Attrs), t.Fatalf, "got attrs %v, wanted %v", proc.Attrs, wantNode.Attrs)
+}
+
+func TestQuotedArgsCanContainSpacesAndEscapes(t *testing.T) {
+	// given
+	wantNode := &ahm.Proc{
+		Name:  "OPTION",
+		Title: `"Say \"hi\"" next`,
+		Args: []ahm.Arg{
+			{Value: `Say "hi"`, Quoted: true, Span: argSpan(1, 9, 1, 21)},
+			{Value: "next", Span: argSpan(1, 22, 1, 26)},
+		},
+	}
+
+	input := strings.NewReader(`@OPTION "Say \"hi\"" next`)
+
+	parser := ahm.Config{Source: "cards.ahm", StructuredArgs: true}.NewParser(input)
+
+	// when
+	node, err := parser.Parse()
+
+	// then
+	assert.That(errors.Cause(err) == io.EOF, t.Fatalf, "got error %q, wanted %q", err, io.EOF)
+	ReportNodeDiffs(t.Fatalf, node, wantNode)
+	proc := node.(*ahm.Proc)
+	assert.That(reflect.DeepEqual(proc.Args, wantNode.Args), t.Fatalf, "got args %v, wanted %v", proc.Args, wantNode.Args)
+	assert.That(reflect.DeepEqual(proc.Attrs, wantNode.Attrs), t.Fatalf, "got attrs %v, wanted %v", proc.Attrs, wantNode.Attrs)
+}
+
+func TestAttrsAreSeparatedFromPositionalArgs(t *testing.T) {
+	// given
+	wantNode := &ahm.Proc{
+		Name:  "CODE",
+		Title: `go title="Hello, world" tabs=4`,
+		Args: []ahm.Arg{
+			{Value: "go", Span: argSpan(1, 7, 1, 9)},
+		},
+		Attrs: []ahm.Attr{
+			{Key: "title", Value: "Hello, world", Quoted: true, Span: argSpan(1, 10, 1, 30)},
+			{Key: "tabs", Value: "4", Span: argSpan(1, 31, 1, 37)},
+		},
+	}
+
+	input := strings.NewReader(`@CODE go title="Hello, world" tabs=4`)
+
+	parser := ahm.Config{Source: "cards.ahm", StructuredArgs: true}.NewParser(input)
+
+	// when
+	node, err := parser.Parse()
+
+	// then
+	assert.That(errors.Cause(err) == io.EOF, t.Fatalf, "got error %q, wanted %q", err, io.EOF)
+	ReportNodeDiffs(t.Fatalf, node, wantNode)
+	proc := node.(*ahm.Proc)
+	assert.That(reflect.DeepEqual(proc.Args, wantNode.Args), t.Fatalf, "got args %v, wanted %v", proc.Args, wantNode.Args)
+	assert.That(reflect.DeepEqual(proc.Attrs, wantNode.Attrs), t.Fatalf, "got attrs %v, wanted %v", proc.Attrs, wantNode.Attrs)
+}
+
+func TestUnterminatedQuotedArgIsAnError(t *testing.T) {
+	// given
+	input := strings.NewReader(`@OPTION "Change the music`)
+
+	parser := ahm.Config{StructuredArgs: true}.NewParser(input)
+
+	// when
+	_, err := parser.Parse()
+
+	// then
+	diag, ok := err.(*ahmerr.Diagnostic)
+	assert.That(ok, t.Fatalf, "got error %#v, wanted a diagnostic", err)
+	start := diag.Span.StartsAt()
+	assert.That(start.Line() == 1 && start.Column() == 9, t.Errorf, "got diagnostic at %s, wanted it at the opening quote", diag.Span)
+}
+
+// argSpan is a span on the first line of an ASCII input, where the byte offsets follow from the columns.
+func argSpan(startLine, startCol, endLine, endCol int) ahm.Span {
+	return position.SpanFromTo(
+		position.PositionAt(startLine, startCol, startCol-1),
+		position.PositionAt(endLine, endCol, endCol-1)).In("cards.ahm")
+}
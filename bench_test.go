@@ -0,0 +1,34 @@
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package ahm_test
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/szabba/ahm"
+	"github.com/szabba/ahm/ahmtest"
+)
+
+func BenchmarkParseReader(b *testing.B) {
+	doc := ahmtest.LargeDocument(b, 1<<20)
+	benchmarkParser(b, doc, func() *ahm.Parser { return ahm.NewParser(bytes.NewReader(doc)) })
+}
+
+func BenchmarkParseBytes(b *testing.B) {
+	doc := ahmtest.LargeDocument(b, 1<<20)
+	benchmarkParser(b, doc, func() *ahm.Parser { return ahm.NewBytesParser(doc) })
+}
+
+func benchmarkParser(b *testing.B, doc []byte, newParser func() *ahm.Parser) {
+	b.SetBytes(int64(len(doc)))
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		_, err := newParser().ParseAll()
+		if err != nil {
+			b.Fatal(err)
+		}
+	}
+}
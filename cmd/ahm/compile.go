@@ -0,0 +1,43 @@
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package main
+
+import (
+	"os"
+
+	"github.com/szabba/ahm/ahmbin"
+)
+
+var compileCommand = &command{
+	name:    "compile",
+	usage:   "compile [-o out.ahmb] [file]",
+	summary: "encode a document in the binary form that loads without parsing",
+}
+
+func init() { compileCommand.run = runCompile }
+
+func runCompile(env *env, args []string) error {
+	flags := newFlagSet(env, compileCommand)
+	out := flags.String("o", "", "the file to write to; stdout by default")
+	err := parseFlags(flags, args)
+	if err != nil || flags.NArg() > 1 {
+		return errUsage
+	}
+
+	nodes, err := parseFile(env, flags.Arg(0))
+	if err != nil {
+		return err
+	}
+	data, err := ahmbin.Marshal(nodes)
+	if err != nil {
+		return err
+	}
+
+	if *out == "" {
+		_, err = env.stdout.Write(data)
+		return err
+	}
+	return os.WriteFile(*out, data, 0644)
+}
@@ -0,0 +1,86 @@
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package main
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+
+	"github.com/szabba/ahm"
+	"github.com/szabba/ahm/markdown"
+)
+
+var convertCommand = &command{
+	name:    "convert",
+	usage:   "convert [-from ahm|md] [-to ahm|md] [file]",
+	summary: "convert between AHM and Markdown",
+}
+
+func init() { convertCommand.run = runConvert }
+
+func runConvert(env *env, args []string) error {
+	flags := newFlagSet(env, convertCommand)
+	from := flags.String("from", "", "the format of the input; guessed from the file extension by default")
+	to := flags.String("to", "", "the format of the output; the other format by default")
+	err := parseFlags(flags, args)
+	if err != nil || flags.NArg() > 1 {
+		return errUsage
+	}
+	name := flags.Arg(0)
+
+	if *from == "" {
+		*from = formatOf(name)
+	}
+	if *to == "" {
+		*to = otherFormat(*from)
+	}
+	if !knownFormat(*from) || !knownFormat(*to) {
+		return fmt.Errorf("unknown format; use one of ahm or md")
+	}
+
+	nodes, err := readFormat(env, name, *from)
+	if err != nil {
+		return err
+	}
+	return writeFormat(env, nodes, *to)
+}
+
+func formatOf(name string) string {
+	switch strings.ToLower(filepath.Ext(name)) {
+	case ".md", ".markdown":
+		return "md"
+	default:
+		return "ahm"
+	}
+}
+
+func otherFormat(format string) string {
+	if format == "md" {
+		return "ahm"
+	}
+	return "md"
+}
+
+func knownFormat(format string) bool { return format == "ahm" || format == "md" }
+
+func readFormat(env *env, name, format string) ([]ahm.Node, error) {
+	if format == "ahm" {
+		return parseFile(env, name)
+	}
+	r, err := openInput(env, name)
+	if err != nil {
+		return nil, err
+	}
+	defer r.Close()
+	return markdown.Import(r)
+}
+
+func writeFormat(env *env, nodes []ahm.Node, format string) error {
+	if format == "ahm" {
+		return ahm.Format(env.stdout, nodes)
+	}
+	return markdown.Export(env.stdout, nodes)
+}
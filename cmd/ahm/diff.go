@@ -0,0 +1,38 @@
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package main
+
+import (
+	"fmt"
+
+	"github.com/szabba/ahm/diff"
+)
+
+var diffCommand = &command{
+	name:    "diff",
+	usage:   "diff old.ahm new.ahm",
+	summary: "list the structural changes between two documents",
+}
+
+func init() { diffCommand.run = runDiff }
+
+func runDiff(env *env, args []string) error {
+	if len(args) != 2 {
+		return errUsage
+	}
+	old, err := parseFile(env, args[0])
+	if err != nil {
+		return err
+	}
+	updated, err := parseFile(env, args[1])
+	if err != nil {
+		return err
+	}
+
+	for _, change := range diff.Trees(old, updated) {
+		fmt.Fprintln(env.stdout, change)
+	}
+	return nil
+}
@@ -0,0 +1,56 @@
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package main
+
+import (
+	"errors"
+	"flag"
+	"io"
+	"os"
+
+	"github.com/szabba/ahm"
+)
+
+// errUsage makes a command print its usage.
+var errUsage = errors.New("usage")
+
+// newFlagSet creates a flag set that reports problems as errUsage.
+func newFlagSet(env *env, cmd *command) *flag.FlagSet {
+	flags := flag.NewFlagSet(cmd.name, flag.ContinueOnError)
+	flags.SetOutput(env.stderr)
+	flags.Usage = func() {}
+	return flags
+}
+
+// parseFlags parses the flags of a command, turning all the failures into errUsage.
+func parseFlags(flags *flag.FlagSet, args []string) error {
+	if flags.Parse(args) != nil {
+		return errUsage
+	}
+	return nil
+}
+
+// openInput opens a named file, or stdin for "-" or an empty name.
+func openInput(env *env, name string) (io.ReadCloser, error) {
+	if name == "" || name == "-" {
+		return io.NopCloser(env.stdin), nil
+	}
+	return os.Open(name)
+}
+
+// parseFile parses a named file, or stdin for "-" or an empty name.
+func parseFile(env *env, name string) ([]ahm.Node, error) {
+	r, err := openInput(env, name)
+	if err != nil {
+		return nil, err
+	}
+	defer r.Close()
+
+	source := name
+	if source == "" {
+		source = "-"
+	}
+	return ahm.Config{Source: source}.NewParser(r).ParseAll()
+}
@@ -0,0 +1,22 @@
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package main
+
+import "github.com/szabba/ahm/lsp"
+
+var lspCommand = &command{
+	name:    "lsp",
+	usage:   "lsp",
+	summary: "run a language server on stdin and stdout",
+}
+
+func init() { lspCommand.run = runLSP }
+
+func runLSP(env *env, args []string) error {
+	if len(args) != 0 {
+		return errUsage
+	}
+	return lsp.NewServer(env.stdin, env.stdout).Serve()
+}
@@ -0,0 +1,95 @@
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+// Command ahm works with AHM documents.
+//
+// Usage:
+//
+//	ahm <command> [arguments]
+//
+// Run ahm help to list the commands.
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+)
+
+// A command is a subcommand of ahm.
+type command struct {
+	name    string
+	usage   string
+	summary string
+	run     func(env *env, args []string) error
+}
+
+// An env is what a command can use to communicate with the outside world.
+type env struct {
+	stdin  io.Reader
+	stdout io.Writer
+	stderr io.Writer
+}
+
+var commands []*command
+
+func init() {
+	commands = []*command{
+		convertCommand,
+		todoCommand,
+		playCommand,
+		diffCommand,
+		mergeCommand,
+		lspCommand,
+		compileCommand,
+	}
+}
+
+func main() {
+	env := &env{stdin: os.Stdin, stdout: os.Stdout, stderr: os.Stderr}
+	os.Exit(run(env, os.Args[1:]))
+}
+
+func run(env *env, args []string) int {
+	if len(args) == 0 || args[0] == "help" || args[0] == "-h" || args[0] == "--help" {
+		printUsage(env.stderr)
+		if len(args) == 0 {
+			return 2
+		}
+		return 0
+	}
+
+	cmd := findCommand(args[0])
+	if cmd == nil {
+		fmt.Fprintf(env.stderr, "ahm: unknown command %q\n", args[0])
+		printUsage(env.stderr)
+		return 2
+	}
+
+	err := cmd.run(env, args[1:])
+	if err == errUsage {
+		fmt.Fprintf(env.stderr, "usage: ahm %s\n", cmd.usage)
+		return 2
+	} else if err != nil {
+		fmt.Fprintf(env.stderr, "ahm %s: %s\n", cmd.name, err)
+		return 1
+	}
+	return 0
+}
+
+func findCommand(name string) *command {
+	for _, cmd := range commands {
+		if cmd.name == name {
+			return cmd
+		}
+	}
+	return nil
+}
+
+func printUsage(w io.Writer) {
+	fmt.Fprintf(w, "usage: ahm <command> [arguments]\n\ncommands:\n")
+	for _, cmd := range commands {
+		fmt.Fprintf(w, "  %-10s %s\n", cmd.name, cmd.summary)
+	}
+}
@@ -0,0 +1,209 @@
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package main
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/szabba/ahm"
+	"github.com/szabba/ahm/ahmbin"
+	"github.com/szabba/ahm/assert"
+)
+
+func TestHelpPrintsTheUsage(t *testing.T) {
+	// given
+	env, _, stderr := testEnv("")
+
+	// when
+	code := run(env, []string{"help"})
+
+	// then
+	assert.That(code == 0, t.Errorf, "got exit code %d, wanted 0", code)
+	assert.That(strings.Contains(stderr.String(), "usage: ahm"), t.Errorf, "got stderr %q", stderr)
+}
+
+func TestConvertMarkdownFromStdin(t *testing.T) {
+	// given
+	env, stdout, stderr := testEnv("# Title\n\nSome *text*.\n")
+
+	// when
+	code := run(env, []string{"convert", "-from", "md"})
+
+	// then
+	assert.That(code == 0, t.Fatalf, "got exit code %d, stderr: %s", code, stderr)
+	want := "@H1 Title\n\nSome @EM{text}.\n"
+	assert.That(stdout.String() == want, t.Errorf, "got %q, wanted %q", stdout, want)
+}
+
+func TestConvertAHMToMarkdown(t *testing.T) {
+	// given
+	env, stdout, stderr := testEnv("@H2 Title\n")
+
+	// when
+	code := run(env, []string{"convert", "-from", "ahm"})
+
+	// then
+	assert.That(code == 0, t.Fatalf, "got exit code %d, stderr: %s", code, stderr)
+	want := "## Title\n"
+	assert.That(stdout.String() == want, t.Errorf, "got %q, wanted %q", stdout, want)
+}
+
+func TestUnknownCommandsAreUsageErrors(t *testing.T) {
+	// given
+	env, _, stderr := testEnv("")
+
+	// when
+	code := run(env, []string{"frobnicate"})
+
+	// then
+	assert.That(code == 2, t.Errorf, "got exit code %d, wanted 2", code)
+	assert.That(strings.Contains(stderr.String(), "unknown command"), t.Errorf, "got stderr %q", stderr)
+}
+
+func testEnv(stdin string) (*env, *bytes.Buffer, *bytes.Buffer) {
+	stdout, stderr := new(bytes.Buffer), new(bytes.Buffer)
+	return &env{stdin: strings.NewReader(stdin), stdout: stdout, stderr: stderr}, stdout, stderr
+}
+
+func TestTodoDoneRewritesTheFile(t *testing.T) {
+	// given
+	name := filepath.Join(t.TempDir(), "todos.ahm")
+	err := os.WriteFile(name, []byte("@DONE Buy milk\n@TODO Get recipe\n"), 0644)
+	assert.That(err == nil, t.Fatalf, "cannot write the list: %s", err)
+	env, _, stderr := testEnv("")
+
+	// when
+	code := run(env, []string{"todo", "done", name, "2"})
+
+	// then
+	assert.That(code == 0, t.Fatalf, "got exit code %d, stderr: %s", code, stderr)
+	got, _ := os.ReadFile(name)
+	want := "@DONE Buy milk\n@DONE Get recipe\n"
+	assert.That(string(got) == want, t.Errorf, "got %q, wanted %q", got, want)
+}
+
+func TestPlayFollowsTheChoices(t *testing.T) {
+	// given
+	name := filepath.Join(t.TempDir(), "episode.ahm")
+	src := strings.Join([]string{
+		"@EPISODE Test",
+		"  @CARD start",
+		"    Hello.",
+		"    @CHOICE",
+		"      @OPTION \"Skip ahead\"",
+		"        @EFFECT",
+		"          @GOTO end",
+		"  @CARD middle",
+		"    Never seen.",
+		"  @CARD end",
+		"    Bye."}, "\n")
+	err := os.WriteFile(name, []byte(src), 0644)
+	assert.That(err == nil, t.Fatalf, "cannot write the episode: %s", err)
+	env, stdout, stderr := testEnv("1\n\n")
+
+	// when
+	code := run(env, []string{"play", name})
+
+	// then
+	assert.That(code == 0, t.Fatalf, "got exit code %d, stderr: %s", code, stderr)
+	out := stdout.String()
+	assert.That(strings.Contains(out, "1) Skip ahead"), t.Errorf, "got output %q, wanted the option", out)
+	assert.That(!strings.Contains(out, "Never seen."), t.Errorf, "got output %q, wanted the middle card skipped", out)
+	assert.That(strings.HasSuffix(out, "The end.\n"), t.Errorf, "got output %q, wanted the game to end", out)
+}
+
+func TestDiffListsChanges(t *testing.T) {
+	// given
+	dir := t.TempDir()
+	old, updated := filepath.Join(dir, "old.ahm"), filepath.Join(dir, "new.ahm")
+	os.WriteFile(old, []byte("@CARD a\n@CARD b\n"), 0644)
+	os.WriteFile(updated, []byte("@CARD a\n"), 0644)
+	env, stdout, stderr := testEnv("")
+
+	// when
+	code := run(env, []string{"diff", old, updated})
+
+	// then
+	assert.That(code == 0, t.Fatalf, "got exit code %d, stderr: %s", code, stderr)
+	assert.That(strings.HasPrefix(stdout.String(), "delete @CARD b at /1 ("), t.Errorf, "got output %q", stdout)
+}
+
+func TestMergeReplacesOurs(t *testing.T) {
+	// given
+	dir := t.TempDir()
+	base, ours, theirs := filepath.Join(dir, "base"), filepath.Join(dir, "ours"), filepath.Join(dir, "theirs")
+	os.WriteFile(base, []byte("@CARD a\n@CARD b\n"), 0644)
+	os.WriteFile(ours, []byte("@CARD a\n@CARD b\n  Ours.\n"), 0644)
+	os.WriteFile(theirs, []byte("@CARD a\n  Theirs.\n@CARD b\n"), 0644)
+	env, _, stderr := testEnv("")
+
+	// when
+	code := run(env, []string{"merge", base, ours, theirs})
+
+	// then
+	assert.That(code == 0, t.Fatalf, "got exit code %d, stderr: %s", code, stderr)
+	got, _ := os.ReadFile(ours)
+	want := "@CARD a\n  Theirs.\n@CARD b\n  Ours.\n"
+	assert.That(string(got) == want, t.Errorf, "got %q, wanted %q", got, want)
+}
+
+func TestMergeKeepsTheOnlyChangedSideAsItIs(t *testing.T) {
+	// given
+	dir := t.TempDir()
+	base, ours, theirs := filepath.Join(dir, "base"), filepath.Join(dir, "ours"), filepath.Join(dir, "theirs")
+	os.WriteFile(base, []byte("@CARD a\n  Text.\n"), 0644)
+	os.WriteFile(ours, []byte("@CARD a\n  Text.\n"), 0644)
+	theirText := "@CARD a\n    Text.\n\n\n@CARD   b\n    More.\n"
+	os.WriteFile(theirs, []byte(theirText), 0644)
+	env, _, stderr := testEnv("")
+
+	// when
+	code := run(env, []string{"merge", base, ours, theirs})
+
+	// then
+	assert.That(code == 0, t.Fatalf, "got exit code %d, stderr: %s", code, stderr)
+	got, _ := os.ReadFile(ours)
+	assert.That(string(got) == theirText, t.Errorf, "got %q, wanted %q", got, theirText)
+}
+
+func TestMergeFormatsOnlyTheMergedNodes(t *testing.T) {
+	// given
+	dir := t.TempDir()
+	base, ours, theirs := filepath.Join(dir, "base"), filepath.Join(dir, "ours"), filepath.Join(dir, "theirs")
+	os.WriteFile(base, []byte("@CARD a\n    First.\n@CARD b\n    Second.\n"), 0644)
+	os.WriteFile(ours, []byte("@CARD a\n    First.\n    @EXTRA\n@CARD b\n    Second.\n"), 0644)
+	os.WriteFile(theirs, []byte("@CARD a\n    @START\n    First.\n@CARD b\n    Second!\n"), 0644)
+	env, _, stderr := testEnv("")
+
+	// when
+	code := run(env, []string{"merge", base, ours, theirs})
+
+	// then
+	assert.That(code == 0, t.Fatalf, "got exit code %d, stderr: %s", code, stderr)
+	got, _ := os.ReadFile(ours)
+	want := "@CARD a\n  @START\n  First.\n  @EXTRA\n\n@CARD b\n    Second!\n"
+	assert.That(string(got) == want, t.Errorf, "got %q, wanted %q", got, want)
+}
+
+func TestCompiledDocumentsDecodeIntoTheParsedNodes(t *testing.T) {
+	// given
+	src := "@CARD start\n  Some @EM{text}.\n"
+	env, stdout, stderr := testEnv(src)
+
+	// when
+	code := run(env, []string{"compile"})
+
+	// then
+	assert.That(code == 0, t.Fatalf, "got exit code %d, stderr: %s", code, stderr)
+	nodes, err := ahmbin.Unmarshal(stdout.Bytes())
+	assert.That(err == nil, t.Fatalf, "cannot decode the output: %s", err)
+	want, _ := ahm.Config{Source: "-"}.NewParser(strings.NewReader(src)).ParseAll()
+	assert.That(reflect.DeepEqual(nodes, want), t.Errorf, "got %v, wanted %v", nodes, want)
+}
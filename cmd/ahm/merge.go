@@ -0,0 +1,180 @@
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/szabba/ahm"
+	"github.com/szabba/ahm/merge"
+)
+
+// mergeCommand can be used as a git merge driver:
+//
+//	# .gitattributes
+//	*.ahm merge=ahm
+//
+//	# .git/config
+//	[merge "ahm"]
+//		name = AHM structural merge
+//		driver = ahm merge %O %A %B
+//
+// Like git expects, the result replaces ours, and the command fails when there are conflicts.
+var mergeCommand = &command{
+	name:    "merge",
+	usage:   "merge [-o output] base ours theirs",
+	summary: "merge two versions of a document, for use as a git merge driver",
+}
+
+func init() { mergeCommand.run = runMerge }
+
+func runMerge(env *env, args []string) error {
+	flags := newFlagSet(env, mergeCommand)
+	output := flags.String("o", "", "where to write the result, instead of ours; - for stdout")
+	err := parseFlags(flags, args)
+	if err != nil || flags.NArg() != 3 {
+		return errUsage
+	}
+
+	var versions [3]version
+	for i := range versions {
+		versions[i], err = readVersion(env, flags.Arg(i))
+		if err != nil {
+			return err
+		}
+	}
+
+	out, conflicts, err := mergeVersions(versions[0], versions[1], versions[2])
+	if err != nil {
+		return err
+	}
+
+	switch *output {
+	case "-":
+		_, err = env.stdout.Write(out)
+	case "":
+		err = os.WriteFile(flags.Arg(1), out, 0644)
+	default:
+		err = os.WriteFile(*output, out, 0644)
+	}
+	if err != nil {
+		return err
+	}
+
+	if conflicts > 0 {
+		return fmt.Errorf("%d conflicts", conflicts)
+	}
+	return nil
+}
+
+// A version is a document to merge, with the text it was parsed from.
+type version struct {
+	src   []byte
+	nodes []ahm.Node
+}
+
+func readVersion(env *env, name string) (version, error) {
+	r, err := openInput(env, name)
+	if err != nil {
+		return version{}, err
+	}
+	defer r.Close()
+
+	src, err := io.ReadAll(r)
+	if err != nil {
+		return version{}, err
+	}
+	nodes, err := ahm.Config{Source: name}.NewBytesParser(src).ParseAll()
+	return version{src, nodes}, err
+}
+
+// mergeVersions merges the changes made to the base in ours and theirs, keeping their text where it can.
+//
+// When only one side changed the document, the result is that side, byte for byte.
+// Otherwise, the top level nodes taken whole from a side keep their text, and only the merged ones are formatted.
+func mergeVersions(base, ours, theirs version) ([]byte, int, error) {
+	switch {
+	case sameNodes(theirs.nodes, base.nodes):
+		return ours.src, 0, nil
+	case sameNodes(ours.nodes, base.nodes):
+		return theirs.src, 0, nil
+	}
+
+	merged, conflicts := merge.Trees(base.nodes, ours.nodes, theirs.nodes)
+
+	out, err := splice(merged, ours, theirs)
+	if err != nil {
+		return nil, 0, err
+	}
+	reparsed, err := ahm.NewBytesParser(out).ParseAll()
+	if err != nil || !sameNodes(reparsed, merged) {
+		// The text kept from the sides does not fit together, so it is all formatted instead.
+		var buf bytes.Buffer
+		err = ahm.Format(&buf, merged)
+		return buf.Bytes(), conflicts, err
+	}
+	return out, conflicts, nil
+}
+
+// splice writes the merged nodes, copying the text of the ones that come from a side unchanged.
+func splice(merged []ahm.Node, sides ...version) ([]byte, error) {
+	texts := map[ahm.Node][]byte{}
+	for _, side := range sides {
+		for i, node := range side.nodes {
+			start, _ := ahm.SpanOf(node).Offsets()
+			end := len(side.src)
+			if i+1 < len(side.nodes) {
+				end, _ = ahm.SpanOf(side.nodes[i+1]).Offsets()
+			}
+			texts[node] = side.src[start:end]
+		}
+	}
+
+	var out bytes.Buffer
+	formattedLast := false
+	for _, node := range merged {
+		text, kept := texts[node]
+		if !kept || formattedLast {
+			separate(&out)
+		}
+		if kept {
+			out.Write(text)
+			formattedLast = false
+			continue
+		}
+		err := ahm.Format(&out, []ahm.Node{node})
+		if err != nil {
+			return nil, err
+		}
+		formattedLast = true
+	}
+	return out.Bytes(), nil
+}
+
+// separate ends the output so far with a blank line, so that the next node cannot run into the last one.
+func separate(out *bytes.Buffer) {
+	if out.Len() == 0 || bytes.HasSuffix(out.Bytes(), []byte("\n\n")) {
+		return
+	}
+	if !bytes.HasSuffix(out.Bytes(), []byte("\n")) {
+		out.WriteByte('\n')
+	}
+	out.WriteByte('\n')
+}
+
+func sameNodes(left, right []ahm.Node) bool {
+	if len(left) != len(right) {
+		return false
+	}
+	for i := range left {
+		if !ahm.Equals(left[i], right[i]) {
+			return false
+		}
+	}
+	return true
+}
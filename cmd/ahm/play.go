@@ -0,0 +1,177 @@
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/szabba/ahm/story"
+)
+
+var playCommand = &command{
+	name:    "play",
+	usage:   "play [-load state.json] file",
+	summary: "play an episode in the terminal",
+}
+
+func init() { playCommand.run = runPlay }
+
+const playHelp = `Type the number of an option to choose it, or:
+  values        show the values
+  save FILE     save the game
+  load FILE     load a saved game
+  quit          stop playing`
+
+func runPlay(env *env, args []string) error {
+	flags := newFlagSet(env, playCommand)
+	load := flags.String("load", "", "continue a game saved in a file")
+	err := parseFlags(flags, args)
+	if err != nil || flags.NArg() != 1 {
+		return errUsage
+	}
+
+	f, err := os.Open(flags.Arg(0))
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	ep, err := story.Load(f, flags.Arg(0))
+	if err != nil {
+		return err
+	}
+
+	game := story.NewGame(ep)
+	if *load != "" {
+		err = loadGame(game, *load)
+		if err != nil {
+			return err
+		}
+	}
+
+	p := &player{env: env, game: game, input: bufio.NewScanner(env.stdin)}
+	fmt.Fprintf(env.stdout, "%s\n\n%s\n", ep.Title, playHelp)
+	return p.play()
+}
+
+type player struct {
+	env   *env
+	game  *story.Game
+	input *bufio.Scanner
+}
+
+func (p *player) play() error {
+	for !p.game.Ended() {
+		p.showCard()
+		quit, err := p.turn()
+		if err != nil || quit {
+			return err
+		}
+	}
+	fmt.Fprintln(p.env.stdout, "\nThe end.")
+	return nil
+}
+
+func (p *player) showCard() {
+	card, _ := p.game.Card()
+	fmt.Fprintf(p.env.stdout, "\n[%s]\n%s\n", card.Name, card.Text)
+	for i, option := range card.Options {
+		fmt.Fprintf(p.env.stdout, "  %d) %s\n", i+1, option.Text)
+	}
+	if len(card.Options) == 0 {
+		fmt.Fprintln(p.env.stdout, "  (press enter to continue)")
+	}
+}
+
+// turn reads commands until one moves the game on.
+func (p *player) turn() (quit bool, err error) {
+	for {
+		fmt.Fprint(p.env.stdout, "> ")
+		if !p.input.Scan() {
+			return true, p.input.Err()
+		}
+		fields := strings.Fields(p.input.Text())
+		card, _ := p.game.Card()
+
+		switch {
+		case len(fields) == 0 && len(card.Options) == 0:
+			return false, p.game.Continue()
+
+		case len(fields) == 0:
+			continue
+
+		case fields[0] == "quit":
+			return true, nil
+
+		case fields[0] == "values":
+			p.showValues()
+
+		case fields[0] == "save" && len(fields) == 2:
+			p.report(saveGame(p.game, fields[1]))
+
+		case fields[0] == "load" && len(fields) == 2:
+			err := loadGame(p.game, fields[1])
+			p.report(err)
+			if err == nil {
+				return false, nil
+			}
+
+		default:
+			n, err := strconv.Atoi(fields[0])
+			if err != nil {
+				fmt.Fprintln(p.env.stdout, playHelp)
+				continue
+			}
+			err = p.game.Choose(n - 1)
+			if err == nil {
+				return false, nil
+			}
+			p.report(err)
+		}
+	}
+}
+
+func (p *player) showValues() {
+	values := p.game.State().Values
+	var names []string
+	for name := range values {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		fmt.Fprintf(p.env.stdout, "  %s = %d\n", name, values[name])
+	}
+}
+
+func (p *player) report(err error) {
+	if err != nil {
+		fmt.Fprintf(p.env.stdout, "error: %s\n", err)
+	}
+}
+
+func saveGame(game *story.Game, name string) error {
+	f, err := os.Create(name)
+	if err != nil {
+		return err
+	}
+	err = game.Save(f)
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	return err
+}
+
+func loadGame(game *story.Game, name string) error {
+	f, err := os.Open(name)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	return game.Load(f)
+}
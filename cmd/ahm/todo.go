@@ -0,0 +1,136 @@
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/szabba/ahm/todo"
+)
+
+var todoCommand = &command{
+	name: "todo",
+	usage: "todo list [-status open|done|all] [-tag tag] [-due date] [-grep text] file\n" +
+		"       ahm todo done file id...\n" +
+		"       ahm todo reopen file id...",
+	summary: "list, complete and reopen the items of a TODO list",
+}
+
+func init() { todoCommand.run = runTodo }
+
+func runTodo(env *env, args []string) error {
+	if len(args) == 0 {
+		return errUsage
+	}
+	switch args[0] {
+	case "list":
+		return runTodoList(env, args[1:])
+	case "done":
+		return runTodoSetStatus(env, args[1:], (*todo.List).Complete)
+	case "reopen":
+		return runTodoSetStatus(env, args[1:], (*todo.List).Reopen)
+	default:
+		return errUsage
+	}
+}
+
+func runTodoList(env *env, args []string) error {
+	flags := newFlagSet(env, todoCommand)
+	status := flags.String("status", "open", "show only the open, done or all items")
+	tag := flags.String("tag", "", "show only the items with a tag")
+	due := flags.String("due", "", "show only the items due on the date or earlier")
+	grep := flags.String("grep", "", "show only the items containing the text")
+	err := parseFlags(flags, args)
+	if err != nil || flags.NArg() != 1 {
+		return errUsage
+	}
+
+	var filters []todo.Filter
+	switch *status {
+	case "open":
+		filters = append(filters, todo.WithStatus(todo.Open))
+	case "done":
+		filters = append(filters, todo.WithStatus(todo.Done))
+	case "all":
+	default:
+		return errUsage
+	}
+	if *tag != "" {
+		filters = append(filters, todo.WithTag(*tag))
+	}
+	if *due != "" {
+		day, err := time.Parse(todo.DateLayout, *due)
+		if err != nil {
+			return fmt.Errorf("bad date %q, wanted one like %s", *due, todo.DateLayout)
+		}
+		filters = append(filters, todo.DueBy(day))
+	}
+	if *grep != "" {
+		filters = append(filters, todo.Containing(*grep))
+	}
+
+	list, err := loadTodos(flags.Arg(0))
+	if err != nil {
+		return err
+	}
+	for _, item := range list.Filter(filters...) {
+		printItem(env.stdout, item)
+	}
+	return nil
+}
+
+func printItem(w io.Writer, item *todo.Item) {
+	box := "[ ]"
+	if item.Status == todo.Done {
+		box = "[x]"
+	}
+	line := []string{fmt.Sprintf("%-6s %s %s", item.ID, box, item.Text)}
+	if !item.Due.IsZero() {
+		line = append(line, "due="+item.Due.Format(todo.DateLayout))
+	}
+	for _, tag := range item.Tags {
+		line = append(line, "#"+tag)
+	}
+	fmt.Fprintln(w, strings.Join(line, " "))
+}
+
+func runTodoSetStatus(env *env, args []string, set func(*todo.List, *todo.Item)) error {
+	if len(args) < 2 {
+		return errUsage
+	}
+	name, ids := args[0], args[1:]
+
+	list, err := loadTodos(name)
+	if err != nil {
+		return err
+	}
+	for _, id := range ids {
+		item, ok := list.Find(id)
+		if !ok {
+			return fmt.Errorf("no item with ID %s", id)
+		}
+		set(list, item)
+		printItem(env.stdout, item)
+	}
+
+	info, err := os.Stat(name)
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(name, list.Bytes(), info.Mode())
+}
+
+func loadTodos(name string) (*todo.List, error) {
+	f, err := os.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return todo.Load(f, name)
+}
@@ -0,0 +1,123 @@
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+// Command ahmgen compiles an AHM document into Go source.
+//
+// It is meant to be run by go generate, as in
+//
+//	//go:generate ahmgen -schema cards.json cards.ahm
+//
+// which writes the document to cards_ahm.go, as a variable named Cards.
+package main
+
+import (
+	"bytes"
+	"flag"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"unicode"
+
+	"github.com/szabba/ahm"
+	"github.com/szabba/ahm/ahmgen"
+)
+
+var (
+	outputFileName string
+	packageName    string
+	varName        string
+	schemaFileName string
+	structuredArgs bool
+)
+
+func main() {
+	flag.StringVar(&outputFileName, "out", "", "name for the output file (computed if \"\", stdout if \"-\")")
+	flag.StringVar(&packageName, "pkg", os.Getenv("GOPACKAGE"), "package of the generated code")
+	flag.StringVar(&varName, "var", "", "name of the variable holding the document (computed from the file name if \"\")")
+	flag.StringVar(&schemaFileName, "schema", "", "JSON schema describing the types to generate (ahm nodes if \"\")")
+	flag.BoolVar(&structuredArgs, "args", false, "if true, split proc arguments into Args and Attrs")
+	flag.Parse()
+
+	if flag.NArg() != 1 {
+		log.Fatalf("one argument wanted: the document to compile")
+	}
+	input := flag.Arg(0)
+
+	if packageName == "" {
+		log.Fatalf("-pkg not given and environment variable GOPACKAGE missing or empty")
+	}
+	config := ahmgen.Config{PackageName: packageName, VarName: varName, Source: filepath.Base(input)}
+	if config.VarName == "" {
+		config.VarName = exportedName(input)
+	}
+	if schemaFileName != "" {
+		config.Schema = readSchema(schemaFileName)
+	}
+
+	src, err := os.ReadFile(input)
+	if err != nil {
+		log.Fatal(err)
+	}
+	nodes, err := ahm.Config{Source: input, StructuredArgs: structuredArgs}.NewBytesParser(src).ParseAll()
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	var buf bytes.Buffer
+	err = config.Generate(&buf, nodes)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	var out io.Writer = os.Stdout
+	if outputFileName != "-" {
+		if outputFileName == "" {
+			outputFileName = strings.TrimSuffix(filepath.Base(input), filepath.Ext(input)) + "_ahm.go"
+		}
+		file, err := os.Create(outputFileName)
+		if err != nil {
+			log.Fatal(err)
+		}
+		defer file.Close()
+		out = file
+	}
+
+	_, err = io.Copy(out, &buf)
+	if err != nil {
+		log.Fatal(err)
+	}
+}
+
+func readSchema(name string) ahmgen.Schema {
+	file, err := os.Open(name)
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer file.Close()
+
+	schema, err := ahmgen.ReadSchema(file)
+	if err != nil {
+		log.Fatal(err)
+	}
+	return schema
+}
+
+// exportedName turns a file name like my-cards.ahm into MyCards.
+func exportedName(path string) string {
+	base := strings.TrimSuffix(filepath.Base(path), filepath.Ext(path))
+	words := strings.FieldsFunc(base, func(r rune) bool { return !unicode.IsLetter(r) && !unicode.IsDigit(r) })
+
+	var name strings.Builder
+	for _, word := range words {
+		runes := []rune(word)
+		name.WriteRune(unicode.ToUpper(runes[0]))
+		name.WriteString(string(runes[1:]))
+	}
+	if name.Len() == 0 || !unicode.IsLetter([]rune(name.String())[0]) {
+		return "Doc" + name.String()
+	}
+	return name.String()
+}
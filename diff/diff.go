@@ -0,0 +1,397 @@
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+// Package diff compares documents as trees.
+//
+// Children that are the same in both trees anchor the comparison.
+// Between the anchors, procs with the same name are matched up and compared in turn,
+// as are the pieces of text.
+// What is left over has been deleted or inserted, unless an equal node was inserted or deleted elsewhere,
+// in which case it has been moved.
+package diff
+
+import (
+	"crypto/sha256"
+	"fmt"
+	"hash"
+	"io"
+	"strconv"
+	"strings"
+
+	"github.com/szabba/ahm"
+)
+
+type Kind int
+
+const (
+	Insert Kind = iota
+	Delete
+	Move
+	// Modify is a change to the title of a proc or to a piece of text.
+	// The changes to the children of a proc are reported separately.
+	Modify
+)
+
+func (kind Kind) String() string {
+	switch kind {
+	case Insert:
+		return "insert"
+	case Delete:
+		return "delete"
+	case Move:
+		return "move"
+	case Modify:
+		return "modify"
+	default:
+		return fmt.Sprintf("Kind(%d)", int(kind))
+	}
+}
+
+// A Path locates a node by the indices of it and its ancestors among their siblings.
+type Path []int
+
+func (path Path) String() string {
+	var out strings.Builder
+	for _, i := range path {
+		out.WriteString("/" + strconv.Itoa(i))
+	}
+	if out.Len() == 0 {
+		return "/"
+	}
+	return out.String()
+}
+
+func (path Path) child(i int) Path {
+	return append(path[:len(path):len(path)], i)
+}
+
+// A Change is a difference between two trees.
+//
+// Old and OldPath are nil for an insert, New and NewPath for a delete.
+type Change struct {
+	Kind     Kind
+	Old, New ahm.Node
+	OldPath  Path
+	NewPath  Path
+}
+
+func (change Change) OldSpan() ahm.Span { return spanOf(change.Old) }
+
+func (change Change) NewSpan() ahm.Span { return spanOf(change.New) }
+
+func spanOf(node ahm.Node) ahm.Span {
+	if node == nil {
+		return ahm.Span{}
+	}
+	return ahm.SpanOf(node)
+}
+
+func (change Change) String() string {
+	switch change.Kind {
+	case Insert:
+		return fmt.Sprintf("insert %s at %s (%s)", Describe(change.New), change.NewPath, change.NewSpan())
+	case Delete:
+		return fmt.Sprintf("delete %s at %s (%s)", Describe(change.Old), change.OldPath, change.OldSpan())
+	default:
+		return fmt.Sprintf("%s %s at %s (%s) to %s at %s (%s)",
+			change.Kind,
+			Describe(change.Old), change.OldPath, change.OldSpan(),
+			Describe(change.New), change.NewPath, change.NewSpan())
+	}
+}
+
+// Describe summarizes a node in a line.
+func Describe(node ahm.Node) string {
+	const max = 40
+	var desc string
+	switch node := node.(type) {
+	case *ahm.Proc:
+		desc = "@" + node.Name
+		if node.Title != "" {
+			desc += " " + node.Title
+		}
+	case *ahm.Text:
+		desc = strconv.Quote(node.Text)
+	case *ahm.Paragraph:
+		desc = strconv.Quote(proseText(node))
+	}
+	if len([]rune(desc)) > max {
+		desc = string([]rune(desc)[:max-3]) + "..."
+	}
+	return desc
+}
+
+// Trees lists the changes that turn the old nodes into the new ones.
+func Trees(old, updated []ahm.Node) []Change {
+	d := &differ{}
+	d.children(old, updated, Path{}, Path{})
+	d.findMoves()
+	return d.changes
+}
+
+type differ struct {
+	changes []Change
+}
+
+// children compares the children of matching nodes.
+func (d *differ) children(old, updated []ahm.Node, oldPath, newPath Path) {
+	pairs := Match(old, updated)
+	newMatched := make([]bool, len(updated))
+	oldMatch := make([]int, len(old))
+	for i := range oldMatch {
+		oldMatch[i] = -1
+	}
+	for _, pair := range pairs {
+		oldMatch[pair[0]] = pair[1]
+		newMatched[pair[1]] = true
+	}
+
+	for i, before := range old {
+		if j := oldMatch[i]; j >= 0 {
+			d.pair(before, updated[j], oldPath.child(i), newPath.child(j))
+		} else {
+			d.changes = append(d.changes, Change{Kind: Delete, Old: before, OldPath: oldPath.child(i)})
+		}
+	}
+	for j, after := range updated {
+		if !newMatched[j] {
+			d.changes = append(d.changes, Change{Kind: Insert, New: after, NewPath: newPath.child(j)})
+		}
+	}
+}
+
+// Match pairs up the old nodes with the new ones they have become, if any.
+// It returns the index pairs, ordered by the old index.
+//
+// Equal nodes that appear in the same order in both lists are paired first.
+// Between them, procs with the same name are paired, and so are pieces of text.
+func Match(old, updated []ahm.Node) [][2]int {
+	var pairs [][2]int
+	i, j := 0, 0
+	for _, anchor := range append(commonSubsequence(old, updated), [2]int{len(old), len(updated)}) {
+		pairs = append(pairs, matchGap(old[i:anchor[0]], updated[j:anchor[1]], i, j)...)
+		if anchor[0] < len(old) {
+			pairs = append(pairs, anchor)
+		}
+		i, j = anchor[0]+1, anchor[1]+1
+	}
+	return pairs
+}
+
+// matchGap pairs up the nodes between two anchors.
+// The offsets locate the nodes among all the children.
+func matchGap(old, updated []ahm.Node, oldOffset, newOffset int) [][2]int {
+	var pairs [][2]int
+	matched := make([]bool, len(updated))
+	for i, before := range old {
+		j := matchIn(before, updated, matched)
+		if j >= 0 {
+			matched[j] = true
+			pairs = append(pairs, [2]int{oldOffset + i, newOffset + j})
+		}
+	}
+	return pairs
+}
+
+// matchIn finds the first unmatched node a node could have turned into.
+// Procs with the same name and title are preferred over ones with the same name only.
+func matchIn(node ahm.Node, candidates []ahm.Node, matched []bool) int {
+	fallback := -1
+	for j, candidate := range candidates {
+		if matched[j] {
+			continue
+		}
+		switch node := node.(type) {
+		case *ahm.Proc:
+			other, ok := candidate.(*ahm.Proc)
+			if ok && other.Name == node.Name && other.Title == node.Title {
+				return j
+			}
+			if ok && other.Name == node.Name && fallback < 0 {
+				fallback = j
+			}
+		case *ahm.Text, *ahm.Paragraph:
+			if isProse(candidate) && fallback < 0 {
+				fallback = j
+			}
+		}
+	}
+	return fallback
+}
+
+// pair compares two nodes that have been matched up.
+func (d *differ) pair(old, updated ahm.Node, oldPath, newPath Path) {
+	oldProc, ok := old.(*ahm.Proc)
+	if !ok {
+		if !ahm.Equals(old, updated) {
+			d.changes = append(d.changes, Change{Kind: Modify, Old: old, New: updated, OldPath: oldPath, NewPath: newPath})
+		}
+		return
+	}
+
+	newProc := updated.(*ahm.Proc)
+	if oldProc.Title != newProc.Title {
+		d.changes = append(d.changes, Change{Kind: Modify, Old: old, New: updated, OldPath: oldPath, NewPath: newPath})
+	}
+	d.children(oldProc.Children, newProc.Children, oldPath, newPath)
+}
+
+// findMoves turns deletes and inserts of equal nodes into moves.
+func (d *differ) findMoves() {
+	used := make([]bool, len(d.changes))
+	var changes []Change
+	for i, change := range d.changes {
+		if used[i] {
+			continue
+		}
+		if change.Kind == Delete {
+			if j := d.insertOf(change.Old, used); j >= 0 {
+				used[j] = true
+				change = Change{Kind: Move, Old: change.Old, New: d.changes[j].New, OldPath: change.OldPath, NewPath: d.changes[j].NewPath}
+			}
+		}
+		changes = append(changes, change)
+	}
+	d.changes = changes
+}
+
+func (d *differ) insertOf(node ahm.Node, used []bool) int {
+	for j, change := range d.changes {
+		if !used[j] && change.Kind == Insert && ahm.Equals(change.New, node) {
+			return j
+		}
+	}
+	return -1
+}
+
+// commonSubsequence finds the longest sequence of equal nodes that appear in the same order in both lists.
+// It returns the index pairs of the nodes.
+func commonSubsequence(old, updated []ahm.Node) [][2]int {
+	oldKeys, updatedKeys := keysOf(old), keysOf(updated)
+
+	lengths := make([][]int, len(old)+1)
+	for i := range lengths {
+		lengths[i] = make([]int, len(updated)+1)
+	}
+	for i := len(old) - 1; i >= 0; i-- {
+		for j := len(updated) - 1; j >= 0; j-- {
+			if oldKeys[i] == updatedKeys[j] {
+				lengths[i][j] = lengths[i+1][j+1] + 1
+			} else if lengths[i+1][j] >= lengths[i][j+1] {
+				lengths[i][j] = lengths[i+1][j]
+			} else {
+				lengths[i][j] = lengths[i][j+1]
+			}
+		}
+	}
+
+	var pairs [][2]int
+	for i, j := 0, 0; i < len(old) && j < len(updated); {
+		switch {
+		case oldKeys[i] == updatedKeys[j]:
+			pairs = append(pairs, [2]int{i, j})
+			i, j = i+1, j+1
+		case lengths[i+1][j] >= lengths[i][j+1]:
+			i++
+		default:
+			j++
+		}
+	}
+	return pairs
+}
+
+// A key is a hash of the content of a node.
+// Nodes have equal keys when ahm.Equals considers them equal.
+type key [sha256.Size]byte
+
+func keysOf(nodes []ahm.Node) []key {
+	keys := make([]key, len(nodes))
+	for i, node := range nodes {
+		h := sha256.New()
+		hashNode(h, node)
+		h.Sum(keys[i][:0])
+	}
+	return keys
+}
+
+func hashNode(h hash.Hash, node ahm.Node) {
+	switch node := node.(type) {
+	case nil:
+		io.WriteString(h, "0")
+
+	case *ahm.Proc:
+		if node == nil {
+			io.WriteString(h, "p")
+			return
+		}
+		io.WriteString(h, "P")
+		hashString(h, node.Name)
+		hashString(h, node.Title)
+		fmt.Fprintf(h, "%d,", len(node.Args))
+		for _, arg := range node.Args {
+			hashString(h, arg.Value)
+			fmt.Fprintf(h, "%t,", arg.Quoted)
+		}
+		fmt.Fprintf(h, "%d,", len(node.Attrs))
+		for _, attr := range node.Attrs {
+			hashString(h, attr.Key)
+			hashString(h, attr.Value)
+			fmt.Fprintf(h, "%t,", attr.Quoted)
+		}
+		hashNodes(h, node.Children)
+
+	case *ahm.Text:
+		if node == nil {
+			io.WriteString(h, "t")
+			return
+		}
+		io.WriteString(h, "T")
+		hashString(h, node.Text)
+
+	case *ahm.Paragraph:
+		if node == nil {
+			io.WriteString(h, "g")
+			return
+		}
+		io.WriteString(h, "G")
+		hashNodes(h, node.Children)
+
+	default:
+		fmt.Fprintf(h, "?%#v", node)
+	}
+}
+
+func hashNodes(h hash.Hash, nodes []ahm.Node) {
+	fmt.Fprintf(h, "%d,", len(nodes))
+	for _, node := range nodes {
+		hashNode(h, node)
+	}
+}
+
+// hashString writes the length of a string before it, so that adjacent strings cannot run together.
+func hashString(h hash.Hash, s string) {
+	fmt.Fprintf(h, "%d:%s", len(s), s)
+}
+
+func isProse(node ahm.Node) bool {
+	switch node.(type) {
+	case *ahm.Text, *ahm.Paragraph:
+		return true
+	default:
+		return false
+	}
+}
+
+func proseText(para *ahm.Paragraph) string {
+	var out strings.Builder
+	for _, child := range para.Children {
+		switch child := child.(type) {
+		case *ahm.Text:
+			out.WriteString(child.Text)
+		case *ahm.Proc:
+			out.WriteString("@" + child.Name + "{" + child.Title + "}")
+		}
+	}
+	return out.String()
+}
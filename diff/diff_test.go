@@ -0,0 +1,116 @@
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package diff_test
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/szabba/ahm"
+	"github.com/szabba/ahm/ahmtest"
+	"github.com/szabba/ahm/assert"
+	"github.com/szabba/ahm/diff"
+)
+
+func TestEqualTreesHaveNoChanges(t *testing.T) {
+	// given
+	old := ahmtest.Parse(t, ahm.Config{Source: "a.ahm"}, "@CARD x", "  Text.")
+	updated := ahmtest.Parse(t, ahm.Config{Source: "b.ahm"}, "@CARD x", "", "  Text.")
+
+	// when
+	changes := diff.Trees(old, updated)
+
+	// then
+	assert.That(len(changes) == 0, t.Errorf, "got changes %v", changes)
+}
+
+func TestEqualNodesAnchorTheComparisonWhateverTheirSpans(t *testing.T) {
+	// given
+	old := ahmtest.Parse(t, ahm.Config{Source: "a.ahm"}, "@A", "@B", "@C")
+	updated := ahmtest.Parse(t, ahm.Config{Source: "b.ahm"}, "@C", "", "@A", "", "@B")
+
+	// when
+	changes := diff.Trees(old, updated)
+
+	// then
+	assert.That(len(changes) == 1, t.Fatalf, "got changes %v, wanted one", changes)
+	assert.That(changes[0].Kind == diff.Move, t.Errorf, "got %v, wanted a move", changes[0])
+	assert.That(changes[0].Old.(*ahm.Proc).Name == "C", t.Errorf, "got %v, wanted C to move", changes[0])
+}
+
+func TestInsertDeleteAndModify(t *testing.T) {
+	// given
+	old := ahmtest.Parse(t, ahm.Config{Source: "a.ahm"},
+		"@CARD one",
+		"  Old text.",
+		"  @TAG gone",
+		"@CARD two")
+	updated := ahmtest.Parse(t, ahm.Config{Source: "b.ahm"},
+		"@CARD one",
+		"  New text.",
+		"@CARD three",
+		"@CARD four")
+
+	// when
+	changes := diff.Trees(old, updated)
+
+	// then
+	want := []summary{
+		{diff.Modify, "/0/0", "/0/0"},
+		{diff.Delete, "/0/1", ""},
+		{diff.Modify, "/1", "/1"},
+		{diff.Insert, "", "/2"},
+	}
+	assert.That(reflect.DeepEqual(summarize(changes), want), t.Errorf, "got %v, wanted %v", summarize(changes), want)
+}
+
+func TestOptionsMovingBetweenChoicesAreMoves(t *testing.T) {
+	// given
+	old := ahmtest.Parse(t, ahm.Config{Source: "a.ahm"},
+		"@CHOICE",
+		"  @OPTION \"Stay\"",
+		"  @OPTION \"Leave\"",
+		"    @EFFECT",
+		"      @GOTO away",
+		"@CHOICE",
+		"  @OPTION \"Sleep\"")
+	updated := ahmtest.Parse(t, ahm.Config{Source: "b.ahm"},
+		"@CHOICE",
+		"  @OPTION \"Stay\"",
+		"@CHOICE",
+		"  @OPTION \"Sleep\"",
+		"  @OPTION \"Leave\"",
+		"    @EFFECT",
+		"      @GOTO away")
+
+	// when
+	changes := diff.Trees(old, updated)
+
+	// then
+	want := []summary{{diff.Move, "/0/1", "/1/1"}}
+	assert.That(reflect.DeepEqual(summarize(changes), want), t.Fatalf, "got %v, wanted %v", summarize(changes), want)
+	assert.That(changes[0].OldSpan().Source == "a.ahm", t.Errorf, "got old span %v", changes[0].OldSpan())
+	assert.That(changes[0].NewSpan().StartsAt().Line() == 5, t.Errorf, "got updated span %v", changes[0].NewSpan())
+}
+
+type summary struct {
+	kind             diff.Kind
+	oldPath, newPath string
+}
+
+func summarize(changes []diff.Change) []summary {
+	var out []summary
+	for _, change := range changes {
+		s := summary{kind: change.Kind}
+		if change.OldPath != nil {
+			s.oldPath = change.OldPath.String()
+		}
+		if change.NewPath != nil {
+			s.newPath = change.NewPath.String()
+		}
+		out = append(out, s)
+	}
+	return out
+}
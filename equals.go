@@ -6,6 +6,77 @@ package ahm
 
 import "reflect"
 
+// Equals reports whether two nodes have the same content.
+//
+// Spans are deliberately not compared, so a node is equal to its copy from a reformatted source.
+// Lists of children, arguments and attributes are compared element by element,
+// so nil and empty lists are equal.
 func Equals(left, right Node) bool {
-	return reflect.DeepEqual(left, right)
+	switch left := left.(type) {
+	case *Proc:
+		right, ok := right.(*Proc)
+		if !ok || left == nil || right == nil {
+			return ok && left == right
+		}
+		return left.Name == right.Name &&
+			left.Title == right.Title &&
+			argsEqual(left.Args, right.Args) &&
+			attrsEqual(left.Attrs, right.Attrs) &&
+			allEqual(left.Children, right.Children)
+
+	case *Text:
+		right, ok := right.(*Text)
+		if !ok || left == nil || right == nil {
+			return ok && left == right
+		}
+		return left.Text == right.Text
+
+	case *Paragraph:
+		right, ok := right.(*Paragraph)
+		if !ok || left == nil || right == nil {
+			return ok && left == right
+		}
+		return allEqual(left.Children, right.Children)
+
+	default:
+		return reflect.DeepEqual(left, right)
+	}
+}
+
+func allEqual(left, right []Node) bool {
+	if len(left) != len(right) {
+		return false
+	}
+	for i := range left {
+		if !Equals(left[i], right[i]) {
+			return false
+		}
+	}
+	return true
+}
+
+func argsEqual(left, right []Arg) bool {
+	if len(left) != len(right) {
+		return false
+	}
+	for i := range left {
+		l, r := left[i], right[i]
+		if l.Value != r.Value || l.Quoted != r.Quoted {
+			return false
+		}
+	}
+	return true
+}
+
+func attrsEqual(left, right []Attr) bool {
+	if len(left) != len(right) {
+		return false
+	}
+	for i := range left {
+		l, r := left[i], right[i]
+		if l.Key != r.Key || l.Value != r.Value || l.Quoted != r.Quoted {
+			return false
+		}
+	}
+	return true
 }
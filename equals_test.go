@@ -9,6 +9,7 @@ import (
 
 	"github.com/kr/pretty"
 	"github.com/szabba/ahm/assert"
+	"github.com/szabba/ahm/position"
 )
 
 func TestEquals(t *testing.T) {
@@ -17,34 +18,62 @@ func TestEquals(t *testing.T) {
 		equal       bool
 	}{
 		"twoNils":    {equal: true},
-		"nilAndText": {left: &Text{"abcd"}},
-		"nilAndProc": {left: &Proc{"name", "title", []Node{}}},
+		"nilAndText": {left: &Text{Text: "abcd"}},
+		"nilAndProc": {left: &Proc{Name: "name", Title: "title", Children: []Node{}}},
 		"twoTexts": {
-			left:  &Text{"abcd"},
-			right: &Text{"abcd"},
+			left:  &Text{Text: "abcd"},
+			right: &Text{Text: "abcd"},
 			equal: true,
 		},
 		"twoFlatProcs": {
-			left:  &Proc{"name", "title", nil},
-			right: &Proc{"name", "title", nil},
+			left:  &Proc{Name: "name", Title: "title"},
+			right: &Proc{Name: "name", Title: "title"},
 			equal: true,
 		},
 		"twoProcsWithChildren": {
-			left:  &Proc{"name", "title", []Node{&Text{"a child"}}},
-			right: &Proc{"name", "title", []Node{&Text{"a child"}}},
+			left:  &Proc{Name: "name", Title: "title", Children: []Node{&Text{Text: "a child"}}},
+			right: &Proc{Name: "name", Title: "title", Children: []Node{&Text{Text: "a child"}}},
 			equal: true,
 		},
 		"procsWithDifferentNames": {
-			left:  &Proc{"name-1", "title", nil},
-			right: &Proc{"name-2", "title", nil},
+			left:  &Proc{Name: "name-1", Title: "title"},
+			right: &Proc{Name: "name-2", Title: "title"},
 		},
 		"procsWithDifferentTitles": {
-			left:  &Proc{"name", "title-1", nil},
-			right: &Proc{"name", "title-2", nil},
+			left:  &Proc{Name: "name", Title: "title-1"},
+			right: &Proc{Name: "name", Title: "title-2"},
 		},
 		"twoProcsWithDifferentChildren": {
-			left:  &Proc{"name", "title", []Node{&Text{"first child"}}},
-			right: &Proc{"name", "title", []Node{&Text{"second child"}}},
+			left:  &Proc{Name: "name", Title: "title", Children: []Node{&Text{Text: "first child"}}},
+			right: &Proc{Name: "name", Title: "title", Children: []Node{&Text{Text: "second child"}}},
+		},
+		"textsAtDifferentSpans": {
+			left:  &Text{Text: "abcd", Span: position.SpanFromTo(position.PositionAt(1, 1, 0), position.PositionAt(1, 5, 4)).In("a.ahm")},
+			right: &Text{Text: "abcd", Span: position.SpanFromTo(position.PositionAt(3, 3, 9), position.PositionAt(3, 7, 13)).In("b.ahm")},
+			equal: true,
+		},
+		"procsWithArgsAtDifferentSpans": {
+			left:  &Proc{Name: "name", Args: []Arg{{Value: "a", Span: position.PositionAt(1, 7, 6).StartSpan().In("a.ahm")}}},
+			right: &Proc{Name: "name", Args: []Arg{{Value: "a"}}},
+			equal: true,
+		},
+		"procsWithDifferentArgs": {
+			left:  &Proc{Name: "name", Args: []Arg{{Value: "a"}}},
+			right: &Proc{Name: "name", Args: []Arg{{Value: "a", Quoted: true}}},
+		},
+		"procsWithDifferentAttrs": {
+			left:  &Proc{Name: "name", Attrs: []Attr{{Key: "k", Value: "1"}}},
+			right: &Proc{Name: "name", Attrs: []Attr{{Key: "k", Value: "2"}}},
+		},
+		"procsWithNilAndEmptyChildren": {
+			left:  &Proc{Name: "name", Children: []Node{}},
+			right: &Proc{Name: "name"},
+			equal: true,
+		},
+		"nilProcAndNil": {left: (*Proc)(nil)},
+		"paragraphAndText": {
+			left:  &Paragraph{Children: []Node{&Text{Text: "abcd"}}},
+			right: &Text{Text: "abcd"},
 		},
 	}
 
@@ -0,0 +1,173 @@
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package eval
+
+import (
+	"io/fs"
+	"time"
+
+	"github.com/szabba/ahm"
+	"github.com/szabba/ahm/ahmerr"
+	"github.com/szabba/ahm/ns"
+)
+
+// A Context is what a handler knows about the place in the document it is evaluating.
+type Context struct {
+	run   *run
+	scope *ns.Scope
+
+	parent     *Context
+	key, value interface{}
+}
+
+// run is the state shared by all the contexts of a single evaluation.
+type run struct {
+	engine *Engine
+	diags  ahmerr.Diagnostics
+}
+
+func (engine *Engine) newContext() *Context {
+	return &Context{
+		run:   &run{engine: engine},
+		scope: engine.packages.Scope(),
+	}
+}
+
+func (ctx *Context) Now() time.Time { return ctx.run.engine.now() }
+
+func (ctx *Context) FS() fs.FS { return ctx.run.engine.FS }
+
+// WithValue returns a context in which key is associated with value.
+func (ctx *Context) WithValue(key, value interface{}) *Context {
+	return &Context{run: ctx.run, scope: ctx.scope, parent: ctx, key: key, value: value}
+}
+
+// Value returns the value most recently associated with key, or nil.
+func (ctx *Context) Value(key interface{}) interface{} {
+	for ; ctx != nil; ctx = ctx.parent {
+		if ctx.parent != nil && ctx.key == key {
+			return ctx.value
+		}
+	}
+	return nil
+}
+
+// Report records a diagnostic without stopping the evaluation.
+//
+// When the context is within an included file, the diagnostic is marked as such.
+func (ctx *Context) Report(diag *ahmerr.Diagnostic) {
+	if diag.IncludedFrom == nil {
+		diag.IncludedFrom = ctx.includedFrom()
+	}
+	ctx.run.diags = append(ctx.run.diags, diag)
+}
+
+// Eval evaluates a list of sibling nodes.
+func (ctx *Context) Eval(nodes []ahm.Node) []ahm.Node {
+	var out []ahm.Node
+	for _, node := range nodes {
+		if ns.IsImport(node) {
+			ctx = ctx.importing(node.(*ahm.Proc))
+			continue
+		}
+		out = append(out, ctx.evalNode(node)...)
+	}
+	return out
+}
+
+func (ctx *Context) importing(proc *ahm.Proc) *Context {
+	scope, err := ctx.scope.Import(proc)
+	ctx.reportErr(proc, err)
+	if scope == ctx.scope {
+		return ctx
+	}
+	imported := *ctx
+	imported.scope = scope
+	return &imported
+}
+
+func (ctx *Context) evalNode(node ahm.Node) []ahm.Node {
+	switch node := node.(type) {
+	case *ahm.Proc:
+		return ctx.evalProc(node)
+	case *ahm.Paragraph:
+		return []ahm.Node{ctx.evalParagraph(node)}
+	default:
+		return []ahm.Node{node}
+	}
+}
+
+func (ctx *Context) evalProc(proc *ahm.Proc) []ahm.Node {
+	handler, err := ctx.run.engine.handlerFor(ctx.scope, proc)
+	if err != nil {
+		ctx.reportErr(proc, err)
+		return []ahm.Node{proc}
+	}
+
+	if handler == nil {
+		return ctx.evalUnknown(proc)
+	}
+
+	out, err := handler.Eval(ctx, proc)
+	if err != nil {
+		ctx.reportErr(proc, err)
+		return []ahm.Node{proc}
+	}
+	return out
+}
+
+func (ctx *Context) evalUnknown(proc *ahm.Proc) []ahm.Node {
+	policy := ctx.run.engine.Unknown
+	switch policy {
+	case DropUnknown:
+		return nil
+	case ReportUnknown:
+		ctx.Report(ahmerr.Diagnosef(proc.Span, "no handler for proc %s", proc.Name))
+	}
+
+	kept := *proc
+	kept.Children = ctx.Eval(proc.Children)
+	return []ahm.Node{&kept}
+}
+
+func (ctx *Context) evalParagraph(para *ahm.Paragraph) ahm.Node {
+	evaluated := *para
+	evaluated.Children = nil
+	for _, node := range ctx.Eval(para.Children) {
+		evaluated.Children = appendMergingText(evaluated.Children, node)
+	}
+	return &evaluated
+}
+
+// appendMergingText appends a node to a paragraph's children, joining it with preceding text.
+func appendMergingText(nodes []ahm.Node, node ahm.Node) []ahm.Node {
+	text, ok := node.(*ahm.Text)
+	if !ok || len(nodes) == 0 {
+		return append(nodes, node)
+	}
+	prev, ok := nodes[len(nodes)-1].(*ahm.Text)
+	if !ok {
+		return append(nodes, node)
+	}
+	merged := &ahm.Text{Text: prev.Text + text.Text, Span: prev.Span}
+	return append(nodes[:len(nodes)-1], merged)
+}
+
+func (ctx *Context) reportErr(proc *ahm.Proc, err error) {
+	if err == nil {
+		return
+	}
+	if diag, ok := err.(*ahmerr.Diagnostic); ok {
+		ctx.Report(diag)
+		return
+	}
+	if diags, ok := err.(ahmerr.Diagnostics); ok {
+		for _, diag := range diags {
+			ctx.Report(diag)
+		}
+		return
+	}
+	ctx.Report(ahmerr.Diagnosef(proc.Span, "%s: %s", proc.Name, err))
+}
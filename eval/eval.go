@@ -0,0 +1,128 @@
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+// Package eval replaces procs in a document with the nodes that Go handlers compute for them.
+//
+// Handlers receive procs with their children still unevaluated.
+// They can evaluate the children with Context.Eval, possibly after adding values to the context
+// for the handlers of the children to use.
+// The nodes a handler returns are not evaluated any further.
+package eval
+
+import (
+	"io/fs"
+	"time"
+
+	"github.com/szabba/ahm"
+	"github.com/szabba/ahm/ahmerr"
+	"github.com/szabba/ahm/ns"
+)
+
+type Handler interface {
+	Eval(ctx *Context, proc *ahm.Proc) ([]ahm.Node, error)
+}
+
+type HandlerFunc func(ctx *Context, proc *ahm.Proc) ([]ahm.Node, error)
+
+func (f HandlerFunc) Eval(ctx *Context, proc *ahm.Proc) ([]ahm.Node, error) { return f(ctx, proc) }
+
+// A Package maps local proc names to handlers.
+// Packages can be registered with an engine under a namespace.
+type Package map[string]Handler
+
+func (pkg Package) Has(local string) bool {
+	_, ok := pkg[local]
+	return ok
+}
+
+// UnknownPolicy decides what happens to procs that have no handler.
+type UnknownPolicy int
+
+const (
+	// KeepUnknown leaves the proc in place, with its children evaluated.
+	KeepUnknown UnknownPolicy = iota
+	// DropUnknown removes the proc, together with its children.
+	DropUnknown
+	// ReportUnknown keeps the proc like KeepUnknown does, but also reports a diagnostic.
+	ReportUnknown
+)
+
+type Engine struct {
+	// Unknown is the policy for procs with no handler.
+	Unknown UnknownPolicy
+	// Clock tells handlers the current time.
+	// When nil, time.Now is used.
+	Clock func() time.Time
+	// FS is the file system handlers can read from.
+	FS fs.FS
+	// Parser configures how handlers parse the files they read.
+	// The source name is set by the handlers.
+	Parser ahm.Config
+
+	handlers Package
+	packages *ns.Registry
+}
+
+func New() *Engine {
+	return &Engine{
+		handlers: Package{},
+		packages: ns.NewRegistry(),
+	}
+}
+
+// Register sets the handler for unqualified procs with the given name.
+func (engine *Engine) Register(name string, handler Handler) {
+	engine.handlers[name] = handler
+}
+
+func (engine *Engine) RegisterFunc(name string, handler func(ctx *Context, proc *ahm.Proc) ([]ahm.Node, error)) {
+	engine.Register(name, HandlerFunc(handler))
+}
+
+// RegisterPackage makes a package of handlers importable under a name.
+func (engine *Engine) RegisterPackage(name string, pkg Package) {
+	engine.packages.Register(name, pkg)
+}
+
+// Eval evaluates a document.
+//
+// When some procs could not be evaluated, the error is an ahmerr.Diagnostics.
+// The nodes are returned even then, with the procs that failed left in place.
+// Imports are removed from the result.
+func (engine *Engine) Eval(nodes []ahm.Node) ([]ahm.Node, error) {
+	ctx := engine.newContext()
+	out := ctx.Eval(nodes)
+	return out, ctx.run.diags.Err()
+}
+
+func (engine *Engine) now() time.Time {
+	if engine.Clock == nil {
+		return time.Now()
+	}
+	return engine.Clock()
+}
+
+func (engine *Engine) handlerFor(scope *ns.Scope, proc *ahm.Proc) (Handler, error) {
+	name, pkg, err := scope.Resolve(proc)
+	if err != nil {
+		return nil, err
+	}
+	if pkg != nil {
+		return pkg.(Package)[name.Local], nil
+	}
+	return engine.handlers[name.Local], nil
+}
+
+// Today returns a handler that replaces a proc with the current date, in the given layout.
+func Today(layout string) Handler {
+	return HandlerFunc(func(ctx *Context, proc *ahm.Proc) ([]ahm.Node, error) {
+		return []ahm.Node{&ahm.Text{Text: ctx.Now().Format(layout), Span: proc.Span}}, nil
+	})
+}
+
+// Errorf reports a problem with a proc.
+// Handlers can return the result as an error.
+func Errorf(proc *ahm.Proc, msgFmt string, args ...interface{}) error {
+	return ahmerr.Diagnosef(proc.Span, msgFmt, args...)
+}
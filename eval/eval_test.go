@@ -0,0 +1,188 @@
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package eval_test
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/szabba/ahm"
+	"github.com/szabba/ahm/ahmerr"
+	"github.com/szabba/ahm/ahmtest"
+	"github.com/szabba/ahm/assert"
+	"github.com/szabba/ahm/eval"
+)
+
+func TestProcIsReplacedByTheNodesOfItsHandler(t *testing.T) {
+	// given
+	engine := eval.New()
+	engine.Clock = func() time.Time { return time.Date(2018, 3, 14, 0, 0, 0, 0, time.UTC) }
+	engine.Register("TODAY", eval.Today("2006-01-02"))
+
+	nodes := ahmtest.Parse(t, ahm.Config{},
+		"@DATE",
+		"  @TODAY")
+
+	wantNodes := []ahm.Node{
+		&ahm.Proc{Name: "DATE", Children: []ahm.Node{&ahm.Text{Text: "2018-03-14"}}},
+	}
+
+	// when
+	out, err := engine.Eval(nodes)
+
+	// then
+	assert.That(err == nil, t.Fatalf, "unexpected error: %s", err)
+	ahmtest.ReportDiffs(t.Fatalf, out, wantNodes)
+}
+
+func TestHandlerControlsTheEvaluationOfChildren(t *testing.T) {
+	// given
+	type prefixKey struct{}
+
+	engine := eval.New()
+	engine.RegisterFunc("PREFIX", func(ctx *eval.Context, proc *ahm.Proc) ([]ahm.Node, error) {
+		return ctx.WithValue(prefixKey{}, proc.Title).Eval(proc.Children), nil
+	})
+	engine.RegisterFunc("NAME", func(ctx *eval.Context, proc *ahm.Proc) ([]ahm.Node, error) {
+		prefix, _ := ctx.Value(prefixKey{}).(string)
+		return []ahm.Node{&ahm.Text{Text: prefix + proc.Title}}, nil
+	})
+
+	nodes := ahmtest.Parse(t, ahm.Config{},
+		"@PREFIX go-",
+		"  @NAME fmt",
+		"  @NAME vet",
+		"@NAME bare")
+
+	wantNodes := []ahm.Node{
+		&ahm.Text{Text: "go-fmt"},
+		&ahm.Text{Text: "go-vet"},
+		&ahm.Text{Text: "bare"},
+	}
+
+	// when
+	out, err := engine.Eval(nodes)
+
+	// then
+	assert.That(err == nil, t.Fatalf, "unexpected error: %s", err)
+	ahmtest.ReportDiffs(t.Fatalf, out, wantNodes)
+}
+
+func TestInlineProcsAreEvaluatedWithinParagraphs(t *testing.T) {
+	// given
+	engine := eval.New()
+	engine.RegisterFunc("UPPER", func(ctx *eval.Context, proc *ahm.Proc) ([]ahm.Node, error) {
+		return []ahm.Node{&ahm.Text{Text: strings.ToUpper(proc.Title)}}, nil
+	})
+
+	nodes := ahmtest.Parse(t, ahm.Config{}, "some @UPPER{loud} text")
+
+	wantNodes := []ahm.Node{
+		&ahm.Paragraph{Children: []ahm.Node{&ahm.Text{Text: "some LOUD text"}}},
+	}
+
+	// when
+	out, err := engine.Eval(nodes)
+
+	// then
+	assert.That(err == nil, t.Fatalf, "unexpected error: %s", err)
+	ahmtest.ReportDiffs(t.Fatalf, out, wantNodes)
+}
+
+func TestHandlerErrorsAreReportedAtTheProc(t *testing.T) {
+	// given
+	engine := eval.New()
+	engine.RegisterFunc("FAIL", func(ctx *eval.Context, proc *ahm.Proc) ([]ahm.Node, error) {
+		return nil, errors.New("always fails")
+	})
+
+	nodes := ahmtest.Parse(t, ahm.Config{},
+		"Some text.",
+		"@FAIL")
+
+	// when
+	out, err := engine.Eval(nodes)
+
+	// then
+	diags, ok := err.(ahmerr.Diagnostics)
+	assert.That(ok, t.Fatalf, "got error %#v, wanted diagnostics", err)
+	assert.That(len(diags) == 1, t.Fatalf, "got %d diagnostics, wanted 1", len(diags))
+	assert.That(diags[0].Span.StartsAt().Line() == 2, t.Errorf, "got diagnostic at %s, wanted line 2", diags[0].Span)
+	assert.That(len(out) == 2, t.Errorf, "the failing proc should be left in place")
+}
+
+func TestUnknownProcPolicies(t *testing.T) {
+	kases := map[string]struct {
+		policy    eval.UnknownPolicy
+		wantNodes []ahm.Node
+		wantDiags int
+	}{
+		"keep": {
+			policy:    eval.KeepUnknown,
+			wantNodes: []ahm.Node{&ahm.Proc{Name: "UNKNOWN", Children: []ahm.Node{&ahm.Text{Text: "known"}}}},
+		},
+		"drop": {
+			policy: eval.DropUnknown,
+		},
+		"report": {
+			policy:    eval.ReportUnknown,
+			wantNodes: []ahm.Node{&ahm.Proc{Name: "UNKNOWN", Children: []ahm.Node{&ahm.Text{Text: "known"}}}},
+			wantDiags: 1,
+		},
+	}
+
+	for name, kase := range kases {
+		t.Run(name, func(t *testing.T) {
+			// given
+			engine := eval.New()
+			engine.Unknown = kase.policy
+			engine.RegisterFunc("KNOWN", func(ctx *eval.Context, proc *ahm.Proc) ([]ahm.Node, error) {
+				return []ahm.Node{&ahm.Text{Text: "known"}}, nil
+			})
+
+			nodes := ahmtest.Parse(t, ahm.Config{},
+				"@UNKNOWN",
+				"  @KNOWN")
+
+			// when
+			out, err := engine.Eval(nodes)
+
+			// then
+			diags, _ := err.(ahmerr.Diagnostics)
+			assert.That(len(diags) == kase.wantDiags, t.Fatalf, "got diagnostics %v, wanted %d", err, kase.wantDiags)
+			ahmtest.ReportDiffs(t.Fatalf, out, kase.wantNodes)
+		})
+	}
+}
+
+func TestImportedPackageHandlersAreUsed(t *testing.T) {
+	// given
+	engine := eval.New()
+	engine.RegisterPackage("GO", eval.Package{
+		"VERSION": eval.HandlerFunc(func(ctx *eval.Context, proc *ahm.Proc) ([]ahm.Node, error) {
+			return []ahm.Node{&ahm.Text{Text: "go1.10"}}, nil
+		}),
+	})
+
+	nodes := ahmtest.Parse(t, ahm.Config{},
+		"@G/VERSION",
+		"@IMPORT GO AS G",
+		"@G/VERSION")
+
+	wantNodes := []ahm.Node{
+		&ahm.Proc{Name: "G/VERSION"},
+		&ahm.Text{Text: "go1.10"},
+	}
+
+	// when
+	out, err := engine.Eval(nodes)
+
+	// then
+	diags, _ := err.(ahmerr.Diagnostics)
+	assert.That(len(diags) == 1, t.Fatalf, "got diagnostics %v, wanted 1 for the use before import", err)
+	ahmtest.ReportDiffs(t.Fatalf, out, wantNodes)
+}
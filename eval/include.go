@@ -0,0 +1,127 @@
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package eval
+
+import (
+	"io/fs"
+	"path"
+	"strings"
+
+	"github.com/pkg/errors"
+	"github.com/szabba/ahm"
+	"github.com/szabba/ahm/ahmerr"
+	"github.com/szabba/ahm/position"
+)
+
+// Include is a handler that replaces a proc with the evaluated contents of the file it names:
+//
+//	@INCLUDE chapters/intro.ahm
+//
+// The path is relative to the directory of the including file and cannot leave the root of the engine's FS.
+// The included file starts with no imports in effect.
+var Include Handler = HandlerFunc(include)
+
+func include(ctx *Context, proc *ahm.Proc) ([]ahm.Node, error) {
+	name, err := ResolvePath(proc)
+	if err != nil {
+		return nil, err
+	}
+
+	chain := ctx.includeChain(proc)
+	for _, included := range chain {
+		if included == name {
+			return nil, Errorf(proc, "include cycle: %s", strings.Join(append(chain, name), " -> "))
+		}
+	}
+
+	nodes, err := ctx.ParseFile(name)
+	if diag, ok := err.(*ahmerr.Diagnostic); ok && diag.Span.Source == name {
+		// Only errors within the included file are reported as included from the proc.
+		ctx.including(name, proc.Span).Report(diag)
+		return nil, nil
+	} else if err != nil {
+		return nil, Errorf(proc, "cannot include %s: %s", name, err)
+	}
+	return ctx.including(name, proc.Span).Eval(nodes), nil
+}
+
+// ResolvePath interprets the title of a proc as a path relative to the file containing the proc.
+//
+// The path returned is valid for an fs.FS.
+// Paths that leave the root of the file system are rejected.
+func ResolvePath(proc *ahm.Proc) (string, error) {
+	rel := strings.TrimSpace(proc.Title)
+	if rel == "" {
+		return "", Errorf(proc, "%s needs a path", proc.Name)
+	}
+	if path.IsAbs(rel) {
+		return "", Errorf(proc, "path %s must be relative", rel)
+	}
+
+	name := path.Join(path.Dir(proc.Span.Source), rel)
+	if !fs.ValidPath(name) {
+		return "", Errorf(proc, "path %s leaves the root directory", rel)
+	}
+	return name, nil
+}
+
+// ParseFile reads and parses a file from the engine's FS.
+func (ctx *Context) ParseFile(name string) ([]ahm.Node, error) {
+	fsys := ctx.FS()
+	if fsys == nil {
+		return nil, errNoFS
+	}
+
+	f, err := fsys.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	cfg := ctx.run.engine.Parser
+	cfg.Source = name
+	return cfg.NewParser(f).ParseAll()
+}
+
+var errNoFS = errors.New("no file system to read from")
+
+type includeKey struct{}
+
+type includeFrame struct {
+	name string
+	from position.SpanIn
+	next *includeFrame
+}
+
+func (ctx *Context) including(name string, from position.SpanIn) *Context {
+	frame := &includeFrame{name: name, from: from}
+	frame.next, _ = ctx.Value(includeKey{}).(*includeFrame)
+
+	inner := ctx.WithValue(includeKey{}, frame)
+	inner.scope = ctx.run.engine.packages.Scope()
+	return inner
+}
+
+// includeChain lists the files being included when evaluating a proc, starting with the root document.
+func (ctx *Context) includeChain(proc *ahm.Proc) []string {
+	var names []string
+	root := proc.Span.Source
+	for frame, _ := ctx.Value(includeKey{}).(*includeFrame); frame != nil; frame = frame.next {
+		names = append([]string{frame.name}, names...)
+		root = frame.from.Source
+	}
+	if root != "" {
+		names = append([]string{path.Clean(root)}, names...)
+	}
+	return names
+}
+
+func (ctx *Context) includedFrom() []position.SpanIn {
+	var spans []position.SpanIn
+	for frame, _ := ctx.Value(includeKey{}).(*includeFrame); frame != nil; frame = frame.next {
+		spans = append(spans, frame.from)
+	}
+	return spans
+}
@@ -0,0 +1,64 @@
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package eval
+
+import (
+	"io/fs"
+
+	"github.com/szabba/ahm"
+)
+
+// IncludeFrom is a handler that makes a file available to the handlers of the proc's children:
+//
+//	@INCLUDE-FROM ../somedir/some_file.go
+//	  @GO/INCLUDE-FUNCTION-BODY someExampleFunc
+//
+// A proc without children is replaced by the contents of the file, as text.
+// The path is resolved like the path of an Include.
+var IncludeFrom Handler = HandlerFunc(includeFrom)
+
+// An IncludedFile is a file made available by an IncludeFrom proc.
+type IncludedFile struct {
+	Name string
+	Data []byte
+	// Proc is the proc that included the file.
+	Proc *ahm.Proc
+}
+
+type includedFileKey struct{}
+
+func includeFrom(ctx *Context, proc *ahm.Proc) ([]ahm.Node, error) {
+	name, err := ResolvePath(proc)
+	if err != nil {
+		return nil, err
+	}
+
+	data, err := ctx.ReadFile(name)
+	if err != nil {
+		return nil, Errorf(proc, "%s", err)
+	}
+
+	if len(proc.Children) == 0 {
+		return []ahm.Node{&ahm.Text{Text: string(data), Span: proc.Span}}, nil
+	}
+
+	file := &IncludedFile{Name: name, Data: data, Proc: proc}
+	return ctx.WithValue(includedFileKey{}, file).Eval(proc.Children), nil
+}
+
+// IncludedFrom returns the file included by the closest IncludeFrom ancestor.
+func IncludedFrom(ctx *Context) (*IncludedFile, bool) {
+	file, ok := ctx.Value(includedFileKey{}).(*IncludedFile)
+	return file, ok
+}
+
+// ReadFile reads a file from the engine's FS.
+func (ctx *Context) ReadFile(name string) ([]byte, error) {
+	fsys := ctx.FS()
+	if fsys == nil {
+		return nil, errNoFS
+	}
+	return fs.ReadFile(fsys, name)
+}
@@ -0,0 +1,129 @@
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package eval_test
+
+import (
+	"strings"
+	"testing"
+	"testing/fstest"
+
+	"github.com/szabba/ahm"
+	"github.com/szabba/ahm/ahmerr"
+	"github.com/szabba/ahm/ahmtest"
+	"github.com/szabba/ahm/assert"
+	"github.com/szabba/ahm/eval"
+)
+
+func TestIncludedNodesAreSplicedIn(t *testing.T) {
+	// given
+	engine := includingEngine(fstest.MapFS{
+		"chapters/intro.ahm": {Data: []byte("@H1 Intro\n@INCLUDE text.ahm")},
+		"chapters/text.ahm":  {Data: []byte("Hello.")},
+	})
+
+	nodes := ahmtest.Parse(t, ahm.Config{Source: "book.ahm"},
+		"@BOOK",
+		"  @INCLUDE chapters/intro.ahm")
+
+	wantNodes := []ahm.Node{
+		&ahm.Proc{Name: "BOOK", Children: []ahm.Node{
+			&ahm.Proc{Name: "H1", Title: "Intro"},
+			&ahm.Text{Text: "Hello."},
+		}},
+	}
+
+	// when
+	out, err := engine.Eval(nodes)
+
+	// then
+	assert.That(err == nil, t.Fatalf, "unexpected error: %s", err)
+	ahmtest.ReportDiffs(t.Fatalf, out, wantNodes)
+
+	text := out[0].(*ahm.Proc).Children[1].(*ahm.Text)
+	assert.That(text.Span.Source == "chapters/text.ahm", t.Errorf, "got source %q, wanted the included file", text.Span.Source)
+}
+
+func TestIncludeCyclesAreReported(t *testing.T) {
+	// given
+	engine := includingEngine(fstest.MapFS{
+		"a.ahm": {Data: []byte("@INCLUDE b.ahm")},
+		"b.ahm": {Data: []byte("@INCLUDE a.ahm")},
+	})
+
+	nodes := ahmtest.Parse(t, ahm.Config{Source: "main.ahm"}, "@INCLUDE a.ahm")
+
+	// when
+	_, err := engine.Eval(nodes)
+
+	// then
+	diags, _ := err.(ahmerr.Diagnostics)
+	assert.That(len(diags) == 1, t.Fatalf, "got %v, wanted 1 diagnostic", err)
+	assert.That(strings.Contains(diags[0].Message, "main.ahm -> a.ahm -> b.ahm -> a.ahm"), t.Errorf, "got message %q, wanted the cycle", diags[0].Message)
+	assert.That(diags[0].Span.Source == "b.ahm", t.Errorf, "got diagnostic in %q, wanted b.ahm", diags[0].Span.Source)
+	assert.That(len(diags[0].IncludedFrom) == 2, t.Errorf, "got included from %v, wanted 2 places", diags[0].IncludedFrom)
+}
+
+func TestIncludesCannotLeaveTheRoot(t *testing.T) {
+	// given
+	engine := includingEngine(fstest.MapFS{})
+
+	nodes := ahmtest.Parse(t, ahm.Config{Source: "docs/main.ahm"}, "@INCLUDE ../../secret.ahm")
+
+	// when
+	_, err := engine.Eval(nodes)
+
+	// then
+	diags, _ := err.(ahmerr.Diagnostics)
+	assert.That(len(diags) == 1, t.Fatalf, "got %v, wanted 1 diagnostic", err)
+	assert.That(strings.Contains(diags[0].Message, "leaves the root"), t.Errorf, "got message %q", diags[0].Message)
+}
+
+func TestMissingIncludesAreReportedAtTheInclude(t *testing.T) {
+	// given
+	engine := includingEngine(fstest.MapFS{
+		"a.ahm": {Data: []byte("@INCLUDE missing.ahm")},
+	})
+
+	nodes := ahmtest.Parse(t, ahm.Config{Source: "main.ahm"}, "@INCLUDE a.ahm")
+
+	// when
+	_, err := engine.Eval(nodes)
+
+	// then
+	diags, _ := err.(ahmerr.Diagnostics)
+	assert.That(len(diags) == 1, t.Fatalf, "got %v, wanted 1 diagnostic", err)
+	assert.That(strings.Contains(diags[0].Message, "missing.ahm"), t.Errorf, "got message %q, wanted the missing file", diags[0].Message)
+	assert.That(diags[0].Span.Source == "a.ahm", t.Errorf, "got diagnostic in %q, wanted a.ahm", diags[0].Span.Source)
+	assert.That(len(diags[0].IncludedFrom) == 1, t.Fatalf, "got included from %v, wanted 1 place", diags[0].IncludedFrom)
+	from := diags[0].IncludedFrom[0]
+	assert.That(from.Source == "main.ahm", t.Errorf, "got included from %s, wanted main.ahm", from)
+}
+
+func TestParseErrorsPointIntoTheIncludedFile(t *testing.T) {
+	// given
+	engine := includingEngine(fstest.MapFS{
+		"broken.ahm": {Data: []byte("Fine text.\n@TITLE\n      oops\n  @CHILD")},
+	})
+
+	nodes := ahmtest.Parse(t, ahm.Config{Source: "main.ahm"}, "Text.\n@INCLUDE broken.ahm")
+
+	// when
+	_, err := engine.Eval(nodes)
+
+	// then
+	diags, _ := err.(ahmerr.Diagnostics)
+	assert.That(len(diags) == 1, t.Fatalf, "got %v, wanted 1 diagnostic", err)
+	assert.That(diags[0].Span.Source == "broken.ahm", t.Errorf, "got diagnostic in %q, wanted broken.ahm", diags[0].Span.Source)
+	assert.That(len(diags[0].IncludedFrom) == 1, t.Fatalf, "got included from %v, wanted 1 place", diags[0].IncludedFrom)
+	from := diags[0].IncludedFrom[0]
+	assert.That(from.Source == "main.ahm" && from.StartsAt().Line() == 2, t.Errorf, "got included from %s, wanted main.ahm line 2", from)
+}
+
+func includingEngine(fsys fstest.MapFS) *eval.Engine {
+	engine := eval.New()
+	engine.FS = fsys
+	engine.Register("INCLUDE", eval.Include)
+	return engine
+}
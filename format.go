@@ -0,0 +1,225 @@
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package ahm
+
+import (
+	"bufio"
+	"bytes"
+	"io"
+	"regexp"
+	"strings"
+
+	"github.com/pkg/errors"
+
+	"github.com/szabba/ahm/ahmerr"
+)
+
+// A Printer writes nodes in the AHM syntax, so that parsing the output gives back equal nodes.
+//
+// Some nodes have no such representation, like text with a line starting with a proc mark.
+// Printing them fails.
+type Printer struct {
+	// Indent is the indentation of nested nodes.
+	// When empty, two spaces are used.
+	Indent string
+}
+
+func Format(w io.Writer, nodes []Node) error {
+	return Printer{}.Format(w, nodes)
+}
+
+func (p Printer) Format(w io.Writer, nodes []Node) error {
+	var buf bytes.Buffer
+	out := bufio.NewWriter(&buf)
+	err := p.nodes(out, nodes, "")
+	if err != nil {
+		return err
+	}
+	out.Flush()
+
+	err = checkRoundTrip(buf.Bytes(), nodes)
+	if err != nil {
+		return err
+	}
+	_, err = buf.WriteTo(w)
+	return err
+}
+
+// checkRoundTrip makes sure the printed nodes parse back into equal ones.
+//
+// Nodes with structured args are parsed back with them.
+func checkRoundTrip(printed []byte, nodes []Node) error {
+	cfg := Config{StructuredArgs: hasStructuredArgs(nodes)}
+	parsed, err := cfg.NewParser(bytes.NewReader(printed)).ParseAll()
+	for i, node := range nodes {
+		if i >= len(parsed) || !Equals(node, parsed[i]) {
+			return ahmerr.Diagnosef(SpanOf(node), "the node cannot be written in a way that parses back the same")
+		}
+	}
+	if err != nil || len(parsed) != len(nodes) {
+		return errors.Errorf("the printed document does not parse back the same: %v", err)
+	}
+	return nil
+}
+
+// hasStructuredArgs checks whether any of the procs among the nodes has args or attrs.
+func hasStructuredArgs(nodes []Node) bool {
+	for _, node := range nodes {
+		if proc, ok := node.(*Proc); ok && (len(proc.Args) > 0 || len(proc.Attrs) > 0) {
+			return true
+		}
+		if hasStructuredArgs(childrenOf(node)) {
+			return true
+		}
+	}
+	return false
+}
+
+// FormatString returns the nodes in the AHM syntax.
+func FormatString(nodes []Node) (string, error) {
+	var out strings.Builder
+	err := Format(&out, nodes)
+	return out.String(), err
+}
+
+func (p Printer) indent() string {
+	if p.Indent == "" {
+		return "  "
+	}
+	return p.Indent
+}
+
+func (p Printer) nodes(out *bufio.Writer, nodes []Node, indent string) error {
+	for i, node := range nodes {
+		if i > 0 && isProse(nodes[i-1]) && isProse(node) {
+			return ahmerr.Diagnosef(SpanOf(node), "adjacent text would be parsed as one node")
+		}
+		if i > 0 && indent == "" && separateBlocks(nodes[i-1], node) {
+			out.WriteString("\n")
+		}
+
+		var err error
+		switch node := node.(type) {
+		case *Proc:
+			err = p.proc(out, node, indent)
+		case *Text:
+			err = p.prose(out, []Node{node}, indent)
+		case *Paragraph:
+			err = p.prose(out, node.Children, indent)
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (p Printer) proc(out *bufio.Writer, proc *Proc, indent string) error {
+	if strings.ContainsRune(proc.Title, '\n') {
+		return ahmerr.Diagnosef(proc.Span, "the title of proc %s spans multiple lines", proc.Name)
+	}
+
+	out.WriteString(indent + "@" + proc.Name)
+	if proc.Title != "" {
+		out.WriteString(" " + proc.Title)
+	}
+	out.WriteString("\n")
+
+	return p.nodes(out, proc.Children, indent+p.indent())
+}
+
+// prose prints text interspersed with inline procs.
+func (p Printer) prose(out *bufio.Writer, parts []Node, indent string) error {
+	var line strings.Builder
+	atLineStart, first := true, true
+
+	for _, part := range parts {
+		switch part := part.(type) {
+		case *Text:
+			for i, text := range strings.Split(part.Text, "\n") {
+				if i > 0 {
+					writeLine(out, indent, line.String())
+					line.Reset()
+					atLineStart = true
+				}
+				err := checkText(part, text, atLineStart, first)
+				if err != nil {
+					return err
+				}
+				line.WriteString(text)
+				atLineStart = atLineStart && text == ""
+				first = first && atLineStart
+			}
+
+		case *Proc:
+			if strings.ContainsAny(part.Title, "\n") || !balancedBraces(part.Title) {
+				return ahmerr.Diagnosef(part.Span, "the argument of inline proc %s cannot be written inline", part.Name)
+			}
+			line.WriteString("@" + part.Name + "{" + part.Title + "}")
+			atLineStart, first = false, false
+		}
+	}
+
+	writeLine(out, indent, line.String())
+	return nil
+}
+
+func writeLine(out *bufio.Writer, indent, line string) {
+	if line != "" {
+		out.WriteString(indent)
+	}
+	out.WriteString(line + "\n")
+}
+
+var inlineProcPattern = regexp.MustCompile(`@[^\s@{}]+\{`)
+
+// checkText makes sure a line of text will not be parsed as something else.
+// Only the first line cannot be indented, as the following ones can be indented deeper than it.
+func checkText(node *Text, line string, atLineStart, first bool) error {
+	switch {
+	case atLineStart && strings.HasPrefix(line, "@"):
+		return ahmerr.Diagnosef(node.Span, "a line of text cannot start with a proc mark")
+	case first && strings.TrimLeft(line, " \t") != line:
+		return ahmerr.Diagnosef(node.Span, "a line of text cannot start with whitespace")
+	case inlineProcPattern.MatchString(line):
+		return ahmerr.Diagnosef(node.Span, "text cannot contain what looks like an inline proc")
+	default:
+		return nil
+	}
+}
+
+func balancedBraces(s string) bool {
+	depth := 0
+	for _, r := range s {
+		switch r {
+		case '{':
+			depth++
+		case '}':
+			depth--
+			if depth < 0 {
+				return false
+			}
+		}
+	}
+	return depth == 0
+}
+
+func isProse(node Node) bool {
+	switch node.(type) {
+	case *Text, *Paragraph:
+		return true
+	default:
+		return false
+	}
+}
+
+// separateBlocks decides whether there should be a blank line between two top-level nodes.
+func separateBlocks(prev, next Node) bool {
+	if isProse(prev) || isProse(next) {
+		return true
+	}
+	proc, ok := prev.(*Proc)
+	return ok && len(proc.Children) > 0
+}
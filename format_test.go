@@ -0,0 +1,114 @@
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package ahm_test
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/szabba/ahm"
+	. "github.com/szabba/ahm/ahmtest"
+	"github.com/szabba/ahm/assert"
+)
+
+func TestFormatLaysOutNestedNodes(t *testing.T) {
+	// given
+	nodes := []ahm.Node{
+		P("DOCUMENT", "", P("TITLE", "A title")),
+		P("H1", "One"),
+		P("H2", "Two"),
+		Para(T("Energy is "), P("MATH", "E = mc^2"), T(",\nroughly.")),
+		P("CODE", "go", T("if x {\n\treturn\n}")),
+	}
+
+	want := Lines(
+		"@DOCUMENT",
+		"  @TITLE A title",
+		"",
+		"@H1 One",
+		"@H2 Two",
+		"",
+		"Energy is @MATH{E = mc^2},",
+		"roughly.",
+		"",
+		"@CODE go",
+		"  if x {",
+		"  \treturn",
+		"  }",
+		"")
+
+	// when
+	got, err := ahm.FormatString(nodes)
+
+	// then
+	assert.That(err == nil, t.Fatalf, "unexpected error: %s", err)
+	assert.That(got == want, t.Errorf, "got\n%s\nwanted\n%s", got, want)
+}
+
+func TestFormatKeepsStructuredArgs(t *testing.T) {
+	cases := []struct{ src, want string }{
+		{"@CARD start x=1\n  text\n", "@CARD start x=1\n  text\n"},
+		{"@H1 t\ntext\n", "@H1 t\n\ntext\n"},
+		{"Some @LINK{\"a b\" to=c}.\n", "Some @LINK{\"a b\" to=c}.\n"},
+	}
+
+	for _, c := range cases {
+		// given
+		nodes := Parse(t, ahm.Config{StructuredArgs: true}, c.src)
+
+		// when
+		got, err := ahm.FormatString(nodes)
+
+		// then
+		assert.That(err == nil, t.Fatalf, "unexpected error for %q: %s", c.src, err)
+		assert.That(got == c.want, t.Errorf, "got\n%s\nwanted\n%s", got, c.want)
+	}
+}
+
+func TestFormatRejectsTextThatWouldBecomeAProc(t *testing.T) {
+	// given
+	nodes := []ahm.Node{T("Hi\n@there")}
+
+	// when
+	_, err := ahm.FormatString(nodes)
+
+	// then
+	assert.That(err != nil, t.Errorf, "expected an error")
+}
+
+func TestFormatRejectsAdjacentText(t *testing.T) {
+	// given
+	nodes := []ahm.Node{T("A"), T("B")}
+
+	// when
+	_, err := ahm.FormatString(nodes)
+
+	// then
+	assert.That(err != nil, t.Errorf, "expected an error")
+}
+
+func TestExamplesRoundTripThroughFormat(t *testing.T) {
+	paths, _ := filepath.Glob(filepath.Join("examples", "*.ahm"))
+	for _, path := range paths {
+		t.Run(path, func(t *testing.T) {
+			// given
+			src, err := os.ReadFile(path)
+			assert.That(err == nil, t.Fatalf, "cannot read example: %s", err)
+			nodes, err := ahm.NewParser(strings.NewReader(string(src))).ParseAll()
+			assert.That(err == nil, t.Fatalf, "cannot parse example: %s", err)
+
+			// when
+			formatted, err := ahm.FormatString(nodes)
+
+			// then
+			assert.That(err == nil, t.Fatalf, "unexpected error: %s", err)
+			reparsed, err := ahm.NewParser(strings.NewReader(formatted)).ParseAll()
+			assert.That(err == nil, t.Fatalf, "cannot parse formatted example: %s", err)
+			ReportDiffs(t.Errorf, reparsed, nodes)
+		})
+	}
+}
@@ -0,0 +1,125 @@
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package ahm
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/pkg/errors"
+	"github.com/szabba/ahm/ahmerr"
+	"github.com/szabba/ahm/assert"
+	"github.com/szabba/ahm/internal/seeds"
+	"github.com/szabba/ahm/position"
+)
+
+// FuzzParseNeverPanics checks that no input makes the parser panic or break its invariants.
+//
+// The corpus in testdata/fuzz/FuzzParseNeverPanics holds inputs that once did, or came close.
+func FuzzParseNeverPanics(f *testing.F) {
+	addSeeds(f, "parser_test.go")
+	f.Fuzz(func(t *testing.T, input string) {
+		for _, cfg := range []Config{{}, {StructuredArgs: true}} {
+			nodes, err := cfg.NewParser(strings.NewReader(input)).ParseAll()
+			if internal, ok := errors.Cause(err).(*ahmerr.InternalError); ok {
+				t.Fatalf("parsing %q: %s", input, internal)
+			}
+			if err == nil {
+				checkSpans(t, input, position.SpanFromTo(position.First(), position.NewFile("", []byte(input)).Position(len(input))), nodes)
+			}
+
+			fromBytes, bytesErr := cfg.NewBytesParser([]byte(input)).ParseAll()
+			if !reflect.DeepEqual(fromBytes, nodes) || fmt.Sprint(bytesErr) != fmt.Sprint(err) {
+				t.Fatalf("parsing %q from bytes gave %v, %v instead of %v, %v", input, fromBytes, bytesErr, nodes, err)
+			}
+		}
+	})
+}
+
+// checkSpans checks that the nodes follow one another within their parent.
+func checkSpans(t *testing.T, input string, parent position.Span, nodes []Node) {
+	prevEnd := parent.StartsAt()
+	for _, node := range nodes {
+		span := SpanOf(node).Span
+		if !parent.Encloses(span) || span.StartsAt().Before(prevEnd) {
+			t.Fatalf("the span %s of %# v is not after %s within %s in %q", span, node, prevEnd, parent, input)
+		}
+		prevEnd = span.EndsBefore()
+		checkSpans(t, input, span, childrenOf(node))
+	}
+}
+
+func addSeeds(f *testing.F, testFile string) {
+	examples, err := seeds.Examples("examples")
+	if err != nil {
+		f.Fatal(err)
+	}
+	cases, err := seeds.FromTests(testFile)
+	if err != nil {
+		f.Fatal(err)
+	}
+	for _, input := range append(examples, cases...) {
+		f.Add(input)
+	}
+}
+
+func TestBrokenParserInvariantsAreInternalErrors(t *testing.T) {
+	// given
+	parser := Config{Source: "doc.ahm"}.NewParser(strings.NewReader("not a proc"))
+
+	// when
+	_, err := parser.parseProc()
+
+	// then
+	internal, ok := err.(*ahmerr.InternalError)
+	assert.That(ok, t.Fatalf, "got error %v, wanted an internal error", err)
+	assert.That(internal.Span.Source == "doc.ahm" && internal.Span.StartsAt().Line() == 1, t.Errorf, "got span %s", internal.Span)
+}
+
+func TestAcceptingTokensNotPeekedAtIsAnInternalError(t *testing.T) {
+	// given
+	parser := Config{Source: "doc.ahm"}.NewParser(strings.NewReader("@P title"))
+	_, _ = parser.tokens.peek(1)
+
+	// when
+	err := parser.tokens.accept(3)
+
+	// then
+	internal, ok := err.(*ahmerr.InternalError)
+	assert.That(ok, t.Fatalf, "got error %v, wanted an internal error", err)
+	assert.That(internal.Span.Source == "doc.ahm" && internal.Span.StartsAt().Column() == 1, t.Errorf, "got span %s", internal.Span)
+	tok, _ := parser.tokens.peek(0)
+	assert.That(tok.Text == "@", t.Errorf, "got next token %q, wanted the tokens to stay", tok.Text)
+}
+
+func TestAcceptingNoTokensIsAnInternalError(t *testing.T) {
+	// given
+	parser := Config{Source: "doc.ahm"}.NewParser(strings.NewReader("@P title"))
+
+	// when
+	err := parser.tokens.accept(0)
+
+	// then
+	_, ok := err.(*ahmerr.InternalError)
+	assert.That(ok, t.Errorf, "got error %v, wanted an internal error", err)
+}
+
+func TestParagraphPartsOutOfOrderAreAnInternalError(t *testing.T) {
+	// given
+	file := position.NewFile("", []byte("first\nsecond"))
+	para := paragraphBuilder{source: "doc.ahm"}
+	para.addText("second", position.SpanFromTo(file.PositionOf(2, 1), file.PositionOf(2, 7)))
+
+	// when
+	para.addText("first", position.SpanFromTo(file.PositionOf(1, 1), file.PositionOf(1, 6)))
+	_, err := para.build(nil)
+
+	// then
+	internal, ok := err.(*ahmerr.InternalError)
+	assert.That(ok, t.Fatalf, "got error %v, wanted an internal error", err)
+	assert.That(internal.Span.Source == "doc.ahm" && internal.Span.StartsAt().Line() == 1, t.Errorf, "got span %s", internal.Span)
+}
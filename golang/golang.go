@@ -0,0 +1,277 @@
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+// Package golang provides handlers for keeping Go code in documents formatted and up to date.
+//
+// Register makes the handlers available under the GO namespace:
+//
+//	@IMPORT GO
+//
+//	@CODE go
+//	  @GO/FMT
+//	    package main
+//
+//	@CODE go
+//	  @INCLUDE-FROM ../somedir/some_file.go
+//	    @GO/INCLUDE-FUNCTION-BODY someExampleFunc
+//
+// All the handlers, except for FMT, work on the Go file made available by an enclosing INCLUDE-FROM.
+package golang
+
+import (
+	"bytes"
+	"go/ast"
+	"go/format"
+	"go/parser"
+	"go/token"
+	"strings"
+
+	"github.com/pkg/errors"
+
+	"github.com/szabba/ahm"
+	"github.com/szabba/ahm/eval"
+)
+
+// Namespace is the name Register makes the package importable under.
+const Namespace = "GO"
+
+// Register adds the GO package and the INCLUDE-FROM handler to an engine.
+func Register(engine *eval.Engine) {
+	engine.RegisterPackage(Namespace, Package())
+	engine.Register("INCLUDE-FROM", eval.IncludeFrom)
+}
+
+// Package returns the handlers, by their local names.
+func Package() eval.Package {
+	return eval.Package{
+		"FMT":                   eval.HandlerFunc(fmtCode),
+		"INCLUDE-FUNCTION":      fromGoFile(includeFunction),
+		"INCLUDE-FUNCTION-BODY": fromGoFile(includeFunctionBody),
+		"INCLUDE-TYPE":          fromGoFile(includeType),
+		"INCLUDE-DOC":           fromGoFile(includeDoc),
+		"INCLUDE-EXAMPLES":      fromGoFile(includeExamples),
+	}
+}
+
+// fmtCode formats the text of the proc's children with gofmt.
+func fmtCode(ctx *eval.Context, proc *ahm.Proc) ([]ahm.Node, error) {
+	var src bytes.Buffer
+	for _, child := range ctx.Eval(proc.Children) {
+		text, ok := child.(*ahm.Text)
+		if !ok {
+			return nil, eval.Errorf(proc, "can only format text")
+		}
+		src.WriteString(text.Text)
+		src.WriteRune('\n')
+	}
+
+	formatted, err := format.Source(src.Bytes())
+	if err != nil {
+		return nil, eval.Errorf(proc, "cannot format Go code: %s", err)
+	}
+	return textOf(proc, string(formatted)), nil
+}
+
+// A goFile is a parsed Go file made available by INCLUDE-FROM.
+type goFile struct {
+	name string
+	src  []byte
+	fset *token.FileSet
+	ast  *ast.File
+}
+
+func (file *goFile) source(from, to token.Pos) string {
+	return string(file.src[file.fset.Position(from).Offset:file.fset.Position(to).Offset])
+}
+
+func fromGoFile(f func(file *goFile, proc *ahm.Proc) (string, error)) eval.Handler {
+	return eval.HandlerFunc(func(ctx *eval.Context, proc *ahm.Proc) ([]ahm.Node, error) {
+		included, ok := eval.IncludedFrom(ctx)
+		if !ok {
+			return nil, eval.Errorf(proc, "%s must be nested in an INCLUDE-FROM", proc.Name)
+		}
+
+		file := &goFile{name: included.Name, src: included.Data, fset: token.NewFileSet()}
+		var err error
+		file.ast, err = parser.ParseFile(file.fset, included.Name, included.Data, parser.ParseComments)
+		if err != nil {
+			return nil, eval.Errorf(proc, "cannot parse %s: %s", included.Name, err)
+		}
+
+		text, err := f(file, proc)
+		if err != nil {
+			return nil, eval.Errorf(proc, "%s", err)
+		}
+		return textOf(proc, text), nil
+	})
+}
+
+func includeFunction(file *goFile, proc *ahm.Proc) (string, error) {
+	fun, err := findFunc(file, proc.Title)
+	if err != nil {
+		return "", err
+	}
+	return file.source(fun.Pos(), fun.End()), nil
+}
+
+func includeFunctionBody(file *goFile, proc *ahm.Proc) (string, error) {
+	fun, err := findFunc(file, proc.Title)
+	if err != nil {
+		return "", err
+	}
+	if fun.Body == nil {
+		return "", errors.Errorf("function %s has no body", proc.Title)
+	}
+	body := file.source(fun.Body.Lbrace+1, fun.Body.Rbrace)
+	return dedent(body), nil
+}
+
+func includeType(file *goFile, proc *ahm.Proc) (string, error) {
+	decl, spec, err := findType(file, proc.Title)
+	if err != nil {
+		return "", err
+	}
+	if decl.Lparen.IsValid() {
+		return "type " + file.source(spec.Pos(), spec.End()), nil
+	}
+	return file.source(decl.Pos(), decl.End()), nil
+}
+
+// includeDoc includes the doc comment of a function or type, or of the package when no name is given.
+func includeDoc(file *goFile, proc *ahm.Proc) (string, error) {
+	name := strings.TrimSpace(proc.Title)
+	if name == "" {
+		return docText(file.ast.Doc, "package "+file.ast.Name.Name)
+	}
+
+	if fun, err := findFunc(file, name); err == nil {
+		return docText(fun.Doc, name)
+	}
+
+	decl, spec, err := findType(file, name)
+	if err != nil {
+		return "", errors.Errorf("no function or type named %s in %s", name, file.name)
+	}
+	if spec.Doc != nil {
+		return docText(spec.Doc, name)
+	}
+	return docText(decl.Doc, name)
+}
+
+// includeExamples includes all the example functions in a file, separated by blank lines.
+func includeExamples(file *goFile, proc *ahm.Proc) (string, error) {
+	var examples []string
+	for _, decl := range file.ast.Decls {
+		fun, ok := decl.(*ast.FuncDecl)
+		if ok && fun.Recv == nil && strings.HasPrefix(fun.Name.Name, "Example") {
+			examples = append(examples, file.source(fun.Pos(), fun.End()))
+		}
+	}
+	if len(examples) == 0 {
+		return "", errors.Errorf("no example functions in %s", file.name)
+	}
+	return strings.Join(examples, "\n\n"), nil
+}
+
+// findFunc finds a function by name, or a method by a name like Type.Method.
+func findFunc(file *goFile, name string) (*ast.FuncDecl, error) {
+	name = strings.TrimSpace(name)
+	recv, method := "", name
+	if i := strings.Index(name, "."); i >= 0 {
+		recv, method = name[:i], name[i+1:]
+	}
+
+	for _, decl := range file.ast.Decls {
+		fun, ok := decl.(*ast.FuncDecl)
+		if ok && fun.Name.Name == method && receiverName(fun) == recv {
+			return fun, nil
+		}
+	}
+	return nil, errors.Errorf("no function named %s in %s", name, file.name)
+}
+
+func receiverName(fun *ast.FuncDecl) string {
+	if fun.Recv == nil || len(fun.Recv.List) == 0 {
+		return ""
+	}
+	typ := fun.Recv.List[0].Type
+	if star, ok := typ.(*ast.StarExpr); ok {
+		typ = star.X
+	}
+	if ident, ok := typ.(*ast.Ident); ok {
+		return ident.Name
+	}
+	return ""
+}
+
+func findType(file *goFile, name string) (*ast.GenDecl, *ast.TypeSpec, error) {
+	name = strings.TrimSpace(name)
+	for _, decl := range file.ast.Decls {
+		gen, ok := decl.(*ast.GenDecl)
+		if !ok || gen.Tok != token.TYPE {
+			continue
+		}
+		for _, spec := range gen.Specs {
+			spec := spec.(*ast.TypeSpec)
+			if spec.Name.Name == name {
+				return gen, spec, nil
+			}
+		}
+	}
+	return nil, nil, errors.Errorf("no type named %s in %s", name, file.name)
+}
+
+func docText(doc *ast.CommentGroup, what string) (string, error) {
+	if doc == nil {
+		return "", errors.Errorf("%s has no doc comment", what)
+	}
+	return strings.TrimSuffix(doc.Text(), "\n"), nil
+}
+
+// dedent removes the blank lines around code, and the indentation common to all its lines.
+func dedent(code string) string {
+	lines := trimBlankLines(strings.Split(code, "\n"))
+
+	prefix, seeded := "", false
+	for _, line := range lines {
+		if isBlank(line) {
+			continue
+		}
+		indent := line[:len(line)-len(strings.TrimLeft(line, " \t"))]
+		if !seeded {
+			prefix, seeded = indent, true
+		} else {
+			prefix = commonPrefix(prefix, indent)
+		}
+	}
+
+	for i, line := range lines {
+		lines[i] = strings.TrimPrefix(line, prefix)
+	}
+	return strings.Join(lines, "\n")
+}
+
+func trimBlankLines(lines []string) []string {
+	for len(lines) > 0 && isBlank(lines[0]) {
+		lines = lines[1:]
+	}
+	for len(lines) > 0 && isBlank(lines[len(lines)-1]) {
+		lines = lines[:len(lines)-1]
+	}
+	return lines
+}
+
+func isBlank(line string) bool { return strings.TrimSpace(line) == "" }
+
+func commonPrefix(prefix, indent string) string {
+	n := 0
+	for n < len(prefix) && n < len(indent) && prefix[n] == indent[n] {
+		n++
+	}
+	return prefix[:n]
+}
+
+func textOf(proc *ahm.Proc, text string) []ahm.Node {
+	return []ahm.Node{&ahm.Text{Text: strings.TrimSuffix(text, "\n"), Span: proc.Span}}
+}
@@ -0,0 +1,241 @@
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package golang_test
+
+import (
+	"strings"
+	"testing"
+	"testing/fstest"
+
+	"github.com/szabba/ahm"
+	"github.com/szabba/ahm/ahmerr"
+	"github.com/szabba/ahm/ahmtest"
+	"github.com/szabba/ahm/assert"
+	"github.com/szabba/ahm/eval"
+	"github.com/szabba/ahm/golang"
+)
+
+const exampleGo = `package example
+
+// Greeter greets people.
+type Greeter struct {
+	Name string
+}
+
+// Greet returns a greeting.
+func (g *Greeter) Greet() string {
+	return "Hello, " + g.Name
+}
+
+func someExampleFunc() {
+	g := &Greeter{Name: "World"}
+	if true {
+		println(g.Greet())
+	}
+}
+
+func trailingSpace() { ` + " " + `
+	println("after a brace and a space")
+}
+
+func ExampleGreeter() {
+	println("a")
+}
+
+func ExampleGreeter_Greet() {
+	println("b")
+}
+`
+
+func TestFmtFormatsTheChildCode(t *testing.T) {
+	// given
+	engine := goEngine(nil)
+	nodes := ahmtest.Parse(t, ahm.Config{},
+		"@IMPORT GO",
+		"@GO/FMT",
+		"  package main",
+		"  func  main( ) { }")
+
+	// when
+	out, err := engine.Eval(nodes)
+
+	// then
+	assert.That(err == nil, t.Fatalf, "unexpected error: %s", err)
+	assertText(t, out, "package main\n\nfunc main() {}")
+}
+
+func TestFmtReportsSyntaxErrorsAtTheProc(t *testing.T) {
+	// given
+	engine := goEngine(nil)
+	nodes := ahmtest.Parse(t, ahm.Config{},
+		"@IMPORT GO",
+		"@GO/FMT",
+		"  package main",
+		"  func main( {")
+
+	// when
+	_, err := engine.Eval(nodes)
+
+	// then
+	assertDiagnosticAtLine(t, err, 2, "cannot format")
+}
+
+func TestIncludeFunctionBody(t *testing.T) {
+	// given
+	engine := goEngine(fstest.MapFS{"src/example.go": {Data: []byte(exampleGo)}})
+	nodes := ahmtest.Parse(t, ahm.Config{},
+		"@IMPORT GO",
+		"@INCLUDE-FROM src/example.go",
+		"  @GO/INCLUDE-FUNCTION-BODY someExampleFunc")
+
+	// when
+	out, err := engine.Eval(nodes)
+
+	// then
+	assert.That(err == nil, t.Fatalf, "unexpected error: %s", err)
+	assertText(t, out, "g := &Greeter{Name: \"World\"}\nif true {\n\tprintln(g.Greet())\n}")
+}
+
+func TestIncludeFunctionBodyAfterTrailingSpace(t *testing.T) {
+	// given
+	engine := goEngine(fstest.MapFS{"example.go": {Data: []byte(exampleGo)}})
+	nodes := ahmtest.Parse(t, ahm.Config{},
+		"@IMPORT GO",
+		"@INCLUDE-FROM example.go",
+		"  @GO/INCLUDE-FUNCTION-BODY trailingSpace")
+
+	// when
+	out, err := engine.Eval(nodes)
+
+	// then
+	assert.That(err == nil, t.Fatalf, "unexpected error: %s", err)
+	assertText(t, out, "println(\"after a brace and a space\")")
+}
+
+func TestIncludeMethodBody(t *testing.T) {
+	// given
+	engine := goEngine(fstest.MapFS{"example.go": {Data: []byte(exampleGo)}})
+	nodes := ahmtest.Parse(t, ahm.Config{},
+		"@IMPORT GO",
+		"@INCLUDE-FROM example.go",
+		"  @GO/INCLUDE-FUNCTION-BODY Greeter.Greet")
+
+	// when
+	out, err := engine.Eval(nodes)
+
+	// then
+	assert.That(err == nil, t.Fatalf, "unexpected error: %s", err)
+	assertText(t, out, "return \"Hello, \" + g.Name")
+}
+
+func TestIncludeType(t *testing.T) {
+	// given
+	engine := goEngine(fstest.MapFS{"example.go": {Data: []byte(exampleGo)}})
+	nodes := ahmtest.Parse(t, ahm.Config{},
+		"@IMPORT GO",
+		"@INCLUDE-FROM example.go",
+		"  @GO/INCLUDE-TYPE Greeter")
+
+	// when
+	out, err := engine.Eval(nodes)
+
+	// then
+	assert.That(err == nil, t.Fatalf, "unexpected error: %s", err)
+	assertText(t, out, "type Greeter struct {\n\tName string\n}")
+}
+
+func TestIncludeDoc(t *testing.T) {
+	// given
+	engine := goEngine(fstest.MapFS{"example.go": {Data: []byte(exampleGo)}})
+	nodes := ahmtest.Parse(t, ahm.Config{},
+		"@IMPORT GO",
+		"@INCLUDE-FROM example.go",
+		"  @GO/INCLUDE-DOC Greeter.Greet")
+
+	// when
+	out, err := engine.Eval(nodes)
+
+	// then
+	assert.That(err == nil, t.Fatalf, "unexpected error: %s", err)
+	assertText(t, out, "Greet returns a greeting.")
+}
+
+func TestIncludeExamples(t *testing.T) {
+	// given
+	engine := goEngine(fstest.MapFS{"example.go": {Data: []byte(exampleGo)}})
+	nodes := ahmtest.Parse(t, ahm.Config{},
+		"@IMPORT GO",
+		"@INCLUDE-FROM example.go",
+		"  @GO/INCLUDE-EXAMPLES")
+
+	// when
+	out, err := engine.Eval(nodes)
+
+	// then
+	assert.That(err == nil, t.Fatalf, "unexpected error: %s", err)
+	assertText(t, out, "func ExampleGreeter() {\n\tprintln(\"a\")\n}\n\nfunc ExampleGreeter_Greet() {\n\tprintln(\"b\")\n}")
+}
+
+func TestMissingFunctionIsReportedAtTheProc(t *testing.T) {
+	// given
+	engine := goEngine(fstest.MapFS{"example.go": {Data: []byte(exampleGo)}})
+	nodes := ahmtest.Parse(t, ahm.Config{},
+		"@IMPORT GO",
+		"@INCLUDE-FROM example.go",
+		"  @GO/INCLUDE-FUNCTION-BODY noSuchFunc")
+
+	// when
+	_, err := engine.Eval(nodes)
+
+	// then
+	assertDiagnosticAtLine(t, err, 3, "no function named noSuchFunc")
+}
+
+func TestIncludeOutsideOfIncludeFromIsReported(t *testing.T) {
+	// given
+	engine := goEngine(nil)
+	nodes := ahmtest.Parse(t, ahm.Config{},
+		"@IMPORT GO",
+		"@GO/INCLUDE-TYPE Greeter")
+
+	// when
+	_, err := engine.Eval(nodes)
+
+	// then
+	assertDiagnosticAtLine(t, err, 2, "INCLUDE-FROM")
+}
+
+func goEngine(fsys fstest.MapFS) *eval.Engine {
+	engine := eval.New()
+	engine.FS = fsys
+	golang.Register(engine)
+	return engine
+}
+
+func assertText(t *testing.T, nodes []ahm.Node, want string) {
+	t.Helper()
+	var got []string
+	for _, node := range nodes {
+		switch node := node.(type) {
+		case *ahm.Text:
+			got = append(got, node.Text)
+		case *ahm.Proc:
+			for _, child := range node.Children {
+				if text, ok := child.(*ahm.Text); ok {
+					got = append(got, text.Text)
+				}
+			}
+		}
+	}
+	assert.That(len(got) == 1 && got[0] == want, t.Errorf, "got texts %q, wanted %q", got, want)
+}
+
+func assertDiagnosticAtLine(t *testing.T, err error, line int, msg string) {
+	t.Helper()
+	diags, _ := err.(ahmerr.Diagnostics)
+	assert.That(len(diags) == 1, t.Fatalf, "got %v, wanted 1 diagnostic", err)
+	assert.That(strings.Contains(diags[0].Message, msg), t.Errorf, "got message %q, wanted it to mention %q", diags[0].Message, msg)
+	assert.That(diags[0].Span.StartsAt().Line() == line, t.Errorf, "got diagnostic at %v, wanted line %d", diags[0].Span, line)
+}
@@ -0,0 +1,220 @@
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package html
+
+import (
+	"strconv"
+	"strings"
+
+	"github.com/szabba/ahm"
+	"github.com/szabba/ahm/ahmerr"
+)
+
+var builtins = map[string]ProcRenderer{
+	"DOCUMENT": ProcRendererFunc(renderDocument),
+	"TITLE":    element("h1", "title"),
+	"AUTHOR":   element("p", "author"),
+	"DATE":     ProcRendererFunc(renderDate),
+	"H1":       element("h1", ""),
+	"H2":       element("h2", ""),
+	"H3":       element("h3", ""),
+	"H4":       element("h4", ""),
+	"H5":       element("h5", ""),
+	"H6":       element("h6", ""),
+	"CODE":     ProcRendererFunc(renderCode),
+	"MATH":     ProcRendererFunc(renderMath),
+	"QUOTE":    container("blockquote"),
+	"UL":       container("ul"),
+	"OL":       ProcRendererFunc(renderNumbered),
+	"LI":       ProcRendererFunc(renderItem),
+	"HR":       ProcRendererFunc(renderRule),
+	"EM":       inline("em"),
+	"STRONG":   inline("strong"),
+	"TT":       inline("code"),
+	"LINK":     ProcRendererFunc(renderLink),
+	"IMG":      ProcRendererFunc(renderImage),
+}
+
+func renderDocument(w *Writer, proc *ahm.Proc) error {
+	w.Raw("<header>\n")
+	err := w.Nodes(proc.Children)
+	w.Raw("</header>\n")
+	return err
+}
+
+// element renders a proc as a block element containing its title or children.
+func element(tag, class string) ProcRenderer {
+	return ProcRendererFunc(func(w *Writer, proc *ahm.Proc) error {
+		w.Raw("<" + tag)
+		if class != "" {
+			w.Raw(` class="` + class + `"`)
+		}
+		w.Raw(">")
+		err := w.titleOrChildren(proc)
+		w.Raw("</" + tag + ">\n")
+		return err
+	})
+}
+
+// container renders a proc as a block element containing its children, as blocks.
+func container(tag string) ProcRenderer {
+	return ProcRendererFunc(func(w *Writer, proc *ahm.Proc) error {
+		w.Raw("<" + tag + ">\n")
+		err := w.Nodes(proc.Children)
+		w.Raw("</" + tag + ">\n")
+		return err
+	})
+}
+
+// inline renders a proc as an inline element containing its title.
+func inline(tag string) ProcRenderer {
+	return ProcRendererFunc(func(w *Writer, proc *ahm.Proc) error {
+		w.Raw("<" + tag + ">")
+		w.Text(proc.Title)
+		w.Raw("</" + tag + ">")
+		return nil
+	})
+}
+
+func renderNumbered(w *Writer, proc *ahm.Proc) error {
+	start := strings.TrimSpace(proc.Title)
+	if start == "" {
+		return container("ol").RenderProc(w, proc)
+	}
+	if _, err := strconv.Atoi(start); err != nil {
+		return ahmerr.Diagnosef(proc.Span, "the start of a numbered list must be a number, not %q", start)
+	}
+	w.Raw(`<ol start="` + start + "\">\n")
+	err := w.Nodes(proc.Children)
+	w.Raw("</ol>\n")
+	return err
+}
+
+// renderItem renders a list item, without a paragraph when it only contains text.
+func renderItem(w *Writer, proc *ahm.Proc) error {
+	w.Raw("<li>")
+	var err error
+	if len(proc.Children) == 1 {
+		err = w.InlineNodes(proc.Children)
+	} else {
+		w.Raw("\n")
+		err = w.Nodes(proc.Children)
+	}
+	w.Raw("</li>\n")
+	return err
+}
+
+func renderRule(w *Writer, proc *ahm.Proc) error {
+	w.Raw("<hr>\n")
+	return nil
+}
+
+func renderLink(w *Writer, proc *ahm.Proc) error {
+	url, label := splitLink(proc.Title)
+	if !safeURL(url) {
+		return ahmerr.Diagnosef(proc.Span, "unsafe link URL %q", url)
+	}
+	if label == "" {
+		label = url
+	}
+	w.Raw(`<a href="`)
+	w.Text(url)
+	w.Raw(`">`)
+	w.Text(label)
+	w.Raw("</a>")
+	return nil
+}
+
+func renderImage(w *Writer, proc *ahm.Proc) error {
+	url, alt := splitLink(proc.Title)
+	if !safeURL(url) {
+		return ahmerr.Diagnosef(proc.Span, "unsafe image URL %q", url)
+	}
+	w.Raw(`<img src="`)
+	w.Text(url)
+	w.Raw(`" alt="`)
+	w.Text(alt)
+	w.Raw(`">`)
+	return nil
+}
+
+// splitLink splits the argument of a LINK or IMG into the URL and the text.
+func splitLink(arg string) (url, text string) {
+	fields := strings.SplitN(strings.TrimSpace(arg), " ", 2)
+	if len(fields) == 1 {
+		return fields[0], ""
+	}
+	return fields[0], strings.TrimSpace(fields[1])
+}
+
+// safeURL allows relative URLs and the schemes that cannot run scripts.
+func safeURL(url string) bool {
+	colon := strings.IndexByte(url, ':')
+	if colon < 0 || strings.ContainsAny(url[:colon], "/?#") {
+		return true
+	}
+	switch strings.ToLower(url[:colon]) {
+	case "http", "https", "mailto":
+		return true
+	default:
+		return false
+	}
+}
+
+func renderDate(w *Writer, proc *ahm.Proc) error {
+	w.Raw(`<p class="date"><time>`)
+	err := w.titleOrChildren(proc)
+	w.Raw("</time></p>\n")
+	return err
+}
+
+func renderCode(w *Writer, proc *ahm.Proc) error {
+	var code []string
+	for _, child := range proc.Children {
+		text, ok := child.(*ahm.Text)
+		if !ok {
+			return ahmerr.Diagnosef(ahm.SpanOf(child), "a CODE block can only contain text")
+		}
+		code = append(code, text.Text)
+	}
+
+	w.Raw("<pre><code")
+	if lang := strings.TrimSpace(proc.Title); lang != "" {
+		w.Raw(` class="language-`)
+		w.Text(lang)
+		w.Raw(`"`)
+	}
+	w.Raw(">")
+	w.Text(strings.Join(code, "\n"))
+	w.Raw("</code></pre>\n")
+	return nil
+}
+
+func renderMath(w *Writer, proc *ahm.Proc) error {
+	tex := strings.TrimSpace(proc.Title)
+
+	if w.renderer.MathML != nil {
+		mathML, err := w.renderer.MathML(tex)
+		if err != nil {
+			return ahmerr.Diagnosef(proc.Span, "cannot convert TeX to MathML: %s", err)
+		}
+		w.Raw(mathML)
+		if !w.Inline() {
+			w.Raw("\n")
+		}
+		return nil
+	}
+
+	if w.Inline() {
+		w.Raw(`<span class="math inline">\(`)
+		w.Text(tex)
+		w.Raw(`\)</span>`)
+		return nil
+	}
+	w.Raw(`<div class="math display">\[`)
+	w.Text(tex)
+	w.Raw("\\]</div>\n")
+	return nil
+}
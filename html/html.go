@@ -0,0 +1,221 @@
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+// Package html renders documents to HTML5.
+//
+// It understands the document dialect:
+//
+//	@DOCUMENT
+//	  @TITLE A title
+//	  @AUTHOR An author
+//	  @DATE 2020-01-01
+//
+//	@H1 A header.
+//
+//	Text becomes paragraphs, with @MATH{E = mc^2} inline.
+//
+//	@CODE go
+//	  package main
+//
+// The renderer expects an evaluated document, so procs like TODAY or GO/FMT should have been replaced already.
+// All text is escaped, so a document cannot inject markup.
+package html
+
+import (
+	"bytes"
+	stdhtml "html"
+	"io"
+	"strings"
+
+	"github.com/szabba/ahm"
+	"github.com/szabba/ahm/ahmerr"
+	"github.com/szabba/ahm/ns"
+)
+
+// A ProcRenderer renders procs with a particular name.
+type ProcRenderer interface {
+	RenderProc(w *Writer, proc *ahm.Proc) error
+}
+
+type ProcRendererFunc func(w *Writer, proc *ahm.Proc) error
+
+func (f ProcRendererFunc) RenderProc(w *Writer, proc *ahm.Proc) error { return f(w, proc) }
+
+// A Renderer turns documents into HTML.
+// The zero value renders the document dialect, and fails on other procs.
+type Renderer struct {
+	// Procs renders procs by name.
+	// The renderers here take precedence over the built-in ones.
+	Procs map[string]ProcRenderer
+	// Unknown renders procs that have no other renderer.
+	// When nil, such procs are an error.
+	Unknown ProcRenderer
+	// MathML converts the TeX in a MATH proc to MathML.
+	// Its output is written unescaped.
+	// When nil, MATH procs become spans with the TeX source, for a script like MathJax to typeset.
+	MathML func(tex string) (string, error)
+}
+
+// Render writes a complete HTML5 page with the nodes as its body.
+func Render(w io.Writer, nodes []ahm.Node) error {
+	return new(Renderer).Render(w, nodes)
+}
+
+// Render writes a complete HTML5 page with the nodes as its body.
+//
+// The page title is taken from the TITLE in the DOCUMENT proc, if there is one.
+func (r *Renderer) Render(w io.Writer, nodes []ahm.Node) error {
+	out := newWriter(r)
+
+	out.Raw("<!DOCTYPE html>\n<html>\n<head>\n<meta charset=\"utf-8\">\n")
+	if title, ok := documentTitle(nodes); ok {
+		out.Raw("<title>")
+		err := out.titleOrChildren(title)
+		if err != nil {
+			return err
+		}
+		out.Raw("</title>\n")
+	}
+	out.Raw("</head>\n<body>\n")
+	err := out.Nodes(nodes)
+	if err != nil {
+		return err
+	}
+	out.Raw("</body>\n</html>\n")
+
+	_, err = out.buf.WriteTo(w)
+	return err
+}
+
+// RenderFragment writes the HTML for the nodes alone, for embedding in a page.
+func (r *Renderer) RenderFragment(w io.Writer, nodes []ahm.Node) error {
+	out := newWriter(r)
+	err := out.Nodes(nodes)
+	if err != nil {
+		return err
+	}
+	_, err = out.buf.WriteTo(w)
+	return err
+}
+
+// A Writer accumulates the HTML for a document.
+type Writer struct {
+	renderer *Renderer
+	// builtins is the package level table.
+	// Reaching it through the writer lets the table be a literal, though its renderers render children.
+	builtins map[string]ProcRenderer
+	buf      bytes.Buffer
+	inline   bool
+}
+
+func newWriter(r *Renderer) *Writer {
+	return &Writer{renderer: r, builtins: builtins}
+}
+
+// Raw writes HTML as is.
+func (w *Writer) Raw(html string) { w.buf.WriteString(html) }
+
+// Text writes escaped text.
+func (w *Writer) Text(text string) { w.buf.WriteString(stdhtml.EscapeString(text)) }
+
+// Inline reports whether the writer is inside a paragraph or another inline context.
+func (w *Writer) Inline() bool { return w.inline }
+
+// Nodes renders nodes as blocks, turning text into paragraphs.
+func (w *Writer) Nodes(nodes []ahm.Node) error {
+	wasInline := w.inline
+	w.inline = false
+	defer func() { w.inline = wasInline }()
+
+	return w.nodes(nodes)
+}
+
+// InlineNodes renders nodes as the content of a paragraph or another inline element.
+func (w *Writer) InlineNodes(nodes []ahm.Node) error {
+	wasInline := w.inline
+	w.inline = true
+	defer func() { w.inline = wasInline }()
+
+	return w.nodes(nodes)
+}
+
+func (w *Writer) nodes(nodes []ahm.Node) error {
+	for _, node := range nodes {
+		err := w.node(node)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (w *Writer) node(node ahm.Node) error {
+	switch node := node.(type) {
+	case *ahm.Text:
+		if w.inline {
+			w.Text(node.Text)
+			return nil
+		}
+		w.Raw("<p>")
+		w.Text(node.Text)
+		w.Raw("</p>\n")
+		return nil
+
+	case *ahm.Paragraph:
+		if w.inline {
+			return w.InlineNodes(node.Children)
+		}
+		w.Raw("<p>")
+		err := w.InlineNodes(node.Children)
+		w.Raw("</p>\n")
+		return err
+
+	case *ahm.Proc:
+		return w.proc(node)
+
+	default:
+		return nil
+	}
+}
+
+func (w *Writer) proc(proc *ahm.Proc) error {
+	if ns.IsImport(proc) {
+		return nil
+	}
+	if r, ok := w.renderer.Procs[proc.Name]; ok {
+		return r.RenderProc(w, proc)
+	}
+	if r, ok := w.builtins[proc.Name]; ok {
+		return r.RenderProc(w, proc)
+	}
+	if w.renderer.Unknown != nil {
+		return w.renderer.Unknown.RenderProc(w, proc)
+	}
+	return ahmerr.Diagnosef(proc.Span, "no HTML renderer for proc %s", proc.Name)
+}
+
+// titleOrChildren renders the title of a proc, or its children if it has no title.
+func (w *Writer) titleOrChildren(proc *ahm.Proc) error {
+	if title := strings.TrimSpace(proc.Title); title != "" {
+		w.Text(title)
+		return nil
+	}
+	return w.InlineNodes(proc.Children)
+}
+
+func documentTitle(nodes []ahm.Node) (*ahm.Proc, bool) {
+	for _, node := range nodes {
+		doc, ok := node.(*ahm.Proc)
+		if !ok || doc.Name != "DOCUMENT" {
+			continue
+		}
+		for _, child := range doc.Children {
+			title, ok := child.(*ahm.Proc)
+			if ok && title.Name == "TITLE" {
+				return title, true
+			}
+		}
+	}
+	return nil, false
+}
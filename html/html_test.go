@@ -0,0 +1,182 @@
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package html_test
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/szabba/ahm"
+	"github.com/szabba/ahm/ahmerr"
+	"github.com/szabba/ahm/ahmtest"
+	"github.com/szabba/ahm/assert"
+	"github.com/szabba/ahm/html"
+)
+
+func TestDocumentHeaderAndPageTitle(t *testing.T) {
+	// given
+	nodes := ahmtest.Parse(t, ahm.Config{},
+		"@DOCUMENT",
+		"  @TITLE Fish & chips",
+		"  @AUTHOR Someone",
+		"  @DATE",
+		"    2020-01-01")
+
+	// when
+	out, err := render(new(html.Renderer), nodes)
+
+	// then
+	assert.That(err == nil, t.Fatalf, "unexpected error: %s", err)
+	assertContains(t, out,
+		"<title>Fish &amp; chips</title>",
+		"<header>\n<h1 class=\"title\">Fish &amp; chips</h1>\n<p class=\"author\">Someone</p>\n<p class=\"date\"><time>2020-01-01</time></p>\n</header>")
+}
+
+func TestHeadersAndParagraphs(t *testing.T) {
+	// given
+	nodes := ahmtest.Parse(t, ahm.Config{},
+		"@H1 One",
+		"@H6 Six",
+		"Some <b>text</b>.")
+
+	// when
+	out, err := renderFragment(new(html.Renderer), nodes)
+
+	// then
+	assert.That(err == nil, t.Fatalf, "unexpected error: %s", err)
+	want := "<h1>One</h1>\n<h6>Six</h6>\n<p>Some &lt;b&gt;text&lt;/b&gt;.</p>\n"
+	assert.That(out == want, t.Errorf, "got %q, wanted %q", out, want)
+}
+
+func TestCodeBlocksHaveALanguageClass(t *testing.T) {
+	// given
+	nodes := ahmtest.Parse(t, ahm.Config{},
+		"@CODE go",
+		"  if a < b {",
+		"  \treturn",
+		"  }")
+
+	// when
+	out, err := renderFragment(new(html.Renderer), nodes)
+
+	// then
+	assert.That(err == nil, t.Fatalf, "unexpected error: %s", err)
+	want := "<pre><code class=\"language-go\">if a &lt; b {\n\treturn\n}</code></pre>\n"
+	assert.That(out == want, t.Errorf, "got %q, wanted %q", out, want)
+}
+
+func TestInlineMathIsATeXSpan(t *testing.T) {
+	// given
+	nodes := ahmtest.Parse(t, ahm.Config{}, "Energy is @MATH{E < mc^2}, roughly.")
+
+	// when
+	out, err := renderFragment(new(html.Renderer), nodes)
+
+	// then
+	assert.That(err == nil, t.Fatalf, "unexpected error: %s", err)
+	want := "<p>Energy is <span class=\"math inline\">\\(E &lt; mc^2\\)</span>, roughly.</p>\n"
+	assert.That(out == want, t.Errorf, "got %q, wanted %q", out, want)
+}
+
+func TestMathCanBeConvertedToMathML(t *testing.T) {
+	// given
+	r := &html.Renderer{MathML: func(tex string) (string, error) {
+		return "<math><mi>" + tex + "</mi></math>", nil
+	}}
+	nodes := ahmtest.Parse(t, ahm.Config{}, "@MATH x")
+
+	// when
+	out, err := renderFragment(r, nodes)
+
+	// then
+	assert.That(err == nil, t.Fatalf, "unexpected error: %s", err)
+	want := "<math><mi>x</mi></math>\n"
+	assert.That(out == want, t.Errorf, "got %q, wanted %q", out, want)
+}
+
+func TestCustomProcRenderers(t *testing.T) {
+	// given
+	r := &html.Renderer{Procs: map[string]html.ProcRenderer{
+		"NOTE": html.ProcRendererFunc(func(w *html.Writer, proc *ahm.Proc) error {
+			w.Raw("<aside>")
+			err := w.Nodes(proc.Children)
+			w.Raw("</aside>\n")
+			return err
+		}),
+	}}
+	nodes := ahmtest.Parse(t, ahm.Config{},
+		"@NOTE",
+		"  Careful!")
+
+	// when
+	out, err := renderFragment(r, nodes)
+
+	// then
+	assert.That(err == nil, t.Fatalf, "unexpected error: %s", err)
+	want := "<aside><p>Careful!</p>\n</aside>\n"
+	assert.That(out == want, t.Errorf, "got %q, wanted %q", out, want)
+}
+
+func TestUnknownProcsAreReported(t *testing.T) {
+	// given
+	nodes := ahmtest.Parse(t, ahm.Config{},
+		"Text",
+		"@WHATEVER")
+
+	// when
+	_, err := renderFragment(new(html.Renderer), nodes)
+
+	// then
+	diag, ok := err.(*ahmerr.Diagnostic)
+	assert.That(ok, t.Fatalf, "got %v, wanted a diagnostic", err)
+	assert.That(diag.Span.StartsAt().Line() == 2, t.Errorf, "got diagnostic at %v, wanted line 2", diag.Span)
+}
+
+func render(r *html.Renderer, nodes []ahm.Node) (string, error) {
+	var buf bytes.Buffer
+	err := r.Render(&buf, nodes)
+	return buf.String(), err
+}
+
+func renderFragment(r *html.Renderer, nodes []ahm.Node) (string, error) {
+	var buf bytes.Buffer
+	err := r.RenderFragment(&buf, nodes)
+	return buf.String(), err
+}
+
+func assertContains(t *testing.T, out string, parts ...string) {
+	t.Helper()
+	for _, part := range parts {
+		assert.That(strings.Contains(out, part), t.Errorf, "got %q, wanted it to contain %q", out, part)
+	}
+}
+
+func TestListsAndLinks(t *testing.T) {
+	// given
+	nodes := ahmtest.Parse(t, ahm.Config{},
+		"@OL 2",
+		"  @LI",
+		"    See @LINK{https://example.com/?a=1&b=2 the example}.")
+
+	// when
+	out, err := renderFragment(new(html.Renderer), nodes)
+
+	// then
+	assert.That(err == nil, t.Fatalf, "unexpected error: %s", err)
+	want := "<ol start=\"2\">\n<li>See <a href=\"https://example.com/?a=1&amp;b=2\">the example</a>.</li>\n</ol>\n"
+	assert.That(out == want, t.Errorf, "got %q, wanted %q", out, want)
+}
+
+func TestScriptLinksAreRejected(t *testing.T) {
+	// given
+	nodes := ahmtest.Parse(t, ahm.Config{}, "A @LINK{javascript:alert(1) link}.")
+
+	// when
+	_, err := renderFragment(new(html.Renderer), nodes)
+
+	// then
+	assert.That(err != nil, t.Errorf, "expected an error")
+}
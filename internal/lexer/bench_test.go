@@ -0,0 +1,54 @@
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package lexer_test
+
+import (
+	"bytes"
+	"io"
+	"runtime"
+	"testing"
+
+	"github.com/szabba/ahm/ahmtest"
+	"github.com/szabba/ahm/internal/lexer"
+)
+
+func BenchmarkLexReader(b *testing.B) {
+	doc := ahmtest.LargeDocument(b, 1<<20)
+	benchmarkLexer(b, doc, func() *lexer.Lexer { return lexer.New(bytes.NewReader(doc)) })
+}
+
+func BenchmarkLexBytes(b *testing.B) {
+	doc := ahmtest.LargeDocument(b, 1<<20)
+	benchmarkLexer(b, doc, func() *lexer.Lexer { return lexer.NewBytes(doc) })
+}
+
+// benchmarkLexer reports the throughput of a lexer and the allocations it makes per token.
+func benchmarkLexer(b *testing.B, doc []byte, newLexer func() *lexer.Lexer) {
+	b.SetBytes(int64(len(doc)))
+	b.ReportAllocs()
+
+	var before, after runtime.MemStats
+	runtime.ReadMemStats(&before)
+	b.ResetTimer()
+
+	tokens := 0
+	for i := 0; i < b.N; i++ {
+		lex := newLexer()
+		for {
+			_, err := lex.Next()
+			tokens++
+			if err == io.EOF {
+				break
+			}
+			if err != nil {
+				b.Fatal(err)
+			}
+		}
+	}
+
+	b.StopTimer()
+	runtime.ReadMemStats(&after)
+	b.ReportMetric(float64(after.Mallocs-before.Mallocs)/float64(tokens), "allocs/token")
+}
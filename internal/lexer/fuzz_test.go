@@ -0,0 +1,94 @@
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package lexer_test
+
+import (
+	"fmt"
+	"io"
+	"strings"
+	"testing"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/szabba/ahm/internal/lexer"
+	"github.com/szabba/ahm/internal/seeds"
+	"github.com/szabba/ahm/internal/token"
+)
+
+// FuzzLexer checks that the tokens follow one another through the whole input.
+//
+// Only indentation and the spaces before a proc argument can be left between them.
+func FuzzLexer(f *testing.F) {
+	addSeeds(f)
+	f.Fuzz(func(t *testing.T, input string) {
+		lex := lexer.New(strings.NewReader(input))
+		end := 0
+		for {
+			tok, err := lex.Next()
+			if err != nil && err != io.EOF {
+				return
+			}
+			if tok.TokenType != token.Invalid {
+				end = checkToken(t, input, end, tok)
+			}
+			if err == io.EOF {
+				break
+			}
+		}
+		checkGap(t, input, end, len(input))
+		checkSameTokens(t, input)
+	})
+}
+
+// checkSameTokens checks that the lexer gives the same tokens and errors for a string as for a reader.
+func checkSameTokens(t *testing.T, input string) {
+	fromReader, fromString := lexer.New(strings.NewReader(input)), lexer.NewString(input)
+	for i := 0; ; i++ {
+		want, wantErr := fromReader.Next()
+		got, err := fromString.Next()
+		if got != want || fmt.Sprint(err) != fmt.Sprint(wantErr) {
+			t.Fatalf("token %d of %q: got %s, %v from a string, but %s, %v from a reader", i, input, got, err, want, wantErr)
+		}
+		if wantErr != nil {
+			return
+		}
+	}
+}
+
+// checkToken checks a token against the input, given where the previous one ended, and returns where it ends.
+func checkToken(t *testing.T, input string, prevEnd int, tok token.Token) int {
+	start, end := tok.Span.Offsets()
+	if start < prevEnd || end < start || end > len(input) {
+		t.Fatalf("%s at offsets %d-%d does not follow the token ending at %d in %q", tok, start, end, prevEnd, input)
+	}
+	checkGap(t, input, prevEnd, start)
+	if utf8.ValidString(input) && tok.TokenType != token.Dedent && input[start:end] != tok.Text {
+		t.Fatalf("%s has text %q, but covers %q in %q", tok, tok.Text, input[start:end], input)
+	}
+	return end
+}
+
+// checkGap checks that only intraline whitespace is left between tokens.
+func checkGap(t *testing.T, input string, from, to int) {
+	for _, r := range input[from:to] {
+		if r == '\n' || !unicode.IsSpace(r) {
+			t.Fatalf("offsets %d-%d of %q are not covered by tokens, but hold %q", from, to, input, input[from:to])
+		}
+	}
+}
+
+func addSeeds(f *testing.F) {
+	examples, err := seeds.Examples("../../examples")
+	if err != nil {
+		f.Fatal(err)
+	}
+	cases, err := seeds.FromTests("lexer_test.go")
+	if err != nil {
+		f.Fatal(err)
+	}
+	for _, input := range append(examples, cases...) {
+		f.Add(input)
+	}
+}
@@ -4,11 +4,10 @@
 
 package lexer
 
-import (
-	"log"
+import "errors"
 
-	"github.com/szabba/ahm/assert"
-)
+// errUnderflow is returned for a dedent with no indentation level left to close.
+var errUnderflow = errors.New("dedent without a matching indent")
 
 type indentStack struct {
 	indents []string
@@ -18,9 +17,12 @@ func (stack *indentStack) pushIndent(extraIndent string) {
 	stack.indents = append(stack.indents, extraIndent)
 }
 
-func (stack *indentStack) popIndent() {
-	assert.That(len(stack.indents) >= 1, log.Panicf, "stack underflow")
+func (stack *indentStack) popIndent() error {
+	if len(stack.indents) == 0 {
+		return errUnderflow
+	}
 	stack.indents = stack.indents[:len(stack.indents)-1]
+	return nil
 }
 
 func (stack *indentStack) isNotEmpty() bool {
@@ -10,50 +10,118 @@ import (
 
 	"github.com/szabba/ahm/ahmerr"
 	"github.com/szabba/ahm/internal/token"
+	"github.com/szabba/ahm/position"
 )
 
 const (
-	NewlineRune  = '\n'
-	ProcMarkRune = '@'
+	NewlineRune     = '\n'
+	ProcMarkRune    = '@'
+	InlineOpenRune  = '{'
+	InlineCloseRune = '}'
 )
 
 type Lexer struct {
-	src       io.RuneScanner
+	src       source
 	nextToken tokenBuilder
 	indents   indentStack
-	next      func() error
+	next      func(*Lexer) error
 	err       error
+	// lineStart is true when the last token ended a line, or there was none yet.
+	lineStart bool
 }
 
 func New(input io.RuneScanner) *Lexer {
 	lex := new(Lexer)
-	lex.src = input
-	lex.next = lex.scanLineAfterIndent
+	lex.src = newLookahead(input)
+	lex.next = (*Lexer).scanLineAfterIndent
+	lex.lineStart = true
 	return lex
 }
 
+// NewBytes creates a lexer for a document held in memory.
+//
+// It gives the same tokens as New would, but their text shares memory with a single copy of the document,
+// instead of being built up rune by rune.
+func NewBytes(input []byte) *Lexer {
+	return NewString(string(input))
+}
+
+// NewString is NewBytes for a document held in a string, which it does not need to copy.
+func NewString(input string) *Lexer {
+	lex := new(Lexer)
+	lex.src = &stringSource{src: input}
+	lex.nextToken.src, lex.nextToken.fromSource = input, true
+	lex.next = (*Lexer).scanLineAfterIndent
+	lex.lineStart = true
+	return lex
+}
+
+// A Checkpoint is the state of a lexer at the start of a line.
+type Checkpoint struct {
+	Position position.Position
+	// Indents are the indentation levels of the enclosing blocks, outermost first.
+	Indents []string
+}
+
+// Resume creates a lexer that continues from a checkpoint.
+// The input must start at the line the checkpoint was taken at.
+func Resume(input io.RuneScanner, at Checkpoint) *Lexer {
+	lex := New(input)
+	lex.nextToken.span = at.Position.StartSpan()
+	lex.indents.indents = append([]string(nil), at.Indents...)
+	if at.Position != position.First() {
+		lex.next = (*Lexer).tryToScanIndent
+	}
+	return lex
+}
+
+// Checkpoint describes the state of the lexer, when it is at the start of a line.
+func (lex *Lexer) Checkpoint() (Checkpoint, bool) {
+	if !lex.lineStart || lex.err != nil {
+		return Checkpoint{}, false
+	}
+	indents := append([]string(nil), lex.indents.indents...)
+	return Checkpoint{Position: lex.nextToken.span.EndsBefore(), Indents: indents}, true
+}
+
 func (lex *Lexer) Next() (token.Token, error) {
 	if lex.err != nil {
 		lex.next = nil
 		return token.Token{}, lex.err
 	}
-	lex.err = lex.next()
+	if lex.next == nil {
+		lex.err = io.EOF
+		return token.Token{}, lex.err
+	}
+	lex.err = lex.next(lex)
 	tok := lex.nextToken.build()
-	lex.adjustCurrentIndent(tok)
+	if err := lex.adjustCurrentIndent(tok); err != nil {
+		lex.err = ahmerr.Internalf(tok.Span.In(""), "%s", err)
+	}
+	lex.lineStart = tok.TokenType == token.Newline
 	return tok, lex.err
 }
 
-func (lex *Lexer) adjustCurrentIndent(tok token.Token) {
+func (lex *Lexer) adjustCurrentIndent(tok token.Token) error {
 	switch tok.TokenType {
 	case token.Indent:
 		lex.indents.pushIndent(tok.Text)
 	case token.Dedent:
-		lex.indents.popIndent()
+		return lex.indents.popIndent()
 	}
+	return nil
 }
 
 func (lex *Lexer) tryToScanIndent() error {
-	lex.next = lex.scanLineAfterIndent
+	lex.next = (*Lexer).scanLineAfterIndent
+
+	blank, err := lex.atBlankLine()
+	if err != nil && err != io.EOF {
+		return err
+	}
+	if blank {
+		return lex.scanBlankLine()
+	}
 
 	indents := lex.indents.indents
 	levelsMatched, err := lex.acceptStrings(indents...)
@@ -62,7 +130,7 @@ func (lex *Lexer) tryToScanIndent() error {
 	}
 
 	if levelsMatched < len(indents) {
-		return lex.produceDedents(len(indents) - levelsMatched)()
+		return lex.produceDedents(len(indents) - levelsMatched)(lex)
 	}
 
 	lex.startToken(token.Indent)
@@ -78,30 +146,55 @@ func (lex *Lexer) tryToScanIndent() error {
 	return lex.scanLineAfterIndent()
 }
 
-func (lex *Lexer) produceDedents(n int) func() error {
-	return func() error {
+// atBlankLine checks whether the rest of the line is whitespace, followed by a newline.
+func (lex *Lexer) atBlankLine() (bool, error) {
+	for i := 0; ; i++ {
+		r, err := lex.src.peekAt(i)
+		if err != nil {
+			return false, err
+		}
+		if r == NewlineRune {
+			return true, nil
+		}
+		if !isIntralineSpace(r) {
+			return false, nil
+		}
+	}
+}
+
+// scanBlankLine produces only a newline for a blank line, whatever its indentation.
+func (lex *Lexer) scanBlankLine() error {
+	err := lex.skipWhile(isIntralineSpace)
+	if err != nil {
+		return err
+	}
+	return lex.scanNewline()
+}
+
+func (lex *Lexer) produceDedents(n int) func(*Lexer) error {
+	return func(lex *Lexer) error {
 		lex.next = lex.nextWhenDedentsLeft(n)
 		lex.startToken(token.Dedent)
 		return nil
 	}
 }
 
-func (lex *Lexer) produceFinalDedents(n int) func() error {
-	return func() error {
+func (lex *Lexer) produceFinalDedents(n int) func(*Lexer) error {
+	return func(lex *Lexer) error {
 		lex.next = lex.nextWhenFinalDedentsLeft(n)
 		lex.startToken(token.Dedent)
 		return lex.finalDedentError(n)
 	}
 }
 
-func (lex *Lexer) nextWhenDedentsLeft(n int) func() error {
+func (lex *Lexer) nextWhenDedentsLeft(n int) func(*Lexer) error {
 	if n > 1 {
 		return lex.produceDedents(n - 1)
 	}
-	return lex.scanLineAfterIndent
+	return (*Lexer).scanLineAfterIndent
 }
 
-func (lex *Lexer) nextWhenFinalDedentsLeft(n int) func() error {
+func (lex *Lexer) nextWhenFinalDedentsLeft(n int) func(*Lexer) error {
 	if n > 1 {
 		return lex.produceFinalDedents(n - 1)
 	}
@@ -120,16 +213,52 @@ func (lex *Lexer) scanLineAfterIndent() error {
 	if err != nil && err != io.EOF {
 		return err
 	}
-	if r == ProcMarkRune {
-		return lex.scanProcMark()
+	if r != ProcMarkRune {
+		return lex.scanText()
 	}
-	return lex.scanText()
+	inline, err := lex.atInlineProc()
+	if err != nil && err != io.EOF {
+		return err
+	}
+	if inline {
+		return lex.scanInlineProcMark()
+	}
+	return lex.scanProcMark()
 }
 
 func (lex *Lexer) scanText() error {
-	lex.next = lex.scanNewline
+	lex.next = (*Lexer).scanNewline
 	lex.startToken(token.Text)
-	err := lex.acceptWhile(isNotNewline)
+	for {
+		err := lex.acceptWhile(isTextRune)
+		if err != nil {
+			return lex.endLine(err)
+		}
+
+		inline, err := lex.atInlineProc()
+		if err != nil && err != io.EOF {
+			return err
+		}
+		if inline {
+			lex.next = (*Lexer).scanInlineProcMark
+			return nil
+		}
+
+		r, err := lex.peekRune()
+		if err != nil || r != ProcMarkRune {
+			return err
+		}
+		err = lex.acceptOne(ProcMarkRune)
+		if err != nil {
+			return err
+		}
+	}
+}
+
+// endLine handles an error that ended the current line.
+//
+// When the input ends inside an indented block, the dedents that close it still need to be produced.
+func (lex *Lexer) endLine(err error) error {
 	if err == io.EOF && lex.indents.isNotEmpty() {
 		lex.next = lex.produceFinalDedents(lex.indents.count())
 		return nil
@@ -137,14 +266,112 @@ func (lex *Lexer) scanText() error {
 	return err
 }
 
+// atInlineProc checks whether the input continues with an inline proc: a proc mark, a name and an opening brace.
+func (lex *Lexer) atInlineProc() (bool, error) {
+	for i := 0; ; i++ {
+		r, err := lex.src.peekAt(i)
+		if err != nil {
+			return false, err
+		}
+
+		switch {
+		case i == 0:
+			if r != ProcMarkRune {
+				return false, nil
+			}
+		case r == InlineOpenRune:
+			return i > 1, nil
+		case !isInlineNameRune(r):
+			return false, nil
+		}
+	}
+}
+
+func (lex *Lexer) scanInlineProcMark() error {
+	lex.next = (*Lexer).scanInlineProcName
+	lex.startToken(token.ProcMark)
+	return lex.acceptOne(ProcMarkRune)
+}
+
+func (lex *Lexer) scanInlineProcName() error {
+	lex.next = (*Lexer).scanInlineOpen
+	lex.startToken(token.ProcName)
+	return lex.acceptWhile(isInlineNameRune)
+}
+
+func (lex *Lexer) scanInlineOpen() error {
+	lex.next = (*Lexer).scanInlineArg
+	lex.startToken(token.InlineOpen)
+	return lex.acceptOne(InlineOpenRune)
+}
+
+// scanInlineArg scans everything up to the brace closing the inline proc.
+//
+// Braces nested within the argument must be balanced.
+// An argument left unclosed at the end of a line stops before the newline,
+// which the parser then rejects in place of the closing brace.
+func (lex *Lexer) scanInlineArg() error {
+	lex.next = (*Lexer).scanNewline
+	lex.startToken(token.InlineArg)
+	depth := 0
+	for {
+		r, size, err := lex.src.ReadRune()
+		if err != nil {
+			return lex.endLine(err)
+		}
+
+		switch {
+		case r == NewlineRune:
+			return lex.src.UnreadRune()
+		case r == InlineCloseRune && depth == 0:
+			lex.next = (*Lexer).scanInlineClose
+			return lex.src.UnreadRune()
+		case r == InlineCloseRune:
+			depth--
+		case r == InlineOpenRune:
+			depth++
+		}
+
+		lex.nextToken.acceptRune(r, size)
+	}
+}
+
+func (lex *Lexer) scanInlineClose() error {
+	lex.startToken(token.InlineClose)
+	err := lex.acceptOne(InlineCloseRune)
+	if err != nil {
+		return err
+	}
+
+	r, err := lex.peekRune()
+	if err != nil {
+		return lex.endLine(err)
+	}
+
+	inline, err := lex.atInlineProc()
+	if err != nil && err != io.EOF {
+		return err
+	}
+
+	switch {
+	case inline:
+		lex.next = (*Lexer).scanInlineProcMark
+	case r == NewlineRune:
+		lex.next = (*Lexer).scanNewline
+	default:
+		lex.next = (*Lexer).scanText
+	}
+	return nil
+}
+
 func (lex *Lexer) scanProcMark() error {
-	lex.next = lex.scanProcName
+	lex.next = (*Lexer).scanProcName
 	lex.startToken(token.ProcMark)
 	return lex.acceptOne(ProcMarkRune)
 }
 
 func (lex *Lexer) scanProcName() error {
-	lex.next = lex.scanProcArg
+	lex.next = (*Lexer).scanProcArg
 	lex.startToken(token.ProcName)
 	err := lex.acceptWhile(isNotSpace)
 	if err != nil && err != io.EOF {
@@ -158,18 +385,14 @@ func (lex *Lexer) scanProcArg() error {
 	if err != nil && err != io.EOF {
 		return err
 	}
-	lex.next = lex.scanNewline
+	lex.next = (*Lexer).scanNewline
 	lex.startToken(token.ProcArg)
 	err = lex.acceptWhile(isNotNewline)
-	if err == io.EOF && lex.indents.isNotEmpty() {
-		lex.next = lex.produceFinalDedents(lex.indents.count())
-		return nil
-	}
-	return err
+	return lex.endLine(err)
 }
 
 func (lex *Lexer) scanNewline() error {
-	lex.next = lex.tryToScanIndent
+	lex.next = (*Lexer).tryToScanIndent
 	lex.startToken(token.Newline)
 	return lex.acceptOne(NewlineRune)
 }
@@ -187,7 +410,7 @@ func (lex *Lexer) startToken(typ token.TokenType) { lex.nextToken.startToken(typ
 
 func (lex *Lexer) acceptWhile(p func(rune) bool) error {
 	for {
-		r, _, err := lex.src.ReadRune()
+		r, size, err := lex.src.ReadRune()
 
 		if err != nil {
 			return err
@@ -196,7 +419,7 @@ func (lex *Lexer) acceptWhile(p func(rune) bool) error {
 			return lex.src.UnreadRune()
 		}
 
-		lex.nextToken.acceptRune(r)
+		lex.nextToken.acceptRune(r, size)
 	}
 }
 
@@ -224,14 +447,14 @@ func (lex *Lexer) acceptStrings(strs ...string) (n int, err error) {
 }
 
 func (lex *Lexer) acceptOne(want rune) error {
-	r, _, err := lex.src.ReadRune()
+	r, size, err := lex.src.ReadRune()
 	if err != nil {
 		return err
 	}
 	if r != want {
 		return ahmerr.NewUnexpectedRuneError(r, want)
 	}
-	lex.nextToken.acceptRune(r)
+	lex.nextToken.acceptRune(r, size)
 	return nil
 }
 
@@ -249,3 +472,9 @@ func isNotSpace(r rune) bool { return !unicode.IsSpace(r) }
 func isIntralineSpace(r rune) bool { return r != NewlineRune && unicode.IsSpace(r) }
 
 func isNotNewline(r rune) bool { return r != NewlineRune }
+
+func isTextRune(r rune) bool { return r != NewlineRune && r != ProcMarkRune }
+
+func isInlineNameRune(r rune) bool {
+	return isNotSpace(r) && r != ProcMarkRune && r != InlineOpenRune && r != InlineCloseRune
+}
@@ -9,6 +9,7 @@ import (
 	"strings"
 	"testing"
 
+	. "github.com/szabba/ahm/ahmtest"
 	"github.com/szabba/ahm/assert"
 	"github.com/szabba/ahm/internal/lexer"
 	"github.com/szabba/ahm/internal/token"
@@ -25,7 +26,7 @@ func TestLineOfText(t *testing.T) {
 
 func TestMultipleLinesOfText(t *testing.T) {
 	expectTokens(
-		t, multiline(
+		t, Lines(
 			"First line",
 			"Second line",
 		),
@@ -58,7 +59,7 @@ func TestSingleLineProcWithoutArg(t *testing.T) {
 
 func TestProcWithTextChild(t *testing.T) {
 	expectTokens(
-		t, multiline(
+		t, Lines(
 			"@proc",
 			"  Child text",
 		),
@@ -75,7 +76,7 @@ func TestProcWithTextChild(t *testing.T) {
 
 func TestProcWithProcChild(t *testing.T) {
 	expectTokens(
-		t, multiline(
+		t, Lines(
 			"@parent",
 			"  @child",
 		),
@@ -94,7 +95,7 @@ func TestProcWithProcChild(t *testing.T) {
 
 func TestProcWithTextGrandchild(t *testing.T) {
 	expectTokens(
-		t, multiline(
+		t, Lines(
 			"@grandparent",
 			"  @parent",
 			"      child",
@@ -118,7 +119,7 @@ func TestProcWithTextGrandchild(t *testing.T) {
 
 func TestProcWithChildThenTextSibling(t *testing.T) {
 	expectTokens(
-		t, multiline(
+		t, Lines(
 			"@parent",
 			"  child",
 			"aunt",
@@ -138,7 +139,7 @@ func TestProcWithChildThenTextSibling(t *testing.T) {
 
 func TestProcWithTextGrandchildThenTextSibling(t *testing.T) {
 	expectTokens(
-		t, multiline(
+		t, Lines(
 			"@grandparent",
 			"  @parent",
 			"      child",
@@ -163,11 +164,147 @@ func TestProcWithTextGrandchildThenTextSibling(t *testing.T) {
 	)
 }
 
-func multiline(lines ...string) string { return strings.Join(lines, "\n") }
+func TestInlineProcInText(t *testing.T) {
+	expectTokens(
+		t, "is @MATH{E = m c^2}, right",
+
+		token.Token{token.Text, span(1, 1, 1, 4), "is "},
+		token.Token{token.ProcMark, span(1, 4, 1, 5), "@"},
+		token.Token{token.ProcName, span(1, 5, 1, 9), "MATH"},
+		token.Token{token.InlineOpen, span(1, 9, 1, 10), "{"},
+		token.Token{token.InlineArg, span(1, 10, 1, 19), "E = m c^2"},
+		token.Token{token.InlineClose, span(1, 19, 1, 20), "}"},
+		token.Token{token.Text, span(1, 20, 1, 27), ", right"},
+	)
+}
+
+func TestInlineProcStartingALine(t *testing.T) {
+	expectTokens(
+		t, Lines(
+			"@EM{a}",
+			"b",
+		),
+
+		token.Token{token.ProcMark, span(1, 1, 1, 2), "@"},
+		token.Token{token.ProcName, span(1, 2, 1, 4), "EM"},
+		token.Token{token.InlineOpen, span(1, 4, 1, 5), "{"},
+		token.Token{token.InlineArg, span(1, 5, 1, 6), "a"},
+		token.Token{token.InlineClose, span(1, 6, 1, 7), "}"},
+		token.Token{token.Newline, span(1, 7, 2, 1), "\n"},
+		token.Token{token.Text, span(2, 1, 2, 2), "b"},
+	)
+}
+
+func TestInlineProcArgWithNestedBraces(t *testing.T) {
+	expectTokens(
+		t, "@MATH{\\frac{1}{2}}",
+
+		token.Token{token.ProcMark, span(1, 1, 1, 2), "@"},
+		token.Token{token.ProcName, span(1, 2, 1, 6), "MATH"},
+		token.Token{token.InlineOpen, span(1, 6, 1, 7), "{"},
+		token.Token{token.InlineArg, span(1, 7, 1, 18), "\\frac{1}{2}"},
+		token.Token{token.InlineClose, span(1, 18, 1, 19), "}"},
+	)
+}
+
+func TestProcMarkWithoutBraceStaysInText(t *testing.T) {
+	expectTokens(t, "mail me @ home or @work", token.Token{token.Text, span(1, 1, 1, 24), "mail me @ home or @work"})
+}
+
+func TestBlankLineProducesOnlyANewline(t *testing.T) {
+	expectTokens(
+		t, Lines(
+			"@parent",
+			"  a",
+			" ",
+			"  b",
+		),
+
+		token.Token{token.ProcMark, span(1, 1, 1, 2), "@"},
+		token.Token{token.ProcName, span(1, 2, 1, 8), "parent"},
+		token.Token{token.ProcArg, span(1, 8, 1, 8), ""},
+		token.Token{token.Newline, span(1, 8, 2, 1), "\n"},
+		token.Token{token.Indent, span(2, 1, 2, 3), "  "},
+		token.Token{token.Text, span(2, 3, 2, 4), "a"},
+		token.Token{token.Newline, span(2, 4, 3, 1), "\n"},
+		token.Token{token.Newline, span(3, 2, 4, 1), "\n"},
+		token.Token{token.Text, span(4, 3, 4, 4), "b"},
+		token.Token{token.Dedent, span(4, 4, 4, 4), ""},
+	)
+}
+
+func TestOffsetsCountTheBytesOfInvalidUTF8(t *testing.T) {
+	// given
+	lex := lexer.New(strings.NewReader("a\xffb\n@P"))
+
+	// when
+	text, _ := lex.Next()
+	newline, _ := lex.Next()
+
+	// then
+	start, end := text.Span.Offsets()
+	assert.That(start == 0 && end == 3, t.Errorf, "got text at offsets %d-%d, wanted 0-3", start, end)
+	assert.That(newline.Span.EndsBefore().Offset() == 4, t.Errorf, "got the newline ending at offset %d, wanted 4", newline.Span.EndsBefore().Offset())
+}
+
+func TestResumingFromACheckpointGivesTheSameTokens(t *testing.T) {
+	// given
+	input := Lines(
+		"@grandparent",
+		"  @parent",
+		"      child",
+		"",
+		"      more @EM{text}",
+		"  aunt",
+		"grandaunt",
+	)
+	lines := strings.SplitAfter(input, "\n")
+
+	full := lexer.New(strings.NewReader(input))
+	var all []token.Token
+	var checkpoints []lexer.Checkpoint
+	for {
+		if at, ok := full.Checkpoint(); ok {
+			checkpoints = append(checkpoints, at)
+		}
+		tok, err := full.Next()
+		if tok.TokenType != token.Invalid {
+			all = append(all, tok)
+		}
+		if err != nil {
+			break
+		}
+	}
+	assert.That(len(checkpoints) == len(lines), t.Fatalf, "got %d checkpoints, wanted one per line", len(checkpoints))
+
+	for _, at := range checkpoints {
+		// when
+		rest := strings.Join(lines[at.Position.Line()-1:], "")
+		resumed := lexer.Resume(strings.NewReader(rest), at)
+
+		// then
+		var got []token.Token
+		for {
+			tok, err := resumed.Next()
+			if tok.TokenType != token.Invalid {
+				got = append(got, tok)
+			}
+			if err != nil {
+				break
+			}
+		}
+		want := all[len(all)-len(got):]
+		for i := range got {
+			assert.That(got[i] == want[i], t.Fatalf, "from %s: token %d: got %s, wanted %s", at.Position, i, got[i], want[i])
+		}
+		assert.That(len(got) > 0 && got[0].Span.StartsAt().Line() == at.Position.Line(), t.Errorf, "from %s: got tokens %v", at.Position, got)
+	}
+}
 
 func expectTokens(t *testing.T, rawInput string, tokens ...token.Token) {
 
 	lexer := lexer.New(strings.NewReader(rawInput))
+	file := position.NewFile("", []byte(rawInput))
 
 	var (
 		tok token.Token
@@ -176,6 +313,7 @@ func expectTokens(t *testing.T, rawInput string, tokens ...token.Token) {
 
 	for i, want := range tokens {
 		tok, err = lexer.Next()
+		want.Span = withOffsets(file, want.Span)
 
 		t.Logf("token %d: token = %s", i, tok)
 		assert.That(err == nil, t.Logf, "token %d: error: %s", i, err)
@@ -197,8 +335,45 @@ func expectTokens(t *testing.T, rawInput string, tokens ...token.Token) {
 	assert.That(err == io.EOF, t.Fatalf, "got error %q, wanted %q", err, io.EOF)
 }
 
+// withOffsets fills in the byte offsets of a span given by lines and columns.
+func withOffsets(file *position.File, span position.Span) position.Span {
+	start, end := span.StartsAt(), span.EndsBefore()
+	return position.SpanFromTo(file.PositionOf(start.Line(), start.Column()), file.PositionOf(end.Line(), end.Column()))
+}
+
+// span is a span given by lines and columns, with the offsets left for withOffsets to fill in.
 func span(startLine, startCol, endLine, endCol int) position.Span {
 	return position.SpanFromTo(
-		position.PositionOf(startLine, startCol),
-		position.PositionOf(endLine, endCol))
+		position.PositionAt(startLine, startCol, 0),
+		position.PositionAt(endLine, endCol, 0))
+}
+
+func TestLexingBytesGivesTheSameTokensAsLexingAReader(t *testing.T) {
+	// given
+	inputs := []string{
+		Lines("@A title", "  text \xff with @EM{inline} proc", "", "    deeper", "@B"),
+		Lines("\xf0\x9f", "  \xc3@X{\xff}", "�"),
+	}
+	gen := NewGenerator(7)
+	for i := 0; i < 100; i++ {
+		doc, err := gen.Document()
+		assert.That(err == nil, t.Fatalf, "cannot generate a document: %s", err)
+		inputs = append(inputs, doc)
+	}
+
+	for _, input := range inputs {
+		fromReader, fromBytes := lexer.New(strings.NewReader(input)), lexer.NewBytes([]byte(input))
+		for i := 0; ; i++ {
+			// when
+			got, err := fromBytes.Next()
+
+			// then
+			want, wantErr := fromReader.Next()
+			assert.That(got == want, t.Fatalf, "token %d of %q: got %s, wanted %s", i, input, got, want)
+			assert.That(err == wantErr, t.Fatalf, "token %d of %q: got error %v, wanted %v", i, input, err, wantErr)
+			if err != nil {
+				break
+			}
+		}
+	}
 }
@@ -0,0 +1,73 @@
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package lexer
+
+import (
+	"io"
+)
+
+// A source is what the lexer reads runes from.
+type source interface {
+	io.RuneScanner
+	// peekAt returns the rune that will be read after the next i, without consuming any.
+	// It returns an error when the input ends, or fails, before that rune.
+	peekAt(i int) (rune, error)
+}
+
+// lookahead is an io.RuneScanner that can also peek an arbitrary number of
+// runes ahead without consuming them.
+//
+// It keeps the number of bytes each rune took in the input, which is 1 for
+// invalid UTF-8 decoded as utf8.RuneError.
+type lookahead struct {
+	src   io.RuneReader
+	ahead []sizedRune
+	// last has a zero size when there is no rune to unread.
+	last sizedRune
+}
+
+type sizedRune struct {
+	r    rune
+	size int
+}
+
+func newLookahead(src io.RuneReader) *lookahead {
+	return &lookahead{src: src}
+}
+
+func (la *lookahead) ReadRune() (rune, int, error) {
+	if len(la.ahead) > 0 {
+		la.last = la.ahead[0]
+		la.ahead = la.ahead[1:]
+		return la.last.r, la.last.size, nil
+	}
+	r, size, err := la.src.ReadRune()
+	if err != nil {
+		la.last = sizedRune{}
+		return r, size, err
+	}
+	la.last = sizedRune{r, size}
+	return r, size, nil
+}
+
+func (la *lookahead) UnreadRune() error {
+	if la.last.size == 0 {
+		return errDoubleUnread
+	}
+	la.ahead = append([]sizedRune{la.last}, la.ahead...)
+	la.last = sizedRune{}
+	return nil
+}
+
+func (la *lookahead) peekAt(i int) (rune, error) {
+	for len(la.ahead) <= i {
+		r, size, err := la.src.ReadRune()
+		if err != nil {
+			return r, err
+		}
+		la.ahead = append(la.ahead, sizedRune{r, size})
+	}
+	return la.ahead[i].r, nil
+}
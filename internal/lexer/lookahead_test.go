@@ -0,0 +1,59 @@
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package lexer
+
+import (
+	"bufio"
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/szabba/ahm/assert"
+)
+
+var sources = map[string]func(string) source{
+	"lookahead": func(s string) source { return newLookahead(bufio.NewReader(strings.NewReader(s))) },
+	"string":    func(s string) source { return &stringSource{src: s} },
+}
+
+func TestSourcesUnreadOnlyTheLastRuneRead(t *testing.T) {
+	for name, newSource := range sources {
+		t.Run(name, func(t *testing.T) {
+			// given
+			src := newSource("ab")
+			src.ReadRune()
+
+			// when
+			first := src.UnreadRune()
+			second := src.UnreadRune()
+
+			// then
+			assert.That(first == nil, t.Fatalf, "unexpected error: %s", first)
+			assert.That(second != nil, t.Errorf, "a second unread succeeded")
+			r, _, _ := src.ReadRune()
+			assert.That(r == 'a', t.Errorf, "got %q, wanted %q", r, 'a')
+		})
+	}
+}
+
+func TestSourcesCannotUnreadAfterAFailedRead(t *testing.T) {
+	for name, newSource := range sources {
+		t.Run(name, func(t *testing.T) {
+			// given
+			src := newSource("a")
+			src.ReadRune()
+			_, _, err := src.ReadRune()
+			assert.That(err == io.EOF, t.Fatalf, "got error %v, wanted %v", err, io.EOF)
+
+			// when
+			err = src.UnreadRune()
+
+			// then
+			assert.That(err != nil, t.Errorf, "unread after a failed read succeeded")
+			_, _, err = src.ReadRune()
+			assert.That(err == io.EOF, t.Errorf, "got error %v, wanted %v", err, io.EOF)
+		})
+	}
+}
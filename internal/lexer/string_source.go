@@ -0,0 +1,63 @@
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package lexer
+
+import (
+	"errors"
+	"io"
+	"unicode/utf8"
+)
+
+var errDoubleUnread = errors.New("only the last rune read can be unread")
+
+// stringSource reads runes from a string, without allocating.
+//
+// Invalid UTF-8 is decoded the same way as by the readers New works with:
+// as utf8.RuneError, one byte at a time.
+type stringSource struct {
+	src       string
+	pos, last int
+	// The last peek skipped peeked runes after pos, which ended at peekEnd.
+	// That lets a series of peeks go through the input only once.
+	peekFrom, peeked, peekEnd int
+}
+
+func (s *stringSource) ReadRune() (rune, int, error) {
+	if s.pos >= len(s.src) {
+		s.last = 0
+		return 0, 0, io.EOF
+	}
+	r, size := rune(s.src[s.pos]), 1
+	if r >= utf8.RuneSelf {
+		r, size = utf8.DecodeRuneInString(s.src[s.pos:])
+	}
+	s.pos += size
+	s.last = size
+	return r, size, nil
+}
+
+func (s *stringSource) UnreadRune() error {
+	if s.last == 0 {
+		return errDoubleUnread
+	}
+	s.pos -= s.last
+	s.last = 0
+	return nil
+}
+
+func (s *stringSource) peekAt(i int) (rune, error) {
+	if s.peekFrom != s.pos || s.peeked > i {
+		s.peekFrom, s.peeked, s.peekEnd = s.pos, 0, s.pos
+	}
+	for ; s.peeked < i && s.peekEnd < len(s.src); s.peeked++ {
+		_, size := utf8.DecodeRuneInString(s.src[s.peekEnd:])
+		s.peekEnd += size
+	}
+	if s.peekEnd >= len(s.src) {
+		return 0, io.EOF
+	}
+	r, _ := utf8.DecodeRuneInString(s.src[s.peekEnd:])
+	return r, nil
+}
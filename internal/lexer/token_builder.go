@@ -6,6 +6,8 @@ package lexer
 
 import (
 	"bytes"
+	"strings"
+	"unicode/utf8"
 
 	"github.com/szabba/ahm/internal/token"
 	"github.com/szabba/ahm/position"
@@ -16,11 +18,19 @@ type tokenBuilder struct {
 	buf                bytes.Buffer
 	span               position.Span
 	lastRuneWasNewline bool
+
+	// With fromSource set, the text of a token is cut out of src, instead of being written to buf.
+	// The span offsets locate it there.
+	src        string
+	fromSource bool
+	// invalid is true when the token holds invalid UTF-8, which is not read back as is.
+	invalid bool
 }
 
 func (builder *tokenBuilder) startToken(typ token.TokenType) {
 	builder.typ = typ
 	builder.buf.Reset()
+	builder.invalid = false
 	builder.span = builder.span.EndsBefore().StartSpan()
 }
 
@@ -37,27 +47,47 @@ func (builder *tokenBuilder) isEmpty() bool {
 }
 
 func (builder *tokenBuilder) build() token.Token {
-	tok := token.Token{TokenType: builder.typ, Span: builder.span, Text: builder.buf.String()}
+	tok := token.Token{TokenType: builder.typ, Span: builder.span, Text: builder.text()}
 	builder.startSkipping()
 	return tok
 }
 
-func (builder *tokenBuilder) acceptRune(r rune) {
-	builder.advancePosition(r)
+func (builder *tokenBuilder) text() string {
+	if !builder.fromSource {
+		return builder.buf.String()
+	}
+	start, end := builder.span.Offsets()
+	text := builder.src[start:end]
+	if !builder.invalid {
+		return text
+	}
+	var fixed strings.Builder
+	for _, r := range text {
+		fixed.WriteRune(r)
+	}
+	return fixed.String()
+}
+
+func (builder *tokenBuilder) acceptRune(r rune, size int) {
+	builder.advancePosition(r, size)
 
 	if builder.isNotBuilding() {
 		return
 	}
 
+	if builder.fromSource {
+		builder.invalid = builder.invalid || (r == utf8.RuneError && size == 1)
+		return
+	}
 	builder.buf.WriteRune(r)
 }
 
-func (builder *tokenBuilder) advancePosition(r rune) {
-	builder.span = builder.nextSpan(r)
+func (builder *tokenBuilder) advancePosition(r rune, size int) {
+	builder.span = builder.nextSpan(r, size)
 }
 
-func (builder *tokenBuilder) nextSpan(r rune) position.Span {
-	span := builder.span.Add(r)
+func (builder *tokenBuilder) nextSpan(r rune, size int) position.Span {
+	span := builder.span.Advance(r, size)
 	if builder.isNotBuilding() {
 		span = span.EndsBefore().StartSpan()
 	}
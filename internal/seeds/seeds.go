@@ -0,0 +1,107 @@
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+// Package seeds finds inputs to seed fuzz tests with.
+package seeds
+
+import (
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// Examples reads the .ahm files in a directory.
+func Examples(dir string) ([]string, error) {
+	paths, err := filepath.Glob(filepath.Join(dir, "*.ahm"))
+	if err != nil {
+		return nil, err
+	}
+	var inputs []string
+	for _, path := range paths {
+		src, err := os.ReadFile(path)
+		if err != nil {
+			return nil, err
+		}
+		inputs = append(inputs, string(src))
+	}
+	return inputs, nil
+}
+
+// FromTests finds the inputs of the test cases in a Go test file.
+//
+// Those are the strings assigned to a variable named rawInput, passed to strings.NewReader or passed to expectTokens after the *testing.T.
+// A string is either a literal, or a call to ahmtest.Lines with literal arguments.
+func FromTests(path string) ([]string, error) {
+	file, err := parser.ParseFile(token.NewFileSet(), path, nil, 0)
+	if err != nil {
+		return nil, err
+	}
+
+	var inputs []string
+	add := func(expr ast.Expr) {
+		if s, ok := stringValue(expr); ok {
+			inputs = append(inputs, s)
+		}
+	}
+	ast.Inspect(file, func(node ast.Node) bool {
+		switch node := node.(type) {
+		case *ast.AssignStmt:
+			for i, lhs := range node.Lhs {
+				if id, ok := lhs.(*ast.Ident); ok && id.Name == "rawInput" && i < len(node.Rhs) {
+					add(node.Rhs[i])
+				}
+			}
+		case *ast.CallExpr:
+			switch name := calleeName(node); {
+			case name == "NewReader" && len(node.Args) == 1:
+				add(node.Args[0])
+			case name == "expectTokens" && len(node.Args) >= 2:
+				add(node.Args[1])
+			}
+		}
+		return true
+	})
+	return inputs, nil
+}
+
+func stringValue(expr ast.Expr) (string, bool) {
+	switch expr := expr.(type) {
+	case *ast.BasicLit:
+		if expr.Kind != token.STRING {
+			return "", false
+		}
+		s, err := strconv.Unquote(expr.Value)
+		return s, err == nil
+	case *ast.CallExpr:
+		if calleeName(expr) != "Lines" {
+			return "", false
+		}
+		lines := make([]string, len(expr.Args))
+		for i, arg := range expr.Args {
+			line, ok := stringValue(arg)
+			if !ok {
+				return "", false
+			}
+			lines[i] = line
+		}
+		return strings.Join(lines, "\n"), true
+	default:
+		return "", false
+	}
+}
+
+func calleeName(call *ast.CallExpr) string {
+	switch fun := call.Fun.(type) {
+	case *ast.Ident:
+		return fun.Name
+	case *ast.SelectorExpr:
+		return fun.Sel.Name
+	default:
+		return ""
+	}
+}
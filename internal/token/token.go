@@ -24,6 +24,9 @@ const (
 	ProcName
 	ProcArg
 	Text
+	InlineOpen
+	InlineArg
+	InlineClose
 )
 
 type Token struct {
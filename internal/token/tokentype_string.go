@@ -4,9 +4,9 @@ package token
 
 import "strconv"
 
-const _TokenType_name = "InvalidIndentDedentMisdentNewlineProcMarkProcNameProcArgText"
+const _TokenType_name = "InvalidIndentDedentMisdentNewlineProcMarkProcNameProcArgTextInlineOpenInlineArgInlineClose"
 
-var _TokenType_index = [...]uint8{0, 7, 13, 19, 26, 33, 41, 49, 56, 60}
+var _TokenType_index = [...]uint8{0, 7, 13, 19, 26, 33, 41, 49, 56, 60, 70, 79, 90}
 
 func (i TokenType) String() string {
 	if i < 0 || i >= TokenType(len(_TokenType_index)-1) {
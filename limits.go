@@ -0,0 +1,120 @@
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package ahm
+
+import (
+	"io"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/szabba/ahm/ahmerr"
+	"github.com/szabba/ahm/position"
+)
+
+// Limits keep a parser from using too many resources on untrusted input.
+// A parser that goes over a limit fails with an *ahmerr.LimitError.
+//
+// Zero fields mean no limit.
+type Limits struct {
+	// MaxDepth is how deeply blocks can be nested.
+	MaxDepth int
+	// MaxLineLength is the number of bytes a line can have, not counting the newline.
+	MaxLineLength int
+	// MaxTokens is the number of tokens in a document.
+	MaxTokens int
+	// MaxSize is the number of bytes in a document.
+	MaxSize int
+}
+
+// DefaultLimits are generous for documents written by hand.
+func DefaultLimits() Limits {
+	return Limits{MaxDepth: 64, MaxLineLength: 64 << 10, MaxTokens: 1 << 20, MaxSize: 16 << 20}
+}
+
+// wholeDocument checks whether there are limits on a document as a whole, which parsing a part of it cannot enforce.
+func (limits Limits) wholeDocument() bool {
+	return limits.MaxTokens > 0 || limits.MaxSize > 0
+}
+
+// checkString checks a document held in memory against the limits on its size and on the length of its lines.
+//
+// The error is the one reading the document would fail with.
+func (limits Limits) checkString(source, src string) error {
+	limit, max, at := ahmerr.Limit(0), 0, len(src)
+	if limits.MaxSize > 0 && len(src) > limits.MaxSize {
+		limit, max, at = ahmerr.DocumentSize, limits.MaxSize, limits.MaxSize
+	}
+	if limits.MaxLineLength > 0 {
+		for start := 0; start < at; {
+			end := strings.IndexByte(src[start:], '\n')
+			if end < 0 {
+				end = len(src) - start
+			}
+			if over := start + limits.MaxLineLength; end > limits.MaxLineLength && over < at {
+				limit, max, at = ahmerr.LineLength, limits.MaxLineLength, over
+			}
+			start += end + 1
+		}
+	}
+	if max == 0 {
+		return nil
+	}
+
+	// The rune containing the byte at goes over the limit.
+	from := position.NewFile(source, []byte(src)).Position(at)
+	r, size := utf8.DecodeRuneInString(src[from.Offset():])
+	span := position.SpanFromTo(from, from.Advance(r, size)).In(source)
+	return &ahmerr.LimitError{Limit: limit, Max: max, Span: span}
+}
+
+// limitedInput enforces the limits on the size of a document and its lines as it is read.
+type limitedInput struct {
+	src                      io.RuneScanner
+	limits                   Limits
+	source                   string
+	pos, prev                position.Position
+	lineStart, prevLineStart int
+}
+
+func newLimitedInput(src io.RuneScanner, limits Limits, source string, start position.Position) io.RuneScanner {
+	if limits.MaxSize == 0 && limits.MaxLineLength == 0 {
+		return src
+	}
+	return &limitedInput{src: src, limits: limits, source: source, pos: start, lineStart: start.Offset()}
+}
+
+func (in *limitedInput) ReadRune() (rune, int, error) {
+	r, size, err := in.src.ReadRune()
+	if err != nil {
+		return r, size, err
+	}
+
+	in.prev, in.prevLineStart = in.pos, in.lineStart
+	in.pos = in.pos.Advance(r, size)
+	if r == '\n' {
+		in.lineStart = in.pos.Offset()
+	}
+
+	if max := in.limits.MaxSize; max > 0 && in.pos.Offset() > max {
+		return r, size, in.exceeded(ahmerr.DocumentSize, max)
+	}
+	if max := in.limits.MaxLineLength; max > 0 && r != '\n' && in.pos.Offset()-in.lineStart > max {
+		return r, size, in.exceeded(ahmerr.LineLength, max)
+	}
+	return r, size, nil
+}
+
+func (in *limitedInput) UnreadRune() error {
+	err := in.src.UnreadRune()
+	if err == nil {
+		in.pos, in.lineStart = in.prev, in.prevLineStart
+	}
+	return err
+}
+
+func (in *limitedInput) exceeded(limit ahmerr.Limit, max int) error {
+	span := position.SpanFromTo(in.prev, in.pos).In(in.source)
+	return &ahmerr.LimitError{Limit: limit, Max: max, Span: span}
+}
@@ -0,0 +1,121 @@
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package ahm
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/szabba/ahm/ahmerr"
+	"github.com/szabba/ahm/assert"
+)
+
+func TestLimitsAreNotReachedByOrdinaryDocuments(t *testing.T) {
+	// given
+	parser := Config{Limits: DefaultLimits()}.NewParser(strings.NewReader(nested))
+
+	// when
+	_, err := parser.ParseAll()
+
+	// then
+	assert.That(err == nil, t.Errorf, "unexpected error: %s", err)
+}
+
+func TestLimitsStopParsingWithAPositionedError(t *testing.T) {
+	deep := "@A\n  @B\n    @C\n      @D\n"
+	long := "short\n" + strings.Repeat("x", 20) + "\n"
+
+	cases := []struct {
+		name      string
+		input     string
+		limits    Limits
+		limit     ahmerr.Limit
+		line, col int
+	}{
+		{"depth", deep, Limits{MaxDepth: 2}, ahmerr.NestingDepth, 4, 5},
+		{"line length", long, Limits{MaxLineLength: 10}, ahmerr.LineLength, 2, 11},
+		{"tokens", deep, Limits{MaxTokens: 5}, ahmerr.TokenCount, 2, 3},
+		{"size", long, Limits{MaxSize: 16}, ahmerr.DocumentSize, 2, 11},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			// given
+			parser := Config{Source: "upload.ahm", Limits: c.limits}.NewParser(strings.NewReader(c.input))
+
+			// when
+			_, err := parser.ParseAll()
+
+			// then
+			limit, ok := ahmerr.AsLimit(err)
+			assert.That(ok, t.Fatalf, "got error %v, wanted a limit error", err)
+			assert.That(limit.Limit == c.limit, t.Errorf, "got limit %s, wanted %s", limit.Limit, c.limit)
+			at := limit.Span.StartsAt()
+			assert.That(at.Line() == c.line && at.Column() == c.col, t.Errorf, "got error at %s, wanted %d,%d", limit.Span, c.line, c.col)
+			assert.That(limit.Span.Source == "upload.ahm", t.Errorf, "got source %q", limit.Span.Source)
+		})
+	}
+}
+
+func TestLimitsKeepDeepNestingFromGrowingTheIndentStack(t *testing.T) {
+	// given
+	var input strings.Builder
+	for i := 0; i < 1000; i++ {
+		input.WriteString(strings.Repeat(" ", i))
+		input.WriteString("@P\n")
+	}
+	parser := Config{Limits: DefaultLimits()}.NewParser(strings.NewReader(input.String()))
+
+	// when
+	_, err := parser.ParseAll()
+
+	// then
+	limit, ok := ahmerr.AsLimit(err)
+	assert.That(ok && limit.Limit == ahmerr.NestingDepth, t.Errorf, "got error %v, wanted a limit on nesting", err)
+}
+
+func TestManyBlankLinesDoNotExhaustTheStack(t *testing.T) {
+	// given
+	input := strings.Repeat("\n", 1<<18) + "text"
+
+	// when
+	nodes, err := NewParser(strings.NewReader(input)).ParseAll()
+
+	// then
+	assert.That(err == nil, t.Fatalf, "unexpected error: %s", err)
+	assert.That(len(nodes) == 1, t.Errorf, "got %d nodes, wanted 1", len(nodes))
+}
+
+func TestLimitsOnDocumentsInMemoryFailWhereReadingWould(t *testing.T) {
+	cases := []struct {
+		name   string
+		input  string
+		limits Limits
+	}{
+		{"size", "short\n" + strings.Repeat("x", 20), Limits{MaxSize: 16}},
+		{"size within a rune", "ąąąąą", Limits{MaxSize: 5}},
+		{"line length", "short\n" + strings.Repeat("x", 20) + "\n", Limits{MaxLineLength: 10}},
+		{"line length within a rune", "ok\nżżżżżż\n", Limits{MaxLineLength: 5}},
+		{"last line", "short\nshort\n" + strings.Repeat("x", 11), Limits{MaxLineLength: 10}},
+		{"line before size", strings.Repeat("x", 11) + "\nshort", Limits{MaxSize: 15, MaxLineLength: 10}},
+		{"size before line", "short\n" + strings.Repeat("x", 20), Limits{MaxSize: 10, MaxLineLength: 10}},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			// given
+			cfg := Config{Source: "upload.ahm", Limits: c.limits}
+			_, want := cfg.NewParser(strings.NewReader(c.input)).ParseAll()
+
+			// when
+			_, err := cfg.NewBytesParser([]byte(c.input)).ParseAll()
+
+			// then
+			limit, ok := ahmerr.AsLimit(err)
+			assert.That(ok, t.Fatalf, "got error %v, wanted a limit error", err)
+			assert.That(err.Error() == want.Error(), t.Errorf, "got error %s, wanted %s", limit, want)
+		})
+	}
+}
@@ -0,0 +1,71 @@
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package load
+
+import (
+	"crypto/sha256"
+	"fmt"
+	"sync"
+
+	"github.com/szabba/ahm"
+	"github.com/szabba/ahm/ahmbin"
+)
+
+// A Key identifies the result of parsing some content with some configuration.
+type Key [sha256.Size]byte
+
+// KeyOf hashes the content of a file together with the configuration it is parsed with.
+//
+// The source name is part of the configuration, since it ends up in the spans of the nodes.
+// So are the versions of the parser and of the binary form, so that keys change when the cached nodes would.
+func KeyOf(cfg ahm.Config, src []byte) Key {
+	h := sha256.New()
+	fmt.Fprintf(h, "%d %d %q %t %+v\x00", ahm.ParserVersion, ahmbin.Version, cfg.Source, cfg.StructuredArgs, cfg.Limits)
+	h.Write(src)
+
+	var key Key
+	h.Sum(key[:0])
+	return key
+}
+
+// A Cache keeps parsed documents, so that unchanged files need not be parsed again.
+//
+// Only documents that parsed without errors are put in it.
+// The nodes it returns are shared, so they must not be modified.
+// It is used from many goroutines at once.
+type Cache interface {
+	Get(key Key) ([]ahm.Node, bool)
+	Put(key Key, nodes []ahm.Node)
+}
+
+// A MemoryCache is a Cache that lasts as long as the process does.
+type MemoryCache struct {
+	mu    sync.Mutex
+	nodes map[Key][]ahm.Node
+}
+
+func NewMemoryCache() *MemoryCache {
+	return &MemoryCache{nodes: map[Key][]ahm.Node{}}
+}
+
+func (c *MemoryCache) Get(key Key) ([]ahm.Node, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	nodes, ok := c.nodes[key]
+	return nodes, ok
+}
+
+func (c *MemoryCache) Put(key Key, nodes []ahm.Node) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.nodes[key] = nodes
+}
+
+// Len is the number of documents in the cache.
+func (c *MemoryCache) Len() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return len(c.nodes)
+}
@@ -0,0 +1,62 @@
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package load
+
+import (
+	"encoding/hex"
+	"os"
+	"path/filepath"
+
+	"github.com/szabba/ahm"
+	"github.com/szabba/ahm/ahmbin"
+)
+
+// A DirCache is a Cache kept in a directory, so that it lasts between runs.
+//
+// Each document is a file in the binary form of package ahmbin, named after its key.
+// The cache is best effort: files that cannot be read or written are treated as missing.
+type DirCache struct {
+	Dir string
+}
+
+func NewDirCache(dir string) *DirCache {
+	return &DirCache{Dir: dir}
+}
+
+func (c *DirCache) Get(key Key) ([]ahm.Node, bool) {
+	data, err := os.ReadFile(c.path(key))
+	if err != nil {
+		return nil, false
+	}
+	nodes, err := ahmbin.Unmarshal(data)
+	return nodes, err == nil
+}
+
+func (c *DirCache) Put(key Key, nodes []ahm.Node) {
+	data, err := ahmbin.Marshal(nodes)
+	if err != nil || os.MkdirAll(c.Dir, 0755) != nil {
+		return
+	}
+
+	// Readers must never see a partly written file.
+	tmp, err := os.CreateTemp(c.Dir, "tmp-*")
+	if err != nil {
+		return
+	}
+	_, err = tmp.Write(data)
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		err = os.Rename(tmp.Name(), c.path(key))
+	}
+	if err != nil {
+		os.Remove(tmp.Name())
+	}
+}
+
+func (c *DirCache) path(key Key) string {
+	return filepath.Join(c.Dir, hex.EncodeToString(key[:])+".ahmb")
+}
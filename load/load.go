@@ -0,0 +1,124 @@
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+// Package load parses many documents at once.
+//
+// The files are read and parsed by a bounded number of workers,
+// but the results always come back in the order the files were asked for.
+package load
+
+import (
+	"io/fs"
+	"os"
+	"runtime"
+	"sync"
+
+	"github.com/pkg/errors"
+	"github.com/szabba/ahm"
+)
+
+// Config describes how to load documents.
+type Config struct {
+	// Parser is the configuration every file is parsed with.
+	// Its Source is replaced with the name of each file.
+	Parser ahm.Config
+	// Workers is the number of files processed at the same time.
+	// When it is not positive, runtime.GOMAXPROCS(0) is used.
+	Workers int
+	// Cache keeps documents between loads, when it is not nil.
+	// A MemoryCache lasts for a process, a DirCache between runs.
+	Cache Cache
+}
+
+// A Document is a parsed file.
+type Document struct {
+	Name  string
+	Nodes []ahm.Node
+	// Err is the error the file could not be read or parsed with.
+	// Parse errors are usually an *ahmerr.Diagnostic or an *ahmerr.LimitError.
+	Err error
+}
+
+// Files loads files from the operating system.
+func (cfg Config) Files(paths []string) []Document {
+	return cfg.load(paths, os.ReadFile)
+}
+
+// FS loads the files from fsys with names matching the pattern, in lexical order.
+//
+// The pattern has the syntax of fs.Glob.
+func (cfg Config) FS(fsys fs.FS, pattern string) ([]Document, error) {
+	names, err := fs.Glob(fsys, pattern)
+	if err != nil {
+		return nil, errors.Wrapf(err, "cannot list %s", pattern)
+	}
+	return cfg.FSFiles(fsys, names), nil
+}
+
+// FSFiles loads the named files from fsys.
+func (cfg Config) FSFiles(fsys fs.FS, names []string) []Document {
+	return cfg.load(names, func(name string) ([]byte, error) { return fs.ReadFile(fsys, name) })
+}
+
+func (cfg Config) load(names []string, read func(string) ([]byte, error)) []Document {
+	docs := make([]Document, len(names))
+
+	work := make(chan int)
+	var wg sync.WaitGroup
+	for w := 0; w < cfg.workers(len(names)); w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range work {
+				docs[i] = cfg.loadOne(names[i], read)
+			}
+		}()
+	}
+	for i := range names {
+		work <- i
+	}
+	close(work)
+	wg.Wait()
+
+	return docs
+}
+
+func (cfg Config) workers(files int) int {
+	n := cfg.Workers
+	if n <= 0 {
+		n = runtime.GOMAXPROCS(0)
+	}
+	if n > files {
+		n = files
+	}
+	return n
+}
+
+func (cfg Config) loadOne(name string, read func(string) ([]byte, error)) Document {
+	doc := Document{Name: name}
+
+	src, err := read(name)
+	if err != nil {
+		doc.Err = errors.Wrapf(err, "cannot read %s", name)
+		return doc
+	}
+
+	parserCfg := cfg.Parser
+	parserCfg.Source = name
+
+	var key Key
+	if cfg.Cache != nil {
+		key = KeyOf(parserCfg, src)
+		if nodes, ok := cfg.Cache.Get(key); ok {
+			doc.Nodes = nodes
+			return doc
+		}
+	}
+
+	doc.Nodes, doc.Err = parserCfg.NewBytesParser(src).ParseAll()
+	if cfg.Cache != nil && doc.Err == nil {
+		cfg.Cache.Put(key, doc.Nodes)
+	}
+	return doc
+}
@@ -0,0 +1,139 @@
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package load_test
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"testing/fstest"
+
+	"github.com/szabba/ahm"
+	"github.com/szabba/ahm/ahmerr"
+	. "github.com/szabba/ahm/ahmtest"
+	"github.com/szabba/ahm/assert"
+	"github.com/szabba/ahm/load"
+)
+
+func TestDocumentsComeBackInTheOrderOfTheirNames(t *testing.T) {
+	// given
+	fsys := fstest.MapFS{}
+	var want []string
+	for i := 0; i < 50; i++ {
+		name := fmt.Sprintf("cards/%02d.ahm", i)
+		fsys[name] = &fstest.MapFile{Data: []byte(fmt.Sprintf("@CARD c%d\n  text", i))}
+		want = append(want, name)
+	}
+
+	cfg := load.Config{Workers: 4}
+
+	// when
+	docs, err := cfg.FS(fsys, "cards/*.ahm")
+
+	// then
+	assert.That(err == nil, t.Fatalf, "unexpected error: %s", err)
+	assert.That(len(docs) == len(want), t.Fatalf, "got %d documents, wanted %d", len(docs), len(want))
+	for i, doc := range docs {
+		assert.That(doc.Name == want[i], t.Errorf, "document %d: got %s, wanted %s", i, doc.Name, want[i])
+		assert.That(doc.Err == nil, t.Errorf, "%s: unexpected error: %s", doc.Name, doc.Err)
+		ReportDiffs(t.Errorf, doc.Nodes, []ahm.Node{P("CARD", fmt.Sprintf("c%d", i), T("text"))})
+	}
+}
+
+func TestEachDocumentHasItsOwnError(t *testing.T) {
+	// given
+	fsys := fstest.MapFS{
+		"a.ahm": {Data: []byte("@A")},
+		"b.ahm": {Data: []byte("text @EM{unterminated")},
+	}
+
+	// when
+	docs := load.Config{}.FSFiles(fsys, []string{"a.ahm", "b.ahm", "missing.ahm"})
+
+	// then
+	assert.That(len(docs) == 3, t.Fatalf, "got %d documents, wanted 3", len(docs))
+	assert.That(docs[0].Err == nil, t.Errorf, "a.ahm: unexpected error: %s", docs[0].Err)
+	diag, ok := docs[1].Err.(*ahmerr.Diagnostic)
+	assert.That(ok, t.Errorf, "b.ahm: got error %#v, wanted a diagnostic", docs[1].Err)
+	assert.That(!ok || diag.Span.Source == "b.ahm", t.Errorf, "b.ahm: got diagnostic %s", docs[1].Err)
+	assert.That(docs[2].Err != nil, t.Errorf, "missing.ahm: expected an error")
+}
+
+func TestFilesAreReadFromTheOperatingSystem(t *testing.T) {
+	// given
+	path := filepath.Join(t.TempDir(), "doc.ahm")
+	err := os.WriteFile(path, []byte("@H1 Title"), 0644)
+	assert.That(err == nil, t.Fatalf, "cannot write file: %s", err)
+
+	// when
+	docs := load.Config{}.Files([]string{path})
+
+	// then
+	assert.That(len(docs) == 1 && docs[0].Err == nil, t.Fatalf, "got %#v", docs)
+	ReportDiffs(t.Errorf, docs[0].Nodes, []ahm.Node{P("H1", "Title")})
+	proc := docs[0].Nodes[0].(*ahm.Proc)
+	assert.That(proc.Span.Source == path, t.Errorf, "got source %s, wanted %s", proc.Span.Source, path)
+}
+
+func TestUnchangedFilesAreTakenFromTheCache(t *testing.T) {
+	// given
+	fsys := fstest.MapFS{
+		"a.ahm": {Data: []byte("@A")},
+		"b.ahm": {Data: []byte("@B")},
+	}
+	cache := load.NewMemoryCache()
+	cfg := load.Config{Cache: cache}
+
+	first, err := cfg.FS(fsys, "*.ahm")
+	assert.That(err == nil, t.Fatalf, "unexpected error: %s", err)
+	fsys["b.ahm"] = &fstest.MapFile{Data: []byte("@B changed")}
+
+	// when
+	second, err := cfg.FS(fsys, "*.ahm")
+
+	// then
+	assert.That(err == nil, t.Fatalf, "unexpected error: %s", err)
+	assert.That(second[0].Nodes[0] == first[0].Nodes[0], t.Errorf, "a.ahm was parsed again")
+	ReportDiffs(t.Errorf, second[1].Nodes, []ahm.Node{P("B", "changed")})
+	assert.That(cache.Len() == 3, t.Errorf, "got %d cached documents, wanted 3", cache.Len())
+}
+
+func TestTheCacheTellsSourcesApart(t *testing.T) {
+	// given
+	fsys := fstest.MapFS{
+		"a.ahm": {Data: []byte("@SAME")},
+		"b.ahm": {Data: []byte("@SAME")},
+	}
+	cfg := load.Config{Cache: load.NewMemoryCache(), Workers: 1}
+
+	// when
+	docs, err := cfg.FS(fsys, "*.ahm")
+
+	// then
+	assert.That(err == nil, t.Fatalf, "unexpected error: %s", err)
+	for _, doc := range docs {
+		source := doc.Nodes[0].(*ahm.Proc).Span.Source
+		assert.That(source == doc.Name, t.Errorf, "%s: got nodes from %s", doc.Name, source)
+	}
+}
+
+func TestADirCacheLastsBetweenLoads(t *testing.T) {
+	// given
+	fsys := fstest.MapFS{"a.ahm": {Data: []byte("@A title\n  text")}}
+	dir := t.TempDir()
+
+	first, err := load.Config{Cache: load.NewDirCache(dir)}.FS(fsys, "*.ahm")
+	assert.That(err == nil, t.Fatalf, "unexpected error: %s", err)
+
+	// when
+	cache := load.NewDirCache(dir)
+	nodes, ok := cache.Get(load.KeyOf(ahm.Config{Source: "a.ahm"}, fsys["a.ahm"].Data))
+
+	// then
+	assert.That(ok, t.Fatalf, "the document is not in the cache")
+	assert.That(reflect.DeepEqual(nodes, first[0].Nodes), t.Errorf, "got %v, wanted %v", nodes, first[0].Nodes)
+}
@@ -0,0 +1,45 @@
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package lsp
+
+import (
+	"strings"
+
+	"github.com/szabba/ahm"
+	"github.com/szabba/ahm/position"
+)
+
+// A document is the last known content of an open file.
+type document struct {
+	uri   string
+	text  string
+	file  *position.File
+	nodes []ahm.Node
+	err   error
+}
+
+func newDocument(uri, text string) *document {
+	doc := &document{uri: uri, text: text, file: position.NewFile(uri, []byte(text))}
+	doc.nodes, doc.err = ahm.Config{Source: uri}.NewParser(strings.NewReader(text)).ParseAll()
+	return doc
+}
+
+// toLSP converts a position to one with 0-based lines and columns counted in UTF-16 code units.
+func (doc *document) toLSP(pos position.Position) Position {
+	return Position{Line: pos.Line() - 1, Character: doc.file.UTF16Column(pos) - 1}
+}
+
+func (doc *document) fromLSP(pos Position) position.Position {
+	return doc.file.PositionOfUTF16(pos.Line+1, pos.Character+1)
+}
+
+func (doc *document) toRange(span position.Span) Range {
+	return Range{Start: doc.toLSP(span.StartsAt()), End: doc.toLSP(span.EndsBefore())}
+}
+
+// end is the position just past the last character of the document.
+func (doc *document) end() Position {
+	return doc.toLSP(doc.file.Position(doc.file.Size()))
+}
@@ -0,0 +1,211 @@
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package lsp
+
+import (
+	"encoding/json"
+	"strings"
+
+	"github.com/szabba/ahm"
+	"github.com/szabba/ahm/ahmerr"
+	"github.com/szabba/ahm/position"
+	"github.com/szabba/ahm/story"
+)
+
+// diagnose lists the parse errors of a document, and the problems with the episode it holds, if any.
+func diagnose(doc *document) []Diagnostic {
+	diags := []Diagnostic{}
+	add := func(err error) {
+		switch err := err.(type) {
+		case nil:
+		case *ahmerr.Diagnostic:
+			diags = append(diags, toDiagnostic(doc, err))
+		case ahmerr.Diagnostics:
+			for _, diag := range err {
+				diags = append(diags, toDiagnostic(doc, diag))
+			}
+		default:
+			diags = append(diags, Diagnostic{Severity: 1, Source: "ahm", Message: err.Error()})
+		}
+	}
+
+	add(doc.err)
+	if doc.err != nil || !hasEpisode(doc.nodes) {
+		return diags
+	}
+
+	nodes, err := ahm.Config{Source: doc.uri, StructuredArgs: true}.NewParser(strings.NewReader(doc.text)).ParseAll()
+	if err != nil {
+		add(err)
+		return diags
+	}
+	ep, err := story.FromNodes(nodes)
+	add(err)
+	if ep != nil {
+		add(story.Analyze(ep).Err())
+	}
+	return diags
+}
+
+func hasEpisode(nodes []ahm.Node) bool {
+	for _, node := range nodes {
+		if proc, ok := node.(*ahm.Proc); ok && proc.Name == story.EpisodeProc {
+			return true
+		}
+	}
+	return false
+}
+
+func toDiagnostic(doc *document, diag *ahmerr.Diagnostic) Diagnostic {
+	return Diagnostic{
+		Range:    doc.toRange(diag.Span.Span),
+		Severity: int(diag.Severity) + 1,
+		Source:   "ahm",
+		Message:  diag.Message,
+	}
+}
+
+func (s *Server) documentSymbol(params json.RawMessage) (interface{}, error) {
+	doc, err := s.documentFor(params)
+	if err != nil {
+		return nil, err
+	}
+	return symbols(doc, doc.nodes), nil
+}
+
+// symbols describes the block procs among the nodes, leaving out the inline ones.
+func symbols(doc *document, nodes []ahm.Node) []DocumentSymbol {
+	syms := []DocumentSymbol{}
+	for _, node := range nodes {
+		proc, ok := node.(*ahm.Proc)
+		if !ok {
+			continue
+		}
+		syms = append(syms, DocumentSymbol{
+			Name:           "@" + proc.Name,
+			Detail:         proc.Title,
+			Kind:           symbolObject,
+			Range:          doc.toRange(proc.Span.Span),
+			SelectionRange: doc.toRange(nameSpan(proc)),
+			Children:       symbols(doc, proc.Children),
+		})
+	}
+	return syms
+}
+
+// nameSpan is the span of the '@' and the name of a proc.
+func nameSpan(proc *ahm.Proc) position.Span {
+	span := proc.Span.StartsAt().StartSpan().Add('@')
+	for _, r := range proc.Name {
+		span = span.Add(r)
+	}
+	return span
+}
+
+func (s *Server) foldingRange(params json.RawMessage) (interface{}, error) {
+	doc, err := s.documentFor(params)
+	if err != nil {
+		return nil, err
+	}
+	ranges := []FoldingRange{}
+	walkProcs(doc.nodes, func(proc *ahm.Proc) {
+		start, end := proc.Span.StartsAt().Line(), proc.Span.EndsBefore().Line()
+		if len(proc.Children) > 0 && end > start {
+			ranges = append(ranges, FoldingRange{StartLine: start - 1, EndLine: end - 1})
+		}
+	})
+	return ranges, nil
+}
+
+func (s *Server) definition(params json.RawMessage) (interface{}, error) {
+	doc, at, err := s.positionFor(params)
+	if err != nil {
+		return nil, err
+	}
+	ref, ok := ahm.ProcAt(doc.nodes, at)
+	if !ok || s.schema[ref.Name].RefersTo == "" {
+		return nil, nil
+	}
+
+	kind, name := s.schema[ref.Name].RefersTo, strings.TrimSpace(ref.Title)
+	var found *ahm.Proc
+	walkProcs(doc.nodes, func(proc *ahm.Proc) {
+		if found == nil && s.schema[proc.Name].Defines == kind && strings.TrimSpace(proc.Title) == name {
+			found = proc
+		}
+	})
+	if found == nil {
+		return nil, nil
+	}
+	return Location{URI: doc.uri, Range: doc.toRange(nameSpan(found))}, nil
+}
+
+func (s *Server) hover(params json.RawMessage) (interface{}, error) {
+	doc, at, err := s.positionFor(params)
+	if err != nil {
+		return nil, err
+	}
+	proc, ok := ahm.ProcAt(doc.nodes, at)
+	if !ok || !nameSpan(proc).Contains(at) {
+		return nil, nil
+	}
+	info, ok := s.schema[proc.Name]
+	if !ok || info.Doc == "" {
+		return nil, nil
+	}
+	return hover{
+		Contents: markupContent{Kind: "markdown", Value: "**@" + proc.Name + "**\n\n" + info.Doc},
+		Range:    doc.toRange(nameSpan(proc)),
+	}, nil
+}
+
+func (s *Server) formatting(params json.RawMessage) (interface{}, error) {
+	doc, err := s.documentFor(params)
+	if err != nil {
+		return nil, err
+	}
+	if doc.err != nil {
+		return nil, nil
+	}
+	text, err := ahm.FormatString(doc.nodes)
+	if err != nil || text == doc.text {
+		return []TextEdit{}, nil
+	}
+	whole := Range{End: doc.end()}
+	return []TextEdit{{Range: whole, NewText: text}}, nil
+}
+
+func (s *Server) documentFor(params json.RawMessage) (*document, error) {
+	var p documentParams
+	if err := decode(params, &p); err != nil {
+		return nil, err
+	}
+	return s.document(p.TextDocument.URI)
+}
+
+func (s *Server) positionFor(params json.RawMessage) (*document, position.Position, error) {
+	var p textDocumentPositionParams
+	if err := decode(params, &p); err != nil {
+		return nil, position.Position{}, err
+	}
+	doc, err := s.document(p.TextDocument.URI)
+	if err != nil {
+		return nil, position.Position{}, err
+	}
+	return doc, doc.fromLSP(p.Position), nil
+}
+
+// walkProcs visits the procs among the nodes and all their descendants, in document order.
+func walkProcs(nodes []ahm.Node, visit func(*ahm.Proc)) {
+	for _, node := range nodes {
+		switch node := node.(type) {
+		case *ahm.Proc:
+			visit(node)
+			walkProcs(node.Children, visit)
+		case *ahm.Paragraph:
+			walkProcs(node.Children, visit)
+		}
+	}
+}
@@ -0,0 +1,55 @@
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package lsp
+
+// A Schema describes procs, for hovers and go-to-definition.
+type Schema map[string]ProcInfo
+
+type ProcInfo struct {
+	// Doc is shown when hovering over the proc, as Markdown.
+	Doc string
+	// Defines is set for procs whose title names something other procs can refer to, like a card.
+	Defines string
+	// RefersTo is set for procs whose title refers to something defined by other procs.
+	RefersTo string
+}
+
+// DefaultSchema describes the dialects in this module.
+func DefaultSchema() Schema {
+	return Schema{
+		"IMPORT":                   {Doc: "Makes the procs of a package available under a namespace: `@IMPORT GO` or `@IMPORT GO AS G`."},
+		"INCLUDE":                  {Doc: "Replaced by the nodes of another document: `@INCLUDE path`."},
+		"INCLUDE-FROM":             {Doc: "Makes a file available to the procs nested in it, or is replaced by its text."},
+		"DOCUMENT":                 {Doc: "The metadata of a document: its `@TITLE`, `@AUTHOR` and `@DATE`."},
+		"TITLE":                    {Doc: "The title of the document."},
+		"AUTHOR":                   {Doc: "The author of the document."},
+		"DATE":                     {Doc: "The date of the document."},
+		"H1":                       {Doc: "A top level header."},
+		"H2":                       {Doc: "A second level header."},
+		"H3":                       {Doc: "A third level header."},
+		"H4":                       {Doc: "A fourth level header."},
+		"H5":                       {Doc: "A fifth level header."},
+		"H6":                       {Doc: "A sixth level header."},
+		"CODE":                     {Doc: "A code block, titled with the language: `@CODE go`."},
+		"MATH":                     {Doc: "A TeX formula, usually inline: `@MATH{E = mc^2}`."},
+		"TODO":                     {Doc: "An item to do. Nested items are subtasks; `#tag` and `due=YYYY-MM-DD` in the title."},
+		"DONE":                     {Doc: "An item that has been done."},
+		"EPISODE":                  {Doc: "An interactive story, made up of `@CONDITIONS` and `@CARD`s."},
+		"CONDITIONS":               {Doc: "The declarations of the values of an episode."},
+		"VALUE":                    {Doc: "Declares a value with its initial setting: `@VALUE name 10`."},
+		"CARD":                     {Doc: "A card, with text and a `@CHOICE` of options.", Defines: "card"},
+		"CHOICE":                   {Doc: "The options a player can pick on a card."},
+		"OPTION":                   {Doc: "An option, with the `@EFFECT`s of picking it."},
+		"EFFECT":                   {Doc: "What picking an option does: `@SET`, `@ADD`, `@GOTO`, `@NEXT` and `@IF`."},
+		"SET":                      {Doc: "Sets a value: `@SET name 10`."},
+		"ADD":                      {Doc: "Adds to a value: `@ADD name -5`."},
+		"GOTO":                     {Doc: "Makes a card the next one: `@GOTO card`.", RefersTo: "card"},
+		"NEXT":                     {Doc: "Makes the card after the current one the next one."},
+		"IF":                       {Doc: "Applies the nested effects when a condition holds: `@IF name >= 10`."},
+		"CONFLICT":                 {Doc: "A merge conflict, with the `@BASE`, `@OURS` and `@THEIRS` versions."},
+		"GO/FMT":                   {Doc: "Formats the nested Go code."},
+		"GO/INCLUDE-FUNCTION-BODY": {Doc: "Includes the body of a function from the file of the enclosing `@INCLUDE-FROM`."},
+	}
+}
@@ -0,0 +1,238 @@
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+// Package lsp implements a language server for AHM documents.
+//
+// The server speaks the Language Server Protocol over a pair of streams,
+// usually the standard input and output of an editor's child process.
+package lsp
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/textproto"
+	"strconv"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// maxMessageSize limits the body of a message, so that a bad header cannot make the server run out of memory.
+const maxMessageSize = 8 << 20
+
+// A Server answers the requests of a single client.
+type Server struct {
+	in       *bufio.Reader
+	out      io.Writer
+	schema   Schema
+	docs     map[string]*document
+	shutdown bool
+}
+
+// NewServer creates a server that describes procs with the DefaultSchema.
+func NewServer(in io.Reader, out io.Writer) *Server {
+	return NewServerWithSchema(in, out, DefaultSchema())
+}
+
+func NewServerWithSchema(in io.Reader, out io.Writer, schema Schema) *Server {
+	return &Server{
+		in:     bufio.NewReader(in),
+		out:    out,
+		schema: schema,
+		docs:   map[string]*document{},
+	}
+}
+
+// Serve handles messages until the client asks the server to exit or the input ends.
+func (s *Server) Serve() error {
+	for {
+		body, err := s.read()
+		if err == io.EOF {
+			return nil
+		} else if err != nil {
+			return err
+		}
+
+		var msg message
+		if err := json.Unmarshal(body, &msg); err != nil {
+			if err := s.reply(nil, nil, &responseError{codeParseError, err.Error()}); err != nil {
+				return err
+			}
+			continue
+		}
+
+		if msg.Method == "exit" {
+			if !s.shutdown {
+				return errors.New("exit without shutdown")
+			}
+			return nil
+		}
+		if err := s.handle(&msg); err != nil {
+			return err
+		}
+	}
+}
+
+func (s *Server) read() ([]byte, error) {
+	header, err := textproto.NewReader(s.in).ReadMIMEHeader()
+	if err == io.EOF {
+		return nil, err
+	} else if err != nil {
+		return nil, errors.Wrap(err, "reading message header")
+	}
+	length, err := strconv.Atoi(header.Get("Content-Length"))
+	if err != nil {
+		return nil, errors.Wrap(err, "reading message header")
+	}
+	if length < 0 || length > maxMessageSize {
+		return nil, errors.Errorf("message length %d is not between 0 and %d", length, maxMessageSize)
+	}
+	body := make([]byte, length)
+	_, err = io.ReadFull(s.in, body)
+	return body, errors.Wrap(err, "reading message body")
+}
+
+func (s *Server) write(v interface{}) error {
+	body, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	_, err = fmt.Fprintf(s.out, "Content-Length: %d\r\n\r\n%s", len(body), body)
+	return err
+}
+
+func (s *Server) reply(id *json.RawMessage, result interface{}, rerr *responseError) error {
+	if id == nil && rerr == nil {
+		return nil
+	}
+	return s.write(response{JSONRPC: "2.0", ID: id, Result: result, Error: rerr})
+}
+
+func (s *Server) notify(method string, params interface{}) error {
+	return s.write(notification{JSONRPC: "2.0", Method: method, Params: params})
+}
+
+// A handler answers a request, or reacts to a notification when there is no one to answer.
+type handler func(s *Server, params json.RawMessage) (interface{}, error)
+
+var handlers map[string]handler
+
+func init() {
+	handlers = map[string]handler{
+		"initialize":  (*Server).initialize,
+		"initialized": ignore,
+		"shutdown":    (*Server).shutdownRequest,
+
+		"textDocument/didOpen":   (*Server).didOpen,
+		"textDocument/didChange": (*Server).didChange,
+		"textDocument/didClose":  (*Server).didClose,
+		"textDocument/didSave":   ignore,
+
+		"textDocument/documentSymbol": (*Server).documentSymbol,
+		"textDocument/foldingRange":   (*Server).foldingRange,
+		"textDocument/definition":     (*Server).definition,
+		"textDocument/hover":          (*Server).hover,
+		"textDocument/formatting":     (*Server).formatting,
+	}
+}
+
+func ignore(*Server, json.RawMessage) (interface{}, error) { return nil, nil }
+
+func (s *Server) handle(msg *message) error {
+	if s.shutdown && msg.ID != nil {
+		return s.reply(msg.ID, nil, &responseError{codeInvalidRequest, "the server is shutting down"})
+	}
+
+	h, ok := handlers[msg.Method]
+	if !ok {
+		if msg.ID == nil || strings.HasPrefix(msg.Method, "$/") {
+			return nil
+		}
+		return s.reply(msg.ID, nil, &responseError{codeMethodNotFound, fmt.Sprintf("unknown method %s", msg.Method)})
+	}
+
+	result, err := h(s, msg.Params)
+	if perr, ok := err.(paramsError); ok {
+		return s.reply(msg.ID, nil, &responseError{codeInvalidParams, perr.Error()})
+	} else if err != nil {
+		return err
+	}
+	return s.reply(msg.ID, result, nil)
+}
+
+// A paramsError is sent back to the client instead of stopping the server.
+type paramsError struct{ error }
+
+func decode(params json.RawMessage, v interface{}) error {
+	if err := json.Unmarshal(params, v); err != nil {
+		return paramsError{err}
+	}
+	return nil
+}
+
+func (s *Server) initialize(json.RawMessage) (interface{}, error) {
+	return map[string]interface{}{
+		"capabilities": map[string]interface{}{
+			"textDocumentSync":           1,
+			"documentSymbolProvider":     true,
+			"foldingRangeProvider":       true,
+			"definitionProvider":         true,
+			"hoverProvider":              true,
+			"documentFormattingProvider": true,
+			"positionEncoding":           "utf-16",
+		},
+		"serverInfo": map[string]string{"name": "ahm"},
+	}, nil
+}
+
+func (s *Server) shutdownRequest(json.RawMessage) (interface{}, error) {
+	s.shutdown = true
+	return nil, nil
+}
+
+func (s *Server) didOpen(params json.RawMessage) (interface{}, error) {
+	var p didOpenParams
+	if err := decode(params, &p); err != nil {
+		return nil, err
+	}
+	return nil, s.update(p.TextDocument.URI, p.TextDocument.Text)
+}
+
+func (s *Server) didChange(params json.RawMessage) (interface{}, error) {
+	var p didChangeParams
+	if err := decode(params, &p); err != nil {
+		return nil, err
+	}
+	if len(p.ContentChanges) == 0 {
+		return nil, nil
+	}
+	// With full synchronization, the last change holds the whole text.
+	return nil, s.update(p.TextDocument.URI, p.ContentChanges[len(p.ContentChanges)-1].Text)
+}
+
+func (s *Server) didClose(params json.RawMessage) (interface{}, error) {
+	var p didCloseParams
+	if err := decode(params, &p); err != nil {
+		return nil, err
+	}
+	delete(s.docs, p.TextDocument.URI)
+	return nil, s.notify("textDocument/publishDiagnostics", publishDiagnosticsParams{URI: p.TextDocument.URI, Diagnostics: []Diagnostic{}})
+}
+
+func (s *Server) update(uri, text string) error {
+	doc := newDocument(uri, text)
+	s.docs[uri] = doc
+	return s.notify("textDocument/publishDiagnostics", publishDiagnosticsParams{URI: uri, Diagnostics: diagnose(doc)})
+}
+
+// document finds an open document, or fails with a paramsError.
+func (s *Server) document(uri string) (*document, error) {
+	doc, ok := s.docs[uri]
+	if !ok {
+		return nil, paramsError{errors.Errorf("document %s is not open", uri)}
+	}
+	return doc, nil
+}
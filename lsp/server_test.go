@@ -0,0 +1,288 @@
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package lsp_test
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/textproto"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/szabba/ahm/assert"
+	"github.com/szabba/ahm/lsp"
+)
+
+const uri = "file:///story.ahm"
+
+const episode = `@EPISODE Test
+  @CARD start
+    Hello 🙂 there.
+    @CHOICE
+      @OPTION
+        Go on.
+        @EFFECT
+          @GOTO end
+  @CARD end
+    Bye.
+`
+
+func TestOpeningADocumentPublishesDiagnostics(t *testing.T) {
+	// given
+	client := newClient()
+	client.notify("textDocument/didOpen", open("@EPISODE Test\n  @CARD start\n    @CHOICE\n      @OPTION\n        @EFFECT\n          @GOTO nowhere\n"))
+
+	// when
+	msgs := client.run(t)
+
+	// then
+	diags := msgs[0].Params.Diagnostics
+	assert.That(len(diags) == 1, t.Fatalf, "got diagnostics %+v, wanted one", diags)
+	assert.That(strings.Contains(diags[0].Message, "nowhere"), t.Errorf, "got message %q", diags[0].Message)
+	assert.That(diags[0].Range.Start.Line == 5, t.Errorf, "got range %+v", diags[0].Range)
+}
+
+func TestParseErrorsAreDiagnosedAfterAChange(t *testing.T) {
+	// given
+	client := newClient()
+	client.notify("textDocument/didOpen", open("text\n"))
+	client.notify("textDocument/didChange", map[string]interface{}{
+		"textDocument":   map[string]interface{}{"uri": uri, "version": 2},
+		"contentChanges": []map[string]string{{"text": "@A{unterminated\n"}},
+	})
+
+	// when
+	msgs := client.run(t)
+
+	// then
+	assert.That(len(msgs) == 2, t.Fatalf, "got %d messages, wanted 2", len(msgs))
+	assert.That(len(msgs[0].Params.Diagnostics) == 0, t.Errorf, "got diagnostics %+v before the change", msgs[0].Params.Diagnostics)
+	assert.That(len(msgs[1].Params.Diagnostics) == 1, t.Errorf, "got diagnostics %+v after the change", msgs[1].Params.Diagnostics)
+}
+
+func TestDocumentSymbolsOutlineTheProcs(t *testing.T) {
+	// given
+	client := newClient()
+	client.notify("textDocument/didOpen", open(episode))
+	client.request(1, "textDocument/documentSymbol", document())
+
+	// when
+	msgs := client.run(t)
+
+	// then
+	var syms []lsp.DocumentSymbol
+	msgs[1].decodeResult(t, &syms)
+	assert.That(len(syms) == 1 && syms[0].Name == "@EPISODE", t.Fatalf, "got symbols %+v", syms)
+	cards := syms[0].Children
+	assert.That(len(cards) == 2 && cards[1].Detail == "end", t.Fatalf, "got cards %+v", cards)
+	assert.That(cards[1].SelectionRange.Start.Line == 8, t.Errorf, "got selection range %+v", cards[1].SelectionRange)
+}
+
+func TestFoldingRangesCoverProcsWithChildren(t *testing.T) {
+	// given
+	client := newClient()
+	client.notify("textDocument/didOpen", open(episode))
+	client.request(1, "textDocument/foldingRange", document())
+
+	// when
+	msgs := client.run(t)
+
+	// then
+	var ranges []lsp.FoldingRange
+	msgs[1].decodeResult(t, &ranges)
+	want := []lsp.FoldingRange{{0, 9}, {1, 7}, {3, 7}, {4, 7}, {6, 7}, {8, 9}}
+	assert.That(fmt.Sprint(ranges) == fmt.Sprint(want), t.Errorf, "got %v, wanted %v", ranges, want)
+}
+
+func TestDefinitionOfACardReference(t *testing.T) {
+	// given
+	client := newClient()
+	client.notify("textDocument/didOpen", open(episode))
+	client.request(1, "textDocument/definition", at(7, 17))
+
+	// when
+	msgs := client.run(t)
+
+	// then
+	var loc lsp.Location
+	msgs[1].decodeResult(t, &loc)
+	want := lsp.Range{Start: lsp.Position{8, 2}, End: lsp.Position{8, 7}}
+	assert.That(loc.URI == uri && loc.Range == want, t.Errorf, "got %+v, wanted %+v", loc, want)
+}
+
+func TestHoverOnAProcNameShowsItsDoc(t *testing.T) {
+	// given
+	client := newClient()
+	client.notify("textDocument/didOpen", open(episode))
+	client.request(1, "textDocument/hover", at(1, 4))
+	client.request(2, "textDocument/hover", at(1, 9))
+
+	// when
+	msgs := client.run(t)
+
+	// then
+	var hover struct {
+		Contents struct{ Value string }
+	}
+	msgs[1].decodeResult(t, &hover)
+	assert.That(strings.HasPrefix(hover.Contents.Value, "**@CARD**"), t.Errorf, "got hover %q", hover.Contents.Value)
+	assert.That(string(msgs[2].Result) == "null", t.Errorf, "got hover %s on the title", msgs[2].Result)
+}
+
+func TestPositionsAreInUTF16CodeUnits(t *testing.T) {
+	// given
+	client := newClient()
+	client.notify("textDocument/didOpen", open("Smile 🙂 @GOTO{end}\n@CARD end\n"))
+	client.request(1, "textDocument/definition", at(0, 10))
+	client.request(2, "textDocument/definition", at(0, 8))
+
+	// when
+	msgs := client.run(t)
+
+	// then
+	var loc lsp.Location
+	msgs[1].decodeResult(t, &loc)
+	assert.That(loc.Range.Start == lsp.Position{1, 0}, t.Errorf, "got %+v", loc)
+	assert.That(string(msgs[2].Result) == "null", t.Errorf, "got %s before the inline proc", msgs[2].Result)
+}
+
+func TestFormattingReplacesTheWholeDocument(t *testing.T) {
+	// given
+	client := newClient()
+	client.notify("textDocument/didOpen", open("@H1   Title\nText.\n"))
+	client.request(1, "textDocument/formatting", document())
+
+	// when
+	msgs := client.run(t)
+
+	// then
+	var edits []lsp.TextEdit
+	msgs[1].decodeResult(t, &edits)
+	assert.That(len(edits) == 1, t.Fatalf, "got edits %+v", edits)
+	assert.That(edits[0].NewText == "@H1 Title\n\nText.\n", t.Errorf, "got %q", edits[0].NewText)
+	assert.That(edits[0].Range.End == lsp.Position{2, 0}, t.Errorf, "got range %+v", edits[0].Range)
+}
+
+func TestUnknownRequestsAreRejected(t *testing.T) {
+	// given
+	client := newClient()
+	client.request(1, "textDocument/rename", at(0, 0))
+
+	// when
+	msgs := client.run(t)
+
+	// then
+	assert.That(msgs[0].Error != nil && msgs[0].Error.Code == -32601, t.Errorf, "got %+v", msgs[0])
+}
+
+func TestBadMessageLengthsAreErrors(t *testing.T) {
+	lengths := map[string]string{
+		"negative": "-1",
+		"tooLarge": "1000000000",
+	}
+
+	for name, length := range lengths {
+		t.Run(name, func(t *testing.T) {
+			// given
+			in := strings.NewReader("Content-Length: " + length + "\r\n\r\n{}")
+			server := lsp.NewServer(in, io.Discard)
+
+			// when
+			err := server.Serve()
+
+			// then
+			assert.That(err != nil, t.Errorf, "expected an error")
+		})
+	}
+}
+
+type client struct {
+	in bytes.Buffer
+}
+
+func newClient() *client {
+	c := new(client)
+	c.request(0, "initialize", map[string]interface{}{})
+	c.notify("initialized", map[string]interface{}{})
+	return c
+}
+
+func (c *client) request(id int, method string, params interface{}) {
+	c.send(map[string]interface{}{"jsonrpc": "2.0", "id": id, "method": method, "params": params})
+}
+
+func (c *client) notify(method string, params interface{}) {
+	c.send(map[string]interface{}{"jsonrpc": "2.0", "method": method, "params": params})
+}
+
+func (c *client) send(msg interface{}) {
+	body, _ := json.Marshal(msg)
+	fmt.Fprintf(&c.in, "Content-Length: %d\r\n\r\n%s", len(body), body)
+}
+
+// run shuts the server down and returns what it sent after the response to initialize.
+func (c *client) run(t *testing.T) []received {
+	c.request(-1, "shutdown", nil)
+	c.notify("exit", nil)
+
+	var out bytes.Buffer
+	err := lsp.NewServer(&c.in, &out).Serve()
+	assert.That(err == nil, t.Fatalf, "serve: %s", err)
+
+	var msgs []received
+	r := bufio.NewReader(&out)
+	for {
+		header, err := textproto.NewReader(r).ReadMIMEHeader()
+		if err == io.EOF {
+			break
+		}
+		assert.That(err == nil, t.Fatalf, "reading output: %s", err)
+		n, _ := strconv.Atoi(header.Get("Content-Length"))
+		body := make([]byte, n)
+		io.ReadFull(r, body)
+
+		var msg received
+		err = json.Unmarshal(body, &msg)
+		assert.That(err == nil, t.Fatalf, "decoding %s: %s", body, err)
+		msgs = append(msgs, msg)
+	}
+	assert.That(len(msgs) >= 2, t.Fatalf, "got %d messages", len(msgs))
+	return msgs[1 : len(msgs)-1]
+}
+
+type received struct {
+	Method string
+	Params struct {
+		Diagnostics []lsp.Diagnostic
+	}
+	Result json.RawMessage
+	Error  *struct{ Code int }
+}
+
+func (msg received) decodeResult(t *testing.T, v interface{}) {
+	err := json.Unmarshal(msg.Result, v)
+	assert.That(err == nil, t.Fatalf, "decoding result %s: %s", msg.Result, err)
+}
+
+func open(text string) interface{} {
+	return map[string]interface{}{
+		"textDocument": map[string]interface{}{"uri": uri, "languageId": "ahm", "version": 1, "text": text},
+	}
+}
+
+func document() interface{} {
+	return map[string]interface{}{"textDocument": map[string]string{"uri": uri}}
+}
+
+func at(line, char int) interface{} {
+	return map[string]interface{}{
+		"textDocument": map[string]string{"uri": uri},
+		"position":     map[string]int{"line": line, "character": char},
+	}
+}
@@ -0,0 +1,274 @@
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package markdown
+
+import (
+	"io"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/szabba/ahm"
+	"github.com/szabba/ahm/ahmerr"
+	"github.com/szabba/ahm/ns"
+)
+
+// Export writes nodes of the document dialect as Markdown.
+//
+// Only the procs listed in the package documentation can be exported.
+func Export(w io.Writer, nodes []ahm.Node) error {
+	md, err := ExportString(nodes)
+	if err != nil {
+		return err
+	}
+	_, err = io.WriteString(w, md)
+	return err
+}
+
+// ExportString returns nodes of the document dialect as Markdown.
+func ExportString(nodes []ahm.Node) (string, error) {
+	blocks, err := exportBlocks(nodes)
+	if err != nil {
+		return "", err
+	}
+	if len(blocks) == 0 {
+		return "", nil
+	}
+	return strings.Join(blocks, "\n\n") + "\n", nil
+}
+
+// exportBlocks exports each node as a block of lines, without a final newline.
+func exportBlocks(nodes []ahm.Node) ([]string, error) {
+	var blocks []string
+	for _, node := range nodes {
+		if ns.IsImport(node) {
+			continue
+		}
+		block, err := exportBlock(node)
+		if err != nil {
+			return nil, err
+		}
+		blocks = append(blocks, block)
+	}
+	return blocks, nil
+}
+
+func exportBlock(node ahm.Node) (string, error) {
+	switch node := node.(type) {
+	case *ahm.Text:
+		return exportInline([]ahm.Node{node})
+	case *ahm.Paragraph:
+		return exportInline(node.Children)
+	case *ahm.Proc:
+		return exportProc(node)
+	default:
+		return "", nil
+	}
+}
+
+func exportProc(proc *ahm.Proc) (string, error) {
+	if level, ok := headerLevel(proc.Name); ok {
+		text, err := titleOrInline(proc)
+		if err != nil {
+			return "", err
+		}
+		return strings.Repeat("#", level) + " " + strings.ReplaceAll(text, "\n", " "), nil
+	}
+
+	switch proc.Name {
+	case Code:
+		return exportCode(proc)
+	case Quote:
+		return exportQuote(proc)
+	case Bullets, Numbered:
+		return exportList(proc)
+	case Rule:
+		return "***", nil
+	default:
+		return "", ahmerr.Diagnosef(proc.Span, "proc %s has no Markdown equivalent", proc.Name)
+	}
+}
+
+func headerLevel(name string) (int, bool) {
+	if len(name) != 2 || name[0] != 'H' {
+		return 0, false
+	}
+	level, err := strconv.Atoi(name[1:])
+	return level, err == nil && level >= 1 && level <= maxHeader
+}
+
+func titleOrInline(proc *ahm.Proc) (string, error) {
+	if proc.Title != "" || len(proc.Children) == 0 {
+		return escapeText(proc.Title, true), nil
+	}
+	var parts []ahm.Node
+	for _, child := range proc.Children {
+		parts = append(parts, partsOf(child)...)
+	}
+	return exportInline(parts)
+}
+
+func exportCode(proc *ahm.Proc) (string, error) {
+	var lines []string
+	for _, child := range proc.Children {
+		text, ok := child.(*ahm.Text)
+		if !ok {
+			return "", ahmerr.Diagnosef(ahm.SpanOf(child), "a CODE block can only contain text")
+		}
+		lines = append(lines, text.Text)
+	}
+	code := strings.Join(lines, "\n")
+
+	fence := "```"
+	for strings.Contains(code, fence) {
+		fence += "`"
+	}
+	if code == "" {
+		return fence + proc.Title + "\n" + fence, nil
+	}
+	return fence + proc.Title + "\n" + code + "\n" + fence, nil
+}
+
+func exportQuote(proc *ahm.Proc) (string, error) {
+	blocks, err := exportBlocks(proc.Children)
+	if err != nil {
+		return "", err
+	}
+	lines := strings.Split(strings.Join(blocks, "\n\n"), "\n")
+	for i, line := range lines {
+		if line == "" {
+			lines[i] = ">"
+		} else {
+			lines[i] = "> " + line
+		}
+	}
+	return strings.Join(lines, "\n"), nil
+}
+
+func exportList(list *ahm.Proc) (string, error) {
+	start := 1
+	if list.Name == Numbered && list.Title != "" {
+		n, err := strconv.Atoi(strings.TrimSpace(list.Title))
+		if err != nil {
+			return "", ahmerr.Diagnosef(list.Span, "the start of a numbered list must be a number, not %q", list.Title)
+		}
+		start = n
+	}
+
+	var items []string
+	loose := false
+	for i, child := range list.Children {
+		item, ok := child.(*ahm.Proc)
+		if !ok || item.Name != Item {
+			return "", ahmerr.Diagnosef(ahm.SpanOf(child), "a list can only contain %s procs", Item)
+		}
+
+		marker := "- "
+		if list.Name == Numbered {
+			marker = strconv.Itoa(start+i) + ". "
+		}
+
+		blocks, err := exportBlocks(item.Children)
+		if err != nil {
+			return "", err
+		}
+		content := strings.Join(blocks, "\n\n")
+		loose = loose || len(blocks) > 1 || strings.Contains(content, "\n\n")
+		items = append(items, marker+indentLines(content, len(marker)))
+	}
+
+	if loose {
+		return strings.Join(items, "\n\n"), nil
+	}
+	return strings.Join(items, "\n"), nil
+}
+
+// indentLines indents all the lines of a block except the first one.
+func indentLines(block string, n int) string {
+	lines := strings.Split(block, "\n")
+	for i := 1; i < len(lines); i++ {
+		if lines[i] != "" {
+			lines[i] = strings.Repeat(" ", n) + lines[i]
+		}
+	}
+	return strings.Join(lines, "\n")
+}
+
+func exportInline(parts []ahm.Node) (string, error) {
+	var out strings.Builder
+	for _, part := range parts {
+		switch part := part.(type) {
+		case *ahm.Text:
+			out.WriteString(escapeLines(part.Text, out.Len() == 0 || strings.HasSuffix(out.String(), "\n")))
+
+		case *ahm.Proc:
+			inline, err := exportInlineProc(part)
+			if err != nil {
+				return "", err
+			}
+			out.WriteString(inline)
+		}
+	}
+	return out.String(), nil
+}
+
+func exportInlineProc(proc *ahm.Proc) (string, error) {
+	switch proc.Name {
+	case Emphasis:
+		return "*" + escapeText(proc.Title, false) + "*", nil
+	case Strong:
+		return "**" + escapeText(proc.Title, false) + "**", nil
+	case Teletype:
+		fence := "`"
+		for strings.Contains(proc.Title, fence) {
+			fence += "`"
+		}
+		if strings.HasPrefix(proc.Title, "`") || strings.HasSuffix(proc.Title, "`") {
+			return fence + " " + proc.Title + " " + fence, nil
+		}
+		return fence + proc.Title + fence, nil
+	case Link, Image:
+		url, label := splitLink(proc.Title)
+		prefix := ""
+		if proc.Name == Image {
+			prefix = "!"
+		} else if label == "" {
+			return "<" + url + ">", nil
+		}
+		return prefix + "[" + escapeText(label, false) + "](" + url + ")", nil
+	default:
+		return "", ahmerr.Diagnosef(proc.Span, "inline proc %s has no Markdown equivalent", proc.Name)
+	}
+}
+
+var lineStartSyntax = regexp.MustCompile(`^(?:#|>|[-+*](?:\s|$)|\d+[.)](?:\s|$)|=+\s*$|-+\s*$)`)
+
+func escapeLines(text string, atLineStart bool) string {
+	lines := strings.Split(text, "\n")
+	for i, line := range lines {
+		lines[i] = escapeText(line, atLineStart || i > 0)
+	}
+	return strings.Join(lines, "\n")
+}
+
+// escapeText escapes the characters that Markdown would treat as syntax.
+func escapeText(text string, atLineStart bool) string {
+	var out strings.Builder
+	for _, r := range text {
+		if strings.ContainsRune("\\`*_[]<", r) {
+			out.WriteRune('\\')
+		}
+		out.WriteRune(r)
+	}
+	escaped := out.String()
+	if !atLineStart || !lineStartSyntax.MatchString(escaped) {
+		return escaped
+	}
+	if orderedMarker.MatchString(escaped) {
+		digits := len(escaped) - len(strings.TrimLeft(escaped, "0123456789"))
+		return escaped[:digits] + "\\" + escaped[digits:]
+	}
+	return "\\" + escaped
+}
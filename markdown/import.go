@@ -0,0 +1,394 @@
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package markdown
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/szabba/ahm"
+)
+
+// Import converts Markdown into nodes of the document dialect.
+func Import(r io.Reader) ([]ahm.Node, error) {
+	var lines []string
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(nil, 1<<20)
+	for scanner.Scan() {
+		lines = append(lines, scanner.Text())
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	return parseBlocks(lines), nil
+}
+
+// ImportString converts Markdown into nodes of the document dialect.
+func ImportString(src string) []ahm.Node {
+	nodes, _ := Import(strings.NewReader(src))
+	return nodes
+}
+
+var (
+	atxHeading     = regexp.MustCompile(`^(#{1,6})(?:[ \t]+(.*?))?(?:[ \t]+#+)?[ \t]*$`)
+	thematicBreak  = regexp.MustCompile(`^(?:(?:\*[ \t]*){3,}|(?:-[ \t]*){3,}|(?:_[ \t]*){3,})$`)
+	fenceOpen      = regexp.MustCompile("^(`{3,}|~{3,})[ \\t]*(.*)$")
+	bulletMarker   = regexp.MustCompile(`^([-+*])(?:[ \t]+|$)`)
+	orderedMarker  = regexp.MustCompile(`^(\d{1,9})([.)])(?:[ \t]+|$)`)
+	setextUnderEq  = regexp.MustCompile(`^=+[ \t]*$`)
+	setextUnderDsh = regexp.MustCompile(`^-+[ \t]*$`)
+)
+
+// parseBlocks parses the lines of a container, like the document or a list item.
+func parseBlocks(lines []string) []ahm.Node {
+	var nodes []ahm.Node
+	for i := 0; i < len(lines); {
+		if isBlank(lines[i]) {
+			i++
+			continue
+		}
+		node, n := parseBlock(lines[i:])
+		nodes = appendBlock(nodes, node)
+		i += n
+	}
+	return nodes
+}
+
+// appendBlock appends a node, merging consecutive paragraphs.
+func appendBlock(nodes []ahm.Node, node ahm.Node) []ahm.Node {
+	if len(nodes) == 0 || !isProse(node) || !isProse(nodes[len(nodes)-1]) {
+		return append(nodes, node)
+	}
+	last := nodes[len(nodes)-1]
+	parts := append(partsOf(last), &ahm.Text{Text: "\n\n"})
+	parts = append(parts, partsOf(node)...)
+	nodes[len(nodes)-1] = prose(parts)
+	return nodes
+}
+
+func parseBlock(lines []string) (ahm.Node, int) {
+	line := lines[0]
+	indent := indentOf(line)
+	if indent >= 4 {
+		return parseIndentedCode(lines)
+	}
+	trimmed := trimIndent(line)
+
+	switch {
+	case atxHeading.MatchString(trimmed):
+		m := atxHeading.FindStringSubmatch(trimmed)
+		return heading(len(m[1]), m[2]), 1
+
+	case thematicBreak.MatchString(trimmed):
+		return &ahm.Proc{Name: Rule}, 1
+
+	case fenceOpen.MatchString(trimmed) && validFence(trimmed):
+		return parseFencedCode(lines, indent)
+
+	case strings.HasPrefix(trimmed, ">"):
+		return parseQuote(lines)
+
+	case bulletMarker.MatchString(trimmed) || orderedMarker.MatchString(trimmed):
+		return parseList(lines)
+
+	default:
+		return parseParagraph(lines)
+	}
+}
+
+func heading(level int, text string) ahm.Node {
+	return &ahm.Proc{Name: fmt.Sprintf("H%d", level), Title: plainText(parseInline(strings.TrimSpace(text)))}
+}
+
+func validFence(line string) bool {
+	m := fenceOpen.FindStringSubmatch(line)
+	return m[1][0] != '`' || !strings.Contains(m[2], "`")
+}
+
+func parseIndentedCode(lines []string) (ahm.Node, int) {
+	var code []string
+	n := 0
+	for n < len(lines) && (isBlank(lines[n]) || indentOf(lines[n]) >= 4) {
+		code = append(code, stripIndent(lines[n], 4))
+		n++
+	}
+	for len(code) > 0 && isBlank(code[len(code)-1]) {
+		code = code[:len(code)-1]
+	}
+	return codeBlock("", code), n
+}
+
+func parseFencedCode(lines []string, indent int) (ahm.Node, int) {
+	m := fenceOpen.FindStringSubmatch(trimIndent(lines[0]))
+	fence, info := m[1], strings.Fields(m[2])
+
+	var code []string
+	n := 1
+	for ; n < len(lines); n++ {
+		line := lines[n]
+		if closesFence(line, fence) {
+			n++
+			break
+		}
+		code = append(code, stripIndent(line, indent))
+	}
+
+	lang := ""
+	if len(info) > 0 {
+		lang = info[0]
+	}
+	return codeBlock(lang, code), n
+}
+
+func closesFence(line, fence string) bool {
+	indent := indentOf(line)
+	if indent >= 4 {
+		return false
+	}
+	trimmed := strings.TrimRight(trimIndent(line), " \t")
+	return len(trimmed) >= len(fence) && strings.Trim(trimmed, fence[:1]) == ""
+}
+
+func codeBlock(lang string, code []string) ahm.Node {
+	proc := &ahm.Proc{Name: Code, Title: lang}
+	if len(code) > 0 {
+		proc.Children = []ahm.Node{&ahm.Text{Text: strings.Join(code, "\n")}}
+	}
+	return proc
+}
+
+func parseQuote(lines []string) (ahm.Node, int) {
+	var inner []string
+	n := 0
+	for ; n < len(lines); n++ {
+		line := lines[n]
+		indent := indentOf(line)
+		switch {
+		case indent < 4 && strings.HasPrefix(trimIndent(line), ">"):
+			rest := trimIndent(line)[1:]
+			rest = strings.TrimPrefix(rest, " ")
+			inner = append(inner, rest)
+
+		case n > 0 && !isBlank(line) && !isBlank(inner[len(inner)-1]) && !startsBlock(line):
+			inner = append(inner, line)
+
+		default:
+			return &ahm.Proc{Name: Quote, Children: parseBlocks(inner)}, n
+		}
+	}
+	return &ahm.Proc{Name: Quote, Children: parseBlocks(inner)}, n
+}
+
+// A listMarker describes the start of a list item.
+type listMarker struct {
+	ordered   bool
+	delimiter string
+	start     int
+	markerLen int
+	// width is the offset of the item's content from the start of the line.
+	width int
+}
+
+func markerAt(line string) (listMarker, bool) {
+	indent := indentOf(line)
+	if indent >= 4 {
+		return listMarker{}, false
+	}
+	trimmed := trimIndent(line)
+
+	var marker listMarker
+	var m []string
+	if m = bulletMarker.FindStringSubmatch(trimmed); m != nil {
+		marker.delimiter = m[1]
+	} else if m = orderedMarker.FindStringSubmatch(trimmed); m != nil {
+		marker.ordered = true
+		marker.delimiter = m[2]
+		marker.start, _ = strconv.Atoi(m[1])
+	} else {
+		return listMarker{}, false
+	}
+
+	marker.markerLen = len(strings.TrimRight(m[0], " \t"))
+	spaces := indentOf(strings.Repeat(" ", indent+marker.markerLen)+m[0][marker.markerLen:]) - indent - marker.markerLen
+	if spaces > 4 || spaces == 0 {
+		spaces = 1
+	}
+	marker.width = indent + marker.markerLen + spaces
+	return marker, true
+}
+
+func parseList(lines []string) (ahm.Node, int) {
+	first, _ := markerAt(lines[0])
+	list := &ahm.Proc{Name: Bullets}
+	if first.ordered {
+		list.Name = Numbered
+		if first.start != 1 {
+			list.Title = strconv.Itoa(first.start)
+		}
+	}
+
+	n := 0
+	for n < len(lines) {
+		marker, ok := markerAt(lines[n])
+		if !ok || marker.ordered != first.ordered || marker.delimiter != first.delimiter {
+			break
+		}
+		item, size := parseItem(lines[n:], marker)
+		list.Children = append(list.Children, item)
+		n += size
+
+		if n < len(lines) && isBlank(lines[n]) {
+			next := nextNonBlank(lines, n)
+			if next == len(lines) {
+				n = next
+				break
+			}
+			if _, ok := markerAt(lines[next]); !ok {
+				break
+			}
+			n = next
+		}
+	}
+	return list, n
+}
+
+func parseItem(lines []string, marker listMarker) (ahm.Node, int) {
+	content := []string{afterMarker(lines[0], marker)}
+
+	n := 1
+	for ; n < len(lines); n++ {
+		line := lines[n]
+		switch {
+		case isBlank(line):
+			next := nextNonBlank(lines, n)
+			if next == len(lines) || indentOf(lines[next]) < marker.width {
+				return item(content), n
+			}
+			content = append(content, "")
+
+		case indentOf(line) >= marker.width:
+			content = append(content, stripIndent(line, marker.width))
+
+		case !isBlank(content[len(content)-1]) && !startsBlock(line) && !startsItem(line):
+			content = append(content, line)
+
+		default:
+			return item(content), n
+		}
+	}
+	return item(content), n
+}
+
+func startsItem(line string) bool {
+	_, ok := markerAt(line)
+	return ok
+}
+
+func item(content []string) ahm.Node {
+	return &ahm.Proc{Name: Item, Children: parseBlocks(content)}
+}
+
+func parseParagraph(lines []string) (ahm.Node, int) {
+	var text []string
+	n := 0
+	for ; n < len(lines); n++ {
+		line := lines[n]
+		if isBlank(line) {
+			break
+		}
+		if n > 0 {
+			trimmed := trimIndent(line)
+			if setextUnderEq.MatchString(trimmed) {
+				return heading(1, strings.Join(text, "\n")), n + 1
+			}
+			if setextUnderDsh.MatchString(trimmed) {
+				return heading(2, strings.Join(text, "\n")), n + 1
+			}
+			if startsBlock(line) {
+				break
+			}
+		}
+		text = append(text, strings.TrimSpace(line))
+	}
+	return prose(parseInline(strings.Join(text, "\n"))), n
+}
+
+// startsBlock checks whether a line interrupts a paragraph.
+func startsBlock(line string) bool {
+	indent := indentOf(line)
+	if indent >= 4 {
+		return false
+	}
+	trimmed := trimIndent(line)
+	if marker, ok := markerAt(line); ok {
+		rest := strings.TrimSpace(afterMarker(line, marker))
+		return rest != "" && (!marker.ordered || marker.start == 1)
+	}
+	return atxHeading.MatchString(trimmed) ||
+		thematicBreak.MatchString(trimmed) ||
+		fenceOpen.MatchString(trimmed) && validFence(trimmed) ||
+		strings.HasPrefix(trimmed, ">")
+}
+
+func nextNonBlank(lines []string, from int) int {
+	for from < len(lines) && isBlank(lines[from]) {
+		from++
+	}
+	return from
+}
+
+func isBlank(line string) bool { return strings.TrimSpace(line) == "" }
+
+// indentOf is the width of the indentation of a line, with tab stops every 4 columns.
+func indentOf(line string) int {
+	cols := 0
+	for _, r := range line {
+		switch r {
+		case ' ':
+			cols++
+		case '\t':
+			cols += 4 - cols%4
+		default:
+			return cols
+		}
+	}
+	return cols
+}
+
+func trimIndent(line string) string { return strings.TrimLeft(line, " \t") }
+
+// stripIndent removes up to n columns of indentation from a line.
+// A tab that is only partly removed leaves behind spaces.
+func stripIndent(line string, n int) string {
+	cols := 0
+	for i, r := range line {
+		if cols >= n {
+			return line[i:]
+		}
+		switch r {
+		case ' ':
+			cols++
+		case '\t':
+			next := cols + 4 - cols%4
+			if next > n {
+				return strings.Repeat(" ", next-n) + line[i+1:]
+			}
+			cols = next
+		default:
+			return line[i:]
+		}
+	}
+	return ""
+}
+
+// afterMarker is the content on the line of a list marker.
+func afterMarker(line string, marker listMarker) string {
+	rest := trimIndent(line)[marker.markerLen:]
+	return stripIndent(rest, marker.width-marker.markerLen-indentOf(line))
+}
@@ -0,0 +1,308 @@
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package markdown
+
+import (
+	"strings"
+	"unicode"
+
+	"github.com/szabba/ahm"
+)
+
+const asciiPunctuation = "!\"#$%&'()*+,-./:;<=>?@[\\]^_`{|}~"
+
+// parseInline splits the text of a paragraph into text and inline procs.
+func parseInline(src string) []ahm.Node {
+	p := inlineParser{src: src}
+	p.parse()
+	return p.parts
+}
+
+type inlineParser struct {
+	src   string
+	i     int
+	text  strings.Builder
+	parts []ahm.Node
+}
+
+func (p *inlineParser) parse() {
+	for p.i < len(p.src) {
+		c := p.src[p.i]
+		switch {
+		case c == '\\' && p.i+1 < len(p.src) && strings.IndexByte(asciiPunctuation, p.src[p.i+1]) >= 0:
+			p.text.WriteByte(p.src[p.i+1])
+			p.i += 2
+
+		case c == '\\' && p.i+1 < len(p.src) && p.src[p.i+1] == '\n':
+			p.text.WriteByte('\n')
+			p.i += 2
+
+		case c == '`' && p.codeSpan():
+		case c == '!' && p.link(Image, p.i+1):
+		case c == '[' && p.link(Link, p.i):
+		case c == '<' && p.autolink():
+		case (c == '*' || c == '_') && p.emphasis():
+
+		default:
+			p.text.WriteByte(c)
+			p.i++
+		}
+	}
+	p.flush()
+}
+
+func (p *inlineParser) flush() {
+	if p.text.Len() > 0 {
+		p.parts = append(p.parts, &ahm.Text{Text: p.text.String()})
+		p.text.Reset()
+	}
+}
+
+// emit adds an inline proc, or its argument as text if the argument cannot be written inline.
+func (p *inlineParser) emit(name, arg, fallback string) {
+	arg = strings.ReplaceAll(arg, "\n", " ")
+	if !balancedBraces(arg) {
+		p.text.WriteString(fallback)
+		return
+	}
+	p.flush()
+	p.parts = append(p.parts, &ahm.Proc{Name: name, Title: arg})
+}
+
+func (p *inlineParser) codeSpan() bool {
+	open := runLength(p.src, p.i, '`')
+	for j := p.i + open; j < len(p.src); {
+		k := strings.IndexByte(p.src[j:], '`')
+		if k < 0 {
+			break
+		}
+		j += k
+		n := runLength(p.src, j, '`')
+		if n == open {
+			code := strings.ReplaceAll(p.src[p.i+open:j], "\n", " ")
+			if len(code) > 2 && code[0] == ' ' && code[len(code)-1] == ' ' && strings.Trim(code, " ") != "" {
+				code = code[1 : len(code)-1]
+			}
+			p.emit(Teletype, code, code)
+			p.i = j + n
+			return true
+		}
+		j += n
+	}
+	p.text.WriteString(p.src[p.i : p.i+open])
+	p.i += open
+	return true
+}
+
+// link parses a link or an image, starting at the opening bracket.
+func (p *inlineParser) link(name string, bracket int) bool {
+	if bracket >= len(p.src) || p.src[bracket] != '[' {
+		return false
+	}
+	close := matchingBracket(p.src, bracket)
+	if close < 0 || close+1 >= len(p.src) || p.src[close+1] != '(' {
+		return false
+	}
+	end := strings.IndexByte(p.src[close+2:], ')')
+	if end < 0 {
+		return false
+	}
+	end += close + 2
+
+	dest := strings.TrimSpace(p.src[close+2 : end])
+	if fields := strings.Fields(dest); len(fields) > 0 {
+		dest = strings.Trim(fields[0], "<>")
+	}
+	if dest == "" || strings.ContainsAny(dest, " {}") {
+		return false
+	}
+
+	label := plainText(parseInline(p.src[bracket+1 : close]))
+	arg := dest
+	if label != "" {
+		arg += " " + label
+	}
+	p.emit(name, arg, label)
+	p.i = end + 1
+	return true
+}
+
+func (p *inlineParser) autolink() bool {
+	end := strings.IndexByte(p.src[p.i:], '>')
+	if end < 0 {
+		return false
+	}
+	url := p.src[p.i+1 : p.i+end]
+	if !strings.Contains(url, ":") || strings.ContainsAny(url, " \n<{}") {
+		return false
+	}
+	p.emit(Link, url, url)
+	p.i += end + 1
+	return true
+}
+
+// emphasis parses emphasis or strong emphasis, with simpler delimiter rules than CommonMark's.
+func (p *inlineParser) emphasis() bool {
+	c := p.src[p.i]
+	n := runLength(p.src, p.i, c)
+	if n > 2 {
+		return false
+	}
+	if c == '_' && p.i > 0 && isWordByte(p.src[p.i-1]) {
+		return false
+	}
+	start := p.i + n
+	if start >= len(p.src) || unicode.IsSpace(rune(p.src[start])) {
+		return false
+	}
+
+	for j := start; j < len(p.src); j++ {
+		if p.src[j] != c {
+			continue
+		}
+		m := runLength(p.src, j, c)
+		if m == n && !unicode.IsSpace(rune(p.src[j-1])) && !(c == '_' && j+m < len(p.src) && isWordByte(p.src[j+m])) {
+			name := Emphasis
+			if n == 2 {
+				name = Strong
+			}
+			content := plainText(parseInline(p.src[start:j]))
+			p.emit(name, content, content)
+			p.i = j + m
+			return true
+		}
+		j += m - 1
+	}
+	return false
+}
+
+func matchingBracket(src string, open int) int {
+	depth := 0
+	for i := open; i < len(src); i++ {
+		switch src[i] {
+		case '\\':
+			i++
+		case '[':
+			depth++
+		case ']':
+			depth--
+			if depth == 0 {
+				return i
+			}
+		}
+	}
+	return -1
+}
+
+func runLength(src string, i int, c byte) int {
+	n := 0
+	for i+n < len(src) && src[i+n] == c {
+		n++
+	}
+	return n
+}
+
+func isWordByte(c byte) bool {
+	return c == '_' || c >= '0' && c <= '9' || c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z'
+}
+
+func balancedBraces(s string) bool {
+	depth := 0
+	for _, r := range s {
+		switch r {
+		case '{':
+			depth++
+		case '}':
+			depth--
+			if depth < 0 {
+				return false
+			}
+		}
+	}
+	return depth == 0
+}
+
+// plainText is the text of inline nodes, with the procs replaced by the text they stand for.
+func plainText(parts []ahm.Node) string {
+	var out strings.Builder
+	for _, part := range parts {
+		switch part := part.(type) {
+		case *ahm.Text:
+			out.WriteString(part.Text)
+		case *ahm.Proc:
+			out.WriteString(procText(part))
+		}
+	}
+	return out.String()
+}
+
+// procText is the text an inline proc displays.
+func procText(proc *ahm.Proc) string {
+	switch proc.Name {
+	case Link, Image:
+		url, label := splitLink(proc.Title)
+		if label == "" {
+			return url
+		}
+		return label
+	default:
+		return proc.Title
+	}
+}
+
+// splitLink splits the argument of a LINK or IMG into the URL and the label.
+func splitLink(arg string) (url, label string) {
+	arg = strings.TrimSpace(arg)
+	i := strings.IndexAny(arg, " \t")
+	if i < 0 {
+		return arg, ""
+	}
+	return arg[:i], strings.TrimSpace(arg[i+1:])
+}
+
+// prose turns inline nodes into a single text or paragraph node.
+func prose(parts []ahm.Node) ahm.Node {
+	var merged []ahm.Node
+	for _, part := range parts {
+		text, ok := part.(*ahm.Text)
+		if !ok || len(merged) == 0 {
+			merged = append(merged, part)
+			continue
+		}
+		if last, ok := merged[len(merged)-1].(*ahm.Text); ok {
+			merged[len(merged)-1] = &ahm.Text{Text: last.Text + text.Text}
+			continue
+		}
+		merged = append(merged, part)
+	}
+
+	if len(merged) == 1 {
+		if text, ok := merged[0].(*ahm.Text); ok {
+			return text
+		}
+	}
+	if len(merged) == 0 {
+		return &ahm.Text{}
+	}
+	return &ahm.Paragraph{Children: merged}
+}
+
+func partsOf(node ahm.Node) []ahm.Node {
+	switch node := node.(type) {
+	case *ahm.Paragraph:
+		return node.Children
+	default:
+		return []ahm.Node{node}
+	}
+}
+
+func isProse(node ahm.Node) bool {
+	switch node.(type) {
+	case *ahm.Text, *ahm.Paragraph:
+		return true
+	default:
+		return false
+	}
+}
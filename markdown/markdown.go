@@ -0,0 +1,39 @@
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+// Package markdown converts between Markdown and the document dialect.
+//
+// The supported subset of CommonMark maps to procs like this:
+//
+//	# Heading           @H1 Heading
+//	```go               @CODE go
+//	> quote             @QUOTE
+//	- item              @UL with @LI children
+//	1. item             @OL with @LI children, titled with the start number if it is not 1
+//	***                 @HR
+//	*emphasis*          @EM{emphasis}
+//	**strong**          @STRONG{strong}
+//	`code`              @TT{code}
+//	[text](url)         @LINK{url text}
+//	![alt](src)         @IMG{src alt}
+//
+// Paragraphs become text, and consecutive paragraphs become a single text node, separated by a blank line,
+// because that is how they would be parsed from an AHM file.
+// The argument of an inline proc is plain text, so emphasis nested in a link, for instance, is lost.
+package markdown
+
+const (
+	Code      = "CODE"
+	Quote     = "QUOTE"
+	Bullets   = "UL"
+	Numbered  = "OL"
+	Item      = "LI"
+	Rule      = "HR"
+	Emphasis  = "EM"
+	Strong    = "STRONG"
+	Teletype  = "TT"
+	Link      = "LINK"
+	Image     = "IMG"
+	maxHeader = 6
+)
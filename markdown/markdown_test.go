@@ -0,0 +1,230 @@
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package markdown_test
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/szabba/ahm"
+	"github.com/szabba/ahm/ahmtest"
+	"github.com/szabba/ahm/assert"
+	"github.com/szabba/ahm/markdown"
+)
+
+func TestImportBlocks(t *testing.T) {
+	// given
+	src := lines(
+		"# A *title*",
+		"",
+		"Some text",
+		"over two lines.",
+		"",
+		"Another paragraph.",
+		"",
+		"```go",
+		"func main() {",
+		"\tprintln()",
+		"}",
+		"```",
+		"",
+		"> Quoted.",
+		"",
+		"---",
+		"",
+		"Setext",
+		"------")
+
+	want := []ahm.Node{
+		&ahm.Proc{Name: "H1", Title: "A title"},
+		&ahm.Text{Text: "Some text\nover two lines.\n\nAnother paragraph."},
+		&ahm.Proc{Name: "CODE", Title: "go", Children: []ahm.Node{
+			&ahm.Text{Text: "func main() {\n\tprintln()\n}"},
+		}},
+		&ahm.Proc{Name: "QUOTE", Children: []ahm.Node{&ahm.Text{Text: "Quoted."}}},
+		&ahm.Proc{Name: "HR"},
+		&ahm.Proc{Name: "H2", Title: "Setext"},
+	}
+
+	// when
+	got := markdown.ImportString(src)
+
+	// then
+	ahmtest.ReportDiffs(t.Errorf, got, want)
+}
+
+func TestImportLists(t *testing.T) {
+	// given
+	src := lines(
+		"- one",
+		"- two",
+		"  - nested",
+		"",
+		"3. three",
+		"4. four")
+
+	want := []ahm.Node{
+		&ahm.Proc{Name: "UL", Children: []ahm.Node{
+			&ahm.Proc{Name: "LI", Children: []ahm.Node{&ahm.Text{Text: "one"}}},
+			&ahm.Proc{Name: "LI", Children: []ahm.Node{
+				&ahm.Text{Text: "two"},
+				&ahm.Proc{Name: "UL", Children: []ahm.Node{
+					&ahm.Proc{Name: "LI", Children: []ahm.Node{&ahm.Text{Text: "nested"}}},
+				}},
+			}},
+		}},
+		&ahm.Proc{Name: "OL", Title: "3", Children: []ahm.Node{
+			&ahm.Proc{Name: "LI", Children: []ahm.Node{&ahm.Text{Text: "three"}}},
+			&ahm.Proc{Name: "LI", Children: []ahm.Node{&ahm.Text{Text: "four"}}},
+		}},
+	}
+
+	// when
+	got := markdown.ImportString(src)
+
+	// then
+	ahmtest.ReportDiffs(t.Errorf, got, want)
+}
+
+func TestImportInlines(t *testing.T) {
+	// given
+	src := "Some *emphasis*, **strong**, `code`, [a link](https://example.com) and \\*no emphasis\\*."
+
+	want := []ahm.Node{
+		&ahm.Paragraph{Children: []ahm.Node{
+			&ahm.Text{Text: "Some "},
+			&ahm.Proc{Name: "EM", Title: "emphasis"},
+			&ahm.Text{Text: ", "},
+			&ahm.Proc{Name: "STRONG", Title: "strong"},
+			&ahm.Text{Text: ", "},
+			&ahm.Proc{Name: "TT", Title: "code"},
+			&ahm.Text{Text: ", "},
+			&ahm.Proc{Name: "LINK", Title: "https://example.com a link"},
+			&ahm.Text{Text: " and *no emphasis*."},
+		}},
+	}
+
+	// when
+	got := markdown.ImportString(src)
+
+	// then
+	ahmtest.ReportDiffs(t.Errorf, got, want)
+}
+
+func TestImportedDocumentsCanBeFormatted(t *testing.T) {
+	// given
+	src := lines(
+		"# Title",
+		"",
+		"Text with `code`.",
+		"",
+		"- an item",
+		"",
+		"```",
+		"code",
+		"```")
+
+	nodes := markdown.ImportString(src)
+
+	// when
+	formatted, err := ahm.FormatString(nodes)
+
+	// then
+	assert.That(err == nil, t.Fatalf, "unexpected error: %s", err)
+	want := lines(
+		"@H1 Title",
+		"",
+		"Text with @TT{code}.",
+		"",
+		"@UL",
+		"  @LI",
+		"    an item",
+		"",
+		"@CODE",
+		"  code",
+		"")
+	assert.That(formatted == want, t.Errorf, "got\n%s\nwanted\n%s", formatted, want)
+}
+
+func TestExport(t *testing.T) {
+	// given
+	nodes := ahmtest.Parse(t, ahm.Config{},
+		"@H2 A header",
+		"",
+		"Some @EM{text} with a @LINK{https://example.com link}.",
+		"",
+		"1. Not a list.",
+		"",
+		"@OL",
+		"  @LI",
+		"    first",
+		"  @LI",
+		"    second",
+		"@CODE go",
+		"  x := 1")
+
+	want := lines(
+		"## A header",
+		"",
+		"Some *text* with a [link](https://example.com).",
+		"",
+		"1\\. Not a list.",
+		"",
+		"1. first",
+		"2. second",
+		"",
+		"```go",
+		"x := 1",
+		"```",
+		"")
+
+	// when
+	got, err := markdown.ExportString(nodes)
+
+	// then
+	assert.That(err == nil, t.Fatalf, "unexpected error: %s", err)
+	assert.That(got == want, t.Errorf, "got\n%s\nwanted\n%s", got, want)
+}
+
+func TestExportRejectsProcsWithoutAnEquivalent(t *testing.T) {
+	// given
+	nodes := ahmtest.Parse(t, ahm.Config{}, "@CARD x")
+
+	// when
+	_, err := markdown.ExportString(nodes)
+
+	// then
+	assert.That(err != nil, t.Errorf, "expected an error")
+}
+
+func TestExportThenImportRoundTrips(t *testing.T) {
+	// given
+	nodes := ahmtest.Parse(t, ahm.Config{},
+		"@H1 Title",
+		"Text with *stars*, @STRONG{bold} and @TT{code}.",
+		"",
+		"More text.",
+		"@QUOTE",
+		"  Quoted.",
+		"@UL",
+		"  @LI",
+		"    one",
+		"  @LI",
+		"    two",
+		"    @OL 2",
+		"      @LI",
+		"        nested",
+		"@HR")
+
+	// when
+	md, err := markdown.ExportString(nodes)
+	assert.That(err == nil, t.Fatalf, "unexpected error: %s", err)
+	got := markdown.ImportString(md)
+
+	// then
+	ahmtest.ReportDiffs(t.Errorf, got, nodes)
+}
+
+func lines(lines ...string) string { return strings.Join(lines, "\n") }
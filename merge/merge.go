@@ -0,0 +1,282 @@
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+// Package merge combines two sets of changes made to a document.
+//
+// The changes are found by comparing each version to their common base, as trees.
+// Nodes changed by one side only get that side's version, and nodes inserted by either side are kept.
+// When both sides changed a node differently, it is replaced with a conflict:
+//
+//	@CONFLICT
+//	  @BASE
+//	    The original.
+//	  @OURS
+//	    Our version.
+//	  @THEIRS
+//	    Their version.
+//
+// A side that deleted the node has an empty proc in the conflict.
+package merge
+
+import (
+	"sort"
+
+	"github.com/szabba/ahm"
+	"github.com/szabba/ahm/diff"
+)
+
+const (
+	ConflictProc = "CONFLICT"
+	BaseProc     = "BASE"
+	OursProc     = "OURS"
+	TheirsProc   = "THEIRS"
+)
+
+// Trees merges the changes made to the base in ours and theirs.
+// It also returns the number of conflicts in the result.
+func Trees(base, ours, theirs []ahm.Node) ([]ahm.Node, int) {
+	m := new(merger)
+	merged := m.children(base, ours, theirs)
+	return merged, m.conflicts
+}
+
+type merger struct {
+	conflicts int
+}
+
+// A side is a version of a list of siblings, matched up with the base.
+type side struct {
+	nodes []ahm.Node
+	// toBase maps the index of each node to the index of its base node, or -1 for an inserted one.
+	toBase []int
+	// fromBase maps the index of each base node to the index of the node it became, or -1 for a deleted one.
+	fromBase []int
+}
+
+func newSide(base, nodes []ahm.Node) *side {
+	s := &side{nodes: nodes, toBase: make([]int, len(nodes)), fromBase: make([]int, len(base))}
+	for i := range s.toBase {
+		s.toBase[i] = -1
+	}
+	for i := range s.fromBase {
+		s.fromBase[i] = -1
+	}
+	for _, pair := range diff.Match(base, nodes) {
+		s.fromBase[pair[0]] = pair[1]
+		s.toBase[pair[1]] = pair[0]
+	}
+	return s
+}
+
+func (s *side) at(baseIndex int) ahm.Node {
+	if i := s.fromBase[baseIndex]; i >= 0 {
+		return s.nodes[i]
+	}
+	return nil
+}
+
+// reordered checks whether the side changed the order of the base nodes it kept.
+func (s *side) reordered() bool {
+	last := -1
+	for _, i := range s.toBase {
+		if i < 0 {
+			continue
+		}
+		if i < last {
+			return true
+		}
+		last = i
+	}
+	return false
+}
+
+// insertions groups the inserted nodes by the base node they follow, with -1 for the start.
+func (s *side) insertions() map[int][]ahm.Node {
+	inserted := map[int][]ahm.Node{}
+	after := -1
+	for i, node := range s.nodes {
+		if s.toBase[i] >= 0 {
+			after = s.toBase[i]
+			continue
+		}
+		inserted[after] = append(inserted[after], node)
+	}
+	return inserted
+}
+
+// order sorts the base indices by their position in the side.
+// Base nodes the side deleted stay right after the base node preceding them.
+func (s *side) order(n int) []int {
+	keys := make([]float64, n)
+	last := -1.0
+	for i := 0; i < n; i++ {
+		if j := s.fromBase[i]; j >= 0 {
+			last = float64(j)
+			keys[i] = last
+		} else {
+			keys[i] = last + 0.5
+		}
+	}
+
+	order := make([]int, n)
+	for i := range order {
+		order[i] = i
+	}
+	sort.SliceStable(order, func(i, j int) bool { return keys[order[i]] < keys[order[j]] })
+	return order
+}
+
+func (m *merger) children(base, ours, theirs []ahm.Node) []ahm.Node {
+	o, t := newSide(base, ours), newSide(base, theirs)
+
+	merged := make([]ahm.Node, len(base))
+	for i, node := range base {
+		merged[i] = m.node(node, o.at(i), t.at(i))
+	}
+
+	order := o.order(len(base))
+	if !o.reordered() && t.reordered() {
+		order = t.order(len(base))
+	}
+
+	oursInserted, theirsInserted := o.insertions(), t.insertions()
+	var result []ahm.Node
+	appendInserted := func(after int) {
+		result = append(result, oursInserted[after]...)
+		for _, node := range theirsInserted[after] {
+			if !containsEqual(oursInserted[after], node) {
+				result = append(result, node)
+			}
+		}
+	}
+
+	appendInserted(-1)
+	for _, i := range order {
+		if merged[i] != nil {
+			result = append(result, merged[i])
+		}
+		appendInserted(i)
+	}
+	return joinProse(result)
+}
+
+// node merges the versions of a base node, with nil standing for a deleted one.
+func (m *merger) node(base, ours, theirs ahm.Node) ahm.Node {
+	switch {
+	case ours == nil && theirs == nil:
+		return nil
+	case ours == nil && ahm.Equals(theirs, base), theirs == nil && ahm.Equals(ours, base):
+		return nil
+	case ours == nil || theirs == nil:
+		return m.conflict(base, ours, theirs)
+	case ahm.Equals(ours, base):
+		return theirs
+	case ahm.Equals(theirs, base), ahm.Equals(ours, theirs):
+		return ours
+	}
+
+	baseProc, ok := base.(*ahm.Proc)
+	if !ok {
+		return m.conflict(base, ours, theirs)
+	}
+	ourProc, theirProc := ours.(*ahm.Proc), theirs.(*ahm.Proc)
+
+	titled := ourProc
+	switch {
+	case ourProc.Title == baseProc.Title:
+		titled = theirProc
+	case theirProc.Title == baseProc.Title, theirProc.Title == ourProc.Title:
+	default:
+		return m.conflict(base, ours, theirs)
+	}
+
+	return &ahm.Proc{
+		Name:     titled.Name,
+		Title:    titled.Title,
+		Args:     titled.Args,
+		Attrs:    titled.Attrs,
+		Children: m.children(baseProc.Children, ourProc.Children, theirProc.Children),
+		Span:     ourProc.Span,
+	}
+}
+
+func (m *merger) conflict(base, ours, theirs ahm.Node) ahm.Node {
+	m.conflicts++
+	return &ahm.Proc{
+		Name: ConflictProc,
+		Children: []ahm.Node{
+			version(BaseProc, base),
+			version(OursProc, ours),
+			version(TheirsProc, theirs),
+		},
+		Span: ahm.SpanOf(base),
+	}
+}
+
+func version(name string, node ahm.Node) ahm.Node {
+	proc := &ahm.Proc{Name: name}
+	if node != nil {
+		proc.Children = []ahm.Node{node}
+	}
+	return proc
+}
+
+func containsEqual(nodes []ahm.Node, node ahm.Node) bool {
+	for _, other := range nodes {
+		if ahm.Equals(other, node) {
+			return true
+		}
+	}
+	return false
+}
+
+// joinProse joins adjacent pieces of text, the way they would be parsed.
+func joinProse(nodes []ahm.Node) []ahm.Node {
+	var joined []ahm.Node
+	for _, node := range nodes {
+		if len(joined) == 0 || !isProse(node) || !isProse(joined[len(joined)-1]) {
+			joined = append(joined, node)
+			continue
+		}
+		last := joined[len(joined)-1]
+		parts := append(partsOf(last), &ahm.Text{Text: "\n"})
+		joined[len(joined)-1] = prose(append(parts, partsOf(node)...), ahm.SpanOf(last))
+	}
+	return joined
+}
+
+func prose(parts []ahm.Node, span ahm.Span) ahm.Node {
+	var merged []ahm.Node
+	for _, part := range parts {
+		if text, ok := part.(*ahm.Text); ok && len(merged) > 0 {
+			if last, ok := merged[len(merged)-1].(*ahm.Text); ok {
+				merged[len(merged)-1] = &ahm.Text{Text: last.Text + text.Text, Span: last.Span}
+				continue
+			}
+		}
+		merged = append(merged, part)
+	}
+	if len(merged) == 1 {
+		if text, ok := merged[0].(*ahm.Text); ok {
+			return text
+		}
+	}
+	return &ahm.Paragraph{Children: merged, Span: span}
+}
+
+func partsOf(node ahm.Node) []ahm.Node {
+	if para, ok := node.(*ahm.Paragraph); ok {
+		return para.Children
+	}
+	return []ahm.Node{node}
+}
+
+func isProse(node ahm.Node) bool {
+	switch node.(type) {
+	case *ahm.Text, *ahm.Paragraph:
+		return true
+	default:
+		return false
+	}
+}
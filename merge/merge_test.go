@@ -0,0 +1,146 @@
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package merge_test
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/szabba/ahm"
+	"github.com/szabba/ahm/ahmtest"
+	"github.com/szabba/ahm/assert"
+	"github.com/szabba/ahm/merge"
+)
+
+var base = []string{
+	"@EPISODE Test",
+	"  @CARD one",
+	"    First.",
+	"  @CARD two",
+	"    Second.",
+	"  @CARD three",
+	"    Third.",
+}
+
+func TestIndependentEditsAreCombined(t *testing.T) {
+	// given
+	ours := edit(base, "    First.", "    First, edited by us.")
+	theirs := edit(edit(base, "    Third.", "    Third, edited by them."), "  @CARD two\n    Second.\n", "")
+
+	// when
+	got, conflicts := merge.Trees(ahmtest.Parse(t, ahm.Config{}, base...), ahmtest.Parse(t, ahm.Config{}, ours...), ahmtest.Parse(t, ahm.Config{}, theirs...))
+
+	// then
+	assert.That(conflicts == 0, t.Errorf, "got %d conflicts, wanted none", conflicts)
+	assertFormatted(t, got,
+		"@EPISODE Test",
+		"  @CARD one",
+		"    First, edited by us.",
+		"  @CARD three",
+		"    Third, edited by them.")
+}
+
+func TestInsertionsFromBothSidesAreKept(t *testing.T) {
+	// given
+	ours := edit(base, "  @CARD two", "  @CARD ours\n  @CARD two")
+	theirs := edit(base, "  @CARD three", "  @CARD theirs\n  @CARD three")
+
+	// when
+	got, conflicts := merge.Trees(ahmtest.Parse(t, ahm.Config{}, base...), ahmtest.Parse(t, ahm.Config{}, ours...), ahmtest.Parse(t, ahm.Config{}, theirs...))
+
+	// then
+	assert.That(conflicts == 0, t.Errorf, "got %d conflicts, wanted none", conflicts)
+	assertFormatted(t, got,
+		"@EPISODE Test",
+		"  @CARD one",
+		"    First.",
+		"  @CARD ours",
+		"  @CARD two",
+		"    Second.",
+		"  @CARD theirs",
+		"  @CARD three",
+		"    Third.")
+}
+
+func TestConflictingEditsBecomeConflicts(t *testing.T) {
+	// given
+	ours := edit(base, "    Second.", "    Ours.")
+	theirs := edit(base, "    Second.", "    Theirs.")
+
+	// when
+	got, conflicts := merge.Trees(ahmtest.Parse(t, ahm.Config{}, base...), ahmtest.Parse(t, ahm.Config{}, ours...), ahmtest.Parse(t, ahm.Config{}, theirs...))
+
+	// then
+	assert.That(conflicts == 1, t.Errorf, "got %d conflicts, wanted 1", conflicts)
+	assertFormatted(t, got,
+		"@EPISODE Test",
+		"  @CARD one",
+		"    First.",
+		"  @CARD two",
+		"    @CONFLICT",
+		"      @BASE",
+		"        Second.",
+		"      @OURS",
+		"        Ours.",
+		"      @THEIRS",
+		"        Theirs.",
+		"  @CARD three",
+		"    Third.")
+}
+
+func TestDeletingAModifiedNodeIsAConflict(t *testing.T) {
+	// given
+	ours := edit(base, "  @CARD two\n    Second.\n", "")
+	theirs := edit(base, "    Second.", "    Changed.")
+
+	// when
+	got, conflicts := merge.Trees(ahmtest.Parse(t, ahm.Config{}, base...), ahmtest.Parse(t, ahm.Config{}, ours...), ahmtest.Parse(t, ahm.Config{}, theirs...))
+
+	// then
+	assert.That(conflicts == 1, t.Fatalf, "got %d conflicts, wanted 1", conflicts)
+	conflict := got[0].(*ahm.Proc).Children[1].(*ahm.Proc)
+	assert.That(conflict.Name == merge.ConflictProc, t.Fatalf, "got %s, wanted a conflict", conflict.Name)
+	assert.That(len(conflict.Children[1].(*ahm.Proc).Children) == 0, t.Errorf, "our version should be empty")
+}
+
+func TestReorderingOnOneSideIsKept(t *testing.T) {
+	// given
+	ours := []string{
+		"@EPISODE Test",
+		"  @CARD three",
+		"    Third.",
+		"  @CARD one",
+		"    First.",
+		"  @CARD two",
+		"    Second.",
+	}
+	theirs := edit(base, "    Second.", "    Second, edited.")
+
+	// when
+	got, conflicts := merge.Trees(ahmtest.Parse(t, ahm.Config{}, base...), ahmtest.Parse(t, ahm.Config{}, ours...), ahmtest.Parse(t, ahm.Config{}, theirs...))
+
+	// then
+	assert.That(conflicts == 0, t.Errorf, "got %d conflicts, wanted none", conflicts)
+	assertFormatted(t, got,
+		"@EPISODE Test",
+		"  @CARD three",
+		"    Third.",
+		"  @CARD one",
+		"    First.",
+		"  @CARD two",
+		"    Second, edited.")
+}
+
+func edit(lines []string, old, updated string) []string {
+	return strings.Split(strings.Replace(strings.Join(lines, "\n")+"\n", old, updated, 1), "\n")
+}
+
+func assertFormatted(t *testing.T, nodes []ahm.Node, lines ...string) {
+	t.Helper()
+	got, err := ahm.FormatString(nodes)
+	assert.That(err == nil, t.Fatalf, "cannot format: %s", err)
+	want := strings.Join(lines, "\n") + "\n"
+	assert.That(got == want, t.Errorf, "got\n%s\nwanted\n%s", got, want)
+}
@@ -0,0 +1,31 @@
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package ahm
+
+import "strings"
+
+// NamespaceSeparator splits a qualified proc name, like GO/FMT, into a namespace and a local name.
+const NamespaceSeparator = "/"
+
+// SplitName splits a proc name into a namespace and a local name.
+//
+// The namespace is "" for unqualified names.
+func SplitName(name string) (namespace, local string) {
+	i := strings.LastIndex(name, NamespaceSeparator)
+	if i < 0 {
+		return "", name
+	}
+	return name[:i], name[i+len(NamespaceSeparator):]
+}
+
+func (proc *Proc) Namespace() string {
+	namespace, _ := SplitName(proc.Name)
+	return namespace
+}
+
+func (proc *Proc) LocalName() string {
+	_, local := SplitName(proc.Name)
+	return local
+}
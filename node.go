@@ -4,10 +4,15 @@
 
 package ahm
 
+import "github.com/szabba/ahm/position"
+
 type Document struct {
 	nodes []Node
 }
 
+// Span locates a node in its source.
+type Span = position.SpanIn
+
 //go:generate irgen Node NodeConsumer
 
 type Node interface {
@@ -15,6 +20,9 @@ type Node interface {
 }
 
 type NodeConsumer interface {
-	Proc(Name, Title string, Children []Node)
-	Text(Text string)
+	Proc(Name, Title string, Args []Arg, Attrs []Attr, Children []Node, Span Span)
+	Text(Text string, Span Span)
+	// Paragraph is text interspersed with inline procs, like @NAME{arg}.
+	// Its children are Text and Proc nodes.
+	Paragraph(Children []Node, Span Span)
 }
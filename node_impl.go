@@ -4,11 +4,22 @@ package ahm
 
 type Proc struct {
 	Name, Title string
+	Args        []Arg
+	Attrs       []Attr
 	Children    []Node
+	Span        Span
 }
 type Text struct {
 	Text string
+	Span Span
+}
+type Paragraph struct {
+	Children []Node
+	Span     Span
 }
 
-func (Node *Proc) FeedTo(consumer NodeConsumer) { consumer.Proc(Node.Name, Node.Title, Node.Children) }
-func (Node *Text) FeedTo(consumer NodeConsumer) { consumer.Text(Node.Text) }
+func (Node *Proc) FeedTo(consumer NodeConsumer) {
+	consumer.Proc(Node.Name, Node.Title, Node.Args, Node.Attrs, Node.Children, Node.Span)
+}
+func (Node *Text) FeedTo(consumer NodeConsumer)      { consumer.Text(Node.Text, Node.Span) }
+func (Node *Paragraph) FeedTo(consumer NodeConsumer) { consumer.Paragraph(Node.Children, Node.Span) }
@@ -0,0 +1,202 @@
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+// Package ns resolves namespaced proc names, like GO/FMT, to the packages that handle them.
+//
+// A namespace has to be brought into scope before it can be used:
+//
+//	@IMPORT GO
+//	@GO/FMT
+//
+// An import can also give the package a different local alias:
+//
+//	@IMPORT GO AS G
+//	@G/FMT
+//
+// An import is in effect for the siblings following it, together with their descendants.
+package ns
+
+import (
+	"strings"
+
+	"github.com/szabba/ahm"
+	"github.com/szabba/ahm/ahmerr"
+)
+
+// ImportProc is the name of the proc that brings a namespace into scope.
+const ImportProc = "IMPORT"
+
+// A Package is a named set of procs that documents can import.
+type Package interface {
+	// Has checks whether the package handles procs with the given local name.
+	Has(local string) bool
+}
+
+// Names is a package that only knows which procs it has.
+type Names map[string]bool
+
+func (names Names) Has(local string) bool { return names[local] }
+
+type Registry struct {
+	packages map[string]Package
+}
+
+func NewRegistry() *Registry {
+	return &Registry{packages: map[string]Package{}}
+}
+
+// Register makes a package importable under the given name.
+// It replaces any package registered before under that name.
+func (reg *Registry) Register(name string, pkg Package) {
+	reg.packages[name] = pkg
+}
+
+func (reg *Registry) Lookup(name string) (Package, bool) {
+	pkg, ok := reg.packages[name]
+	return pkg, ok
+}
+
+// A Name is what a proc name resolves to.
+type Name struct {
+	// Package is the name the package was registered under, or "" for unqualified procs.
+	Package string
+	Local   string
+}
+
+func (name Name) String() string {
+	if name.Package == "" {
+		return name.Local
+	}
+	return name.Package + ahm.NamespaceSeparator + name.Local
+}
+
+// A Scope maps the aliases that are in effect at some point in a document to registered packages.
+type Scope struct {
+	registry *Registry
+	parent   *Scope
+	alias    string
+	pkg      string
+}
+
+// Scope returns a scope without any imports in effect.
+func (reg *Registry) Scope() *Scope {
+	return &Scope{registry: reg}
+}
+
+// IsImport checks whether a node is an import.
+func IsImport(node ahm.Node) bool {
+	proc, ok := node.(*ahm.Proc)
+	return ok && proc.Name == ImportProc
+}
+
+// Import returns the scope that is in effect after an import proc.
+//
+// When the import is malformed or names an unregistered package, the scope returned is the same as the receiver.
+func (scope *Scope) Import(proc *ahm.Proc) (*Scope, error) {
+	pkg, alias, ok := parseImport(proc.Title)
+	if !ok {
+		return scope, ahmerr.Diagnosef(proc.Span, "malformed import %q, wanted NAME or NAME AS ALIAS", proc.Title)
+	}
+	if _, ok := scope.registry.Lookup(pkg); !ok {
+		return scope, ahmerr.Diagnosef(proc.Span, "cannot import unknown package %s", pkg)
+	}
+	return &Scope{registry: scope.registry, parent: scope, alias: alias, pkg: pkg}, nil
+}
+
+func parseImport(title string) (pkg, alias string, ok bool) {
+	fields := strings.Fields(title)
+	switch {
+	case len(fields) == 1:
+		return fields[0], fields[0], true
+	case len(fields) == 3 && fields[1] == "AS":
+		return fields[0], fields[2], true
+	default:
+		return "", "", false
+	}
+}
+
+// Resolve determines what package a proc belongs to.
+func (scope *Scope) Resolve(proc *ahm.Proc) (Name, Package, error) {
+	namespace, local := ahm.SplitName(proc.Name)
+	if namespace == "" {
+		return Name{Local: local}, nil, nil
+	}
+
+	pkgName, ok := scope.lookupAlias(namespace)
+	if !ok {
+		return Name{}, nil, ahmerr.Diagnosef(proc.Span, "namespace %s used without an import", namespace)
+	}
+
+	pkg, _ := scope.registry.Lookup(pkgName)
+	if !pkg.Has(local) {
+		return Name{}, nil, ahmerr.Diagnosef(proc.Span, "package %s has no proc named %s", pkgName, local)
+	}
+	return Name{Package: pkgName, Local: local}, pkg, nil
+}
+
+func (scope *Scope) lookupAlias(alias string) (string, bool) {
+	for ; scope != nil; scope = scope.parent {
+		if scope.parent != nil && scope.alias == alias {
+			return scope.pkg, true
+		}
+	}
+	return "", false
+}
+
+// A Resolution tells what a single proc in a document resolved to.
+type Resolution struct {
+	Proc    *ahm.Proc
+	Name    Name
+	Package Package
+}
+
+// Resolve determines what every proc in a document, except for imports, resolves to.
+//
+// The resolutions are listed in document order.
+// Procs that fail to resolve are left out and reported in the diagnostics.
+func (reg *Registry) Resolve(nodes []ahm.Node) ([]Resolution, ahmerr.Diagnostics) {
+	res := &resolver{}
+	res.resolveSiblings(reg.Scope(), nodes)
+	return res.resolutions, res.diags
+}
+
+type resolver struct {
+	resolutions []Resolution
+	diags       ahmerr.Diagnostics
+}
+
+func (res *resolver) resolveSiblings(scope *Scope, nodes []ahm.Node) {
+	for _, node := range nodes {
+		if IsImport(node) {
+			var err error
+			scope, err = scope.Import(node.(*ahm.Proc))
+			res.report(err)
+			continue
+		}
+		res.resolveNode(scope, node)
+	}
+}
+
+func (res *resolver) resolveNode(scope *Scope, node ahm.Node) {
+	switch node := node.(type) {
+	case *ahm.Proc:
+		name, pkg, err := scope.Resolve(node)
+		if err != nil {
+			res.report(err)
+		} else {
+			res.resolutions = append(res.resolutions, Resolution{Proc: node, Name: name, Package: pkg})
+		}
+		res.resolveSiblings(scope, node.Children)
+
+	case *ahm.Paragraph:
+		res.resolveSiblings(scope, node.Children)
+	}
+}
+
+func (res *resolver) report(err error) {
+	if err == nil {
+		return
+	}
+	res.diags = append(res.diags, err.(*ahmerr.Diagnostic))
+}
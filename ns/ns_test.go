@@ -0,0 +1,102 @@
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package ns_test
+
+import (
+	"testing"
+
+	"github.com/szabba/ahm"
+	"github.com/szabba/ahm/ahmtest"
+	"github.com/szabba/ahm/assert"
+	"github.com/szabba/ahm/ns"
+)
+
+func TestImportedNamespaceIsResolved(t *testing.T) {
+	// given
+	reg := newRegistry()
+	nodes := ahmtest.Parse(t, ahm.Config{},
+		"@IMPORT GO",
+		"@GO/FMT",
+		"@TITLE x")
+
+	// when
+	resolutions, diags := reg.Resolve(nodes)
+
+	// then
+	assert.That(len(diags) == 0, t.Fatalf, "unexpected diagnostics: %s", diags)
+	assert.That(len(resolutions) == 2, t.Fatalf, "got %d resolutions, wanted 2", len(resolutions))
+	assert.That(resolutions[0].Name == ns.Name{Package: "GO", Local: "FMT"}, t.Errorf, "got name %s, wanted GO/FMT", resolutions[0].Name)
+	assert.That(resolutions[0].Package != nil, t.Errorf, "the package of GO/FMT must be set")
+	assert.That(resolutions[1].Name == ns.Name{Local: "TITLE"}, t.Errorf, "got name %s, wanted TITLE", resolutions[1].Name)
+}
+
+func TestImportCanIntroduceAnAlias(t *testing.T) {
+	// given
+	reg := newRegistry()
+	nodes := ahmtest.Parse(t, ahm.Config{},
+		"@IMPORT GO AS G",
+		"@CODE go",
+		"  @G/FMT")
+
+	// when
+	resolutions, diags := reg.Resolve(nodes)
+
+	// then
+	assert.That(len(diags) == 0, t.Fatalf, "unexpected diagnostics: %s", diags)
+	assert.That(len(resolutions) == 2, t.Fatalf, "got %d resolutions, wanted 2", len(resolutions))
+	assert.That(resolutions[1].Name == ns.Name{Package: "GO", Local: "FMT"}, t.Errorf, "got name %s, wanted GO/FMT", resolutions[1].Name)
+}
+
+func TestNamespaceUsedBeforeImportIsReported(t *testing.T) {
+	// given
+	reg := newRegistry()
+	nodes := ahmtest.Parse(t, ahm.Config{},
+		"@GO/FMT",
+		"@IMPORT GO")
+
+	// when
+	_, diags := reg.Resolve(nodes)
+
+	// then
+	assert.That(len(diags) == 1, t.Fatalf, "got %d diagnostics, wanted 1", len(diags))
+	assert.That(diags[0].Span.StartsAt().Line() == 1, t.Errorf, "got diagnostic at %s, wanted line 1", diags[0].Span)
+}
+
+func TestImportDoesNotLeakOutOfItsParent(t *testing.T) {
+	// given
+	reg := newRegistry()
+	nodes := ahmtest.Parse(t, ahm.Config{},
+		"@SECTION",
+		"  @IMPORT GO",
+		"@GO/FMT")
+
+	// when
+	_, diags := reg.Resolve(nodes)
+
+	// then
+	assert.That(len(diags) == 1, t.Fatalf, "got %d diagnostics, wanted 1", len(diags))
+	assert.That(diags[0].Span.StartsAt().Line() == 3, t.Errorf, "got diagnostic at %s, wanted line 3", diags[0].Span)
+}
+
+func TestUnknownProcsAndPackagesAreReported(t *testing.T) {
+	// given
+	reg := newRegistry()
+	nodes := ahmtest.Parse(t, ahm.Config{},
+		"@IMPORT RUST",
+		"@IMPORT GO",
+		"@GO/COMPILE")
+
+	// when
+	_, diags := reg.Resolve(nodes)
+
+	// then
+	assert.That(len(diags) == 2, t.Fatalf, "got %d diagnostics, wanted 2: %s", len(diags), diags)
+}
+
+func newRegistry() *ns.Registry {
+	reg := ns.NewRegistry()
+	reg.Register("GO", ns.Names{"FMT": true, "INCLUDE-FUNCTION-BODY": true})
+	return reg
+}
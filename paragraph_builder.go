@@ -0,0 +1,82 @@
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package ahm
+
+import (
+	"strings"
+
+	"github.com/szabba/ahm/ahmerr"
+	"github.com/szabba/ahm/position"
+)
+
+// A paragraphBuilder collects the parts of a paragraph.
+//
+// Consecutive text is gathered in a strings.Builder, so that long runs of lines take linear time.
+// Parts that do not follow one another break an invariant of the parser, kept in err.
+type paragraphBuilder struct {
+	source  string
+	parts   []Node
+	text    strings.Builder
+	span    position.Span
+	hasText bool
+	err     error
+}
+
+func (builder *paragraphBuilder) addText(text string, span position.Span) {
+	if builder.hasText {
+		builder.span = builder.extend(builder.span, span)
+	} else {
+		builder.span = span
+		builder.hasText = true
+	}
+	builder.text.WriteString(text)
+}
+
+func (builder *paragraphBuilder) addProc(proc *Proc) {
+	builder.flushText()
+	builder.parts = append(builder.parts, proc)
+}
+
+func (builder *paragraphBuilder) flushText() {
+	if !builder.hasText {
+		return
+	}
+	builder.parts = append(builder.parts, &Text{Text: builder.text.String(), Span: builder.span.In(builder.source)})
+	builder.text.Reset()
+	builder.hasText = false
+}
+
+// extend is the span from the start of one span to the end of a later one.
+func (builder *paragraphBuilder) extend(first, last position.Span) position.Span {
+	span, ok := position.SpanBetween(first.StartsAt(), last.EndsBefore())
+	if !ok && builder.err == nil {
+		builder.err = ahmerr.Internalf(last.In(builder.source), "paragraph part ends before %s", first.StartsAt())
+	}
+	return span
+}
+
+// build returns a plain Text node when there are no inline procs in the paragraph.
+//
+// It passes on err, unless there is none and building the paragraph broke an invariant.
+func (builder *paragraphBuilder) build(err error) (Node, error) {
+	builder.flushText()
+	if err == nil {
+		err = builder.err
+	}
+	if len(builder.parts) == 1 {
+		if text, ok := builder.parts[0].(*Text); ok {
+			return text, err
+		}
+	}
+	para := &Paragraph{Children: builder.parts}
+	if len(builder.parts) > 0 {
+		first, last := SpanOf(builder.parts[0]), SpanOf(builder.parts[len(builder.parts)-1])
+		para.Span = builder.extend(first.Span, last.Span).In(builder.source)
+		if err == nil {
+			err = builder.err
+		}
+	}
+	return para, err
+}
@@ -6,23 +6,75 @@ package ahm
 
 import (
 	"bufio"
-	"bytes"
 	"io"
-	"log"
+	"strings"
 
-	"github.com/pkg/errors"
-	"github.com/szabba/ahm/assert"
+	"github.com/szabba/ahm/ahmerr"
 	"github.com/szabba/ahm/internal/lexer"
 	"github.com/szabba/ahm/internal/token"
+	"github.com/szabba/ahm/position"
 )
 
+// Config describes how a Parser should read its input.
+type Config struct {
+	// Source names the input in the positions the parser reports.
+	Source string
+	// StructuredArgs enables splitting proc arguments into Args and Attrs.
+	// Title is filled in either way.
+	StructuredArgs bool
+	// Limits guard against untrusted input. There are none by default.
+	Limits Limits
+}
+
+// ParserVersion changes whenever some input starts to parse into different nodes than it did before.
+// Caches of parsed documents keep it along with them.
+const ParserVersion = 1
+
 type Parser struct {
+	config Config
 	tokens tokenStream
 }
 
 func NewParser(r io.Reader) *Parser {
+	return Config{}.NewParser(r)
+}
+
+func (cfg Config) NewParser(r io.Reader) *Parser {
+	p := new(Parser)
+	p.config = cfg
+	input := newLimitedInput(p.lexerSource(r), cfg.Limits, cfg.Source, position.First())
+	p.tokens = *newStream(cfg.Source, cfg.Limits, lexer.New(input))
+	return p
+}
+
+func NewBytesParser(src []byte) *Parser {
+	return Config{}.NewBytesParser(src)
+}
+
+// NewBytesParser creates a parser for a document held in memory.
+//
+// It parses the same as NewParser would, but with less copying.
+func (cfg Config) NewBytesParser(src []byte) *Parser {
+	return cfg.newStringParser(string(src))
+}
+
+// newStringParser creates a parser for a document held in a string, which it does not need to copy.
+//
+// The limits on the size of the document and on the length of its lines are checked up front.
+func (cfg Config) newStringParser(src string) *Parser {
+	p := new(Parser)
+	p.config = cfg
+	p.tokens = *newStream(cfg.Source, cfg.Limits, lexer.NewString(src))
+	p.tokens.err = cfg.Limits.checkString(cfg.Source, src)
+	return p
+}
+
+// resumeParser creates a parser for input that starts at a lexer checkpoint.
+func (cfg Config) resumeParser(r io.Reader, at lexer.Checkpoint) *Parser {
 	p := new(Parser)
-	p.tokens = *newStream(lexer.New(p.lexerSource(r)))
+	p.config = cfg
+	input := newLimitedInput(p.lexerSource(r), cfg.Limits, cfg.Source, at.Position)
+	p.tokens = *newStream(cfg.Source, cfg.Limits, lexer.Resume(input, at))
 	return p
 }
 
@@ -40,18 +92,26 @@ func (p *Parser) ParseAll() ([]Node, error) {
 	for {
 		node, err := p.Parse()
 
+		if node != nil && !isEmptyText(node) {
+			nodes = append(nodes, node)
+		}
+
 		if err == io.EOF {
 			return nodes, nil
 		} else if err != nil {
 			return nodes, err
 		}
-
-		nodes = append(nodes, node)
 	}
 }
 
+// isEmptyText checks for the empty text that is parsed after a final newline.
+func isEmptyText(node Node) bool {
+	text, ok := node.(*Text)
+	return ok && text.Text == ""
+}
+
 func (p *Parser) Parse() (Node, error) {
-	tok, err := p.tokens.peek(0)
+	tok, err := p.skipNewlines()
 	if err != nil {
 		return nil, err
 	}
@@ -59,6 +119,9 @@ func (p *Parser) Parse() (Node, error) {
 	switch tok.TokenType {
 
 	case token.ProcMark:
+		if p.inlineProcAhead(0) {
+			return p.parseText()
+		}
 		return p.parseProc()
 
 	case token.Text:
@@ -71,12 +134,17 @@ func (p *Parser) Parse() (Node, error) {
 
 func (p *Parser) parseProc() (Node, error) {
 	first, err := p.tokens.peek(0)
+	if err != nil {
+		return nil, p.internalError(first, "cannot parse proc: %s", err)
+	} else if first.TokenType != token.ProcMark {
+		return nil, p.internalError(first, "cannot parse proc: %s", p.unexpectedToken(first, token.ProcMark))
+	}
 
-	assert.That(err == nil, log.Panicf, "cannot parse proc: %s", err)
-	assert.That(first.TokenType == token.ProcMark, log.Panicf, "cannot parse text: %s", p.unexpectedToken(first, token.ProcMark))
-
-	p.tokens.accept(1)
+	if err := p.tokens.accept(1); err != nil {
+		return nil, err
+	}
 	proc := new(Proc)
+	proc.Span = first.Span.In(p.config.Source)
 
 	tok, err := p.tokens.peek(0)
 	if err != nil {
@@ -93,16 +161,45 @@ func (p *Parser) parseProc() (Node, error) {
 		return nil, p.unexpectedToken(tok, token.ProcArg)
 	}
 	proc.Title = tok.Text
+	proc.Span, err = p.spanBetween(first, tok)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := p.tokens.accept(2); err != nil {
+		return nil, err
+	}
 
-	p.tokens.accept(2)
+	err = p.structureArgs(proc, tok)
+	if err != nil {
+		return proc, err
+	}
 
 	proc.Children, err = p.parseNestedNodes()
+	if spanErr := p.extendOverChildren(proc); err == nil {
+		err = spanErr
+	}
 	if err == nil {
 		_, err = p.tokens.peek(0)
 	}
 	return proc, err
 }
 
+func (p *Parser) extendOverChildren(proc *Proc) error {
+	for i := len(proc.Children) - 1; i >= 0; i-- {
+		if proc.Children[i] != nil {
+			last := SpanOf(proc.Children[i])
+			span, ok := position.SpanBetween(proc.Span.StartsAt(), last.EndsBefore())
+			if !ok {
+				return ahmerr.Internalf(last, "the children of %s end before it starts", proc.Name)
+			}
+			proc.Span = span.In(p.config.Source)
+			return nil
+		}
+	}
+	return nil
+}
+
 func (p *Parser) parseNestedNodes() ([]Node, error) {
 	tok, err := p.tokens.peek(0)
 	if err != nil {
@@ -112,16 +209,20 @@ func (p *Parser) parseNestedNodes() ([]Node, error) {
 	} else if tok.TokenType != token.Newline {
 		return nil, p.unexpectedToken(tok, token.Newline, token.Dedent)
 	}
-	p.tokens.accept(1)
+	if err := p.tokens.accept(1); err != nil {
+		return nil, err
+	}
 
-	tok, err = p.tokens.peek(0)
+	tok, err = p.skipNewlines()
 	if err != nil {
 		return nil, nil
 	} else if tok.TokenType != token.Indent {
 		return nil, nil
 	}
 
-	p.tokens.accept(1)
+	if err := p.tokens.accept(1); err != nil {
+		return nil, err
+	}
 
 	nodes, err := p.parseNodesUntilDedent()
 	if err != nil {
@@ -134,7 +235,9 @@ func (p *Parser) parseNestedNodes() ([]Node, error) {
 	} else if tok.TokenType != token.Dedent {
 		return nil, p.unexpectedToken(tok, token.Dedent)
 	}
-	p.tokens.accept(1)
+	if err := p.tokens.accept(1); err != nil {
+		return nil, err
+	}
 
 	return nodes, nil
 }
@@ -153,47 +256,211 @@ func (p *Parser) parseNodesUntilDedent() ([]Node, error) {
 		} else if tok.TokenType == token.Dedent {
 			return nodes, nil
 		} else if tok.TokenType == token.Newline {
-			p.tokens.accept(1)
+			if err := p.tokens.accept(1); err != nil {
+				return nodes, err
+			}
 		}
 	}
 }
 
+// parseText parses text, possibly spanning multiple lines and containing inline procs.
+//
+// Lines indented deeper than the first one are part of the text, with the extra indentation kept.
 func (p *Parser) parseText() (Node, error) {
-	first, err := p.tokens.peek(0)
+	para := paragraphBuilder{source: p.config.Source}
+	var extraIndents []string
+
+	err := p.parseInlineRun(&para)
+	if err != nil {
+		return para.build(err)
+	}
+
+	for {
+		newlines := p.countNewlines()
+		dedents := p.countDedents(newlines)
+		next := newlines + dedents
 
-	assert.That(err == nil, log.Panicf, "cannot parse text: %s", err)
-	assert.That(first.TokenType == token.Text, log.Panicf, "cannot parse text: %s", p.unexpectedToken(first, token.Text))
+		tok, err := p.tokens.peek(next)
+		if newlines == 0 && dedents == 0 {
+			return para.build(err)
+		}
+
+		if dedents > len(extraIndents) {
+			if ours := newlines + len(extraIndents); ours > 0 {
+				return para.build(p.tokens.accept(ours))
+			}
+			return para.build(nil)
+		}
+		extraIndents = extraIndents[:len(extraIndents)-dedents]
+
+		continues := p.textAhead(next)
+		if err == nil && dedents == 0 && tok.TokenType == token.Indent && p.textAhead(next+1) {
+			extraIndents = append(extraIndents, tok.Text)
+			continues = true
+			next++
+		}
+
+		if !continues && len(extraIndents) > 0 {
+			return para.build(p.unexpectedToken(tok, token.Text, token.Dedent))
+		} else if !continues {
+			if dedents > 0 {
+				return para.build(p.tokens.accept(next))
+			}
+			return para.build(nil)
+		}
 
-	buf := bytes.NewBufferString(first.Text)
-	makeNode := func() Node { return &Text{buf.String()} }
-	p.tokens.accept(1)
+		for i := 0; i < newlines; i++ {
+			newl, _ := p.tokens.peek(i)
+			para.addText(newl.Text, newl.Span)
+		}
+		if err := p.tokens.accept(next); err != nil {
+			return para.build(err)
+		}
+
+		first, _ := p.tokens.peek(0)
+		para.addText(strings.Join(extraIndents, ""), first.Span.StartsAt().StartSpan())
+
+		err = p.parseInlineRun(&para)
+		if err != nil {
+			return para.build(err)
+		}
+	}
+}
 
+// parseInlineRun parses the text and inline procs making up the rest of a line.
+func (p *Parser) parseInlineRun(para *paragraphBuilder) error {
 	for {
 		tok, err := p.tokens.peek(0)
 		if err != nil {
-			return makeNode(), err
-		} else if tok.TokenType != token.Newline {
-			return makeNode(), nil
+			return err
 		}
 
-		newl := tok
+		switch {
+		case tok.TokenType == token.Text:
+			para.addText(tok.Text, tok.Span)
+			if err := p.tokens.accept(1); err != nil {
+				return err
+			}
+
+		case p.inlineProcAhead(0):
+			proc, err := p.parseInlineProc()
+			if err != nil {
+				return err
+			}
+			para.addProc(proc)
+
+		default:
+			return nil
+		}
+	}
+}
 
-		tok, err = p.tokens.peek(1)
-		if err != nil {
+func (p *Parser) parseInlineProc() (*Proc, error) {
+	want := []token.TokenType{token.ProcMark, token.ProcName, token.InlineOpen, token.InlineArg, token.InlineClose}
+
+	toks := make([]token.Token, len(want))
+	for i, typ := range want {
+		tok, err := p.tokens.peek(i)
+		if err == io.EOF {
+			return nil, ahmerr.Diagnosef(toks[0].Span.In(p.config.Source), "unterminated inline proc")
+		} else if err != nil {
 			return nil, err
-		} else if tok.TokenType == token.Dedent {
-			p.tokens.accept(1)
-			return makeNode(), err
-		} else if tok.TokenType != token.Text {
-			return makeNode(), err
+		} else if tok.TokenType != typ {
+			return nil, p.unexpectedToken(tok, typ)
+		}
+		toks[i] = tok
+	}
+	if err := p.tokens.accept(len(want)); err != nil {
+		return nil, err
+	}
+
+	span, err := p.spanBetween(toks[0], toks[4])
+	if err != nil {
+		return nil, err
+	}
+	proc := &Proc{Name: toks[1].Text, Title: toks[3].Text, Span: span}
+	return proc, p.structureArgs(proc, toks[3])
+}
+
+func (p *Parser) structureArgs(proc *Proc, arg token.Token) error {
+	if !p.config.StructuredArgs {
+		return nil
+	}
+	var err error
+	proc.Args, proc.Attrs, err = scanArgs(arg.Text, arg.Span.StartsAt(), p.config.Source)
+	return err
+}
+
+// countNewlines counts the consecutive newlines at the start of the lookahead.
+// There is more than one after blank lines.
+func (p *Parser) countNewlines() int {
+	n := 0
+	for {
+		tok, err := p.tokens.peek(n)
+		if err != nil || tok.TokenType != token.Newline {
+			return n
+		}
+		n++
+	}
+}
+
+func (p *Parser) skipNewlines() (token.Token, error) {
+	n := p.countNewlines()
+	if n > 0 {
+		if err := p.tokens.accept(n); err != nil {
+			return token.Token{}, err
+		}
+	}
+	return p.tokens.peek(0)
+}
+
+// countDedents counts the consecutive dedents d tokens ahead.
+func (p *Parser) countDedents(d int) int {
+	n := 0
+	for {
+		tok, err := p.tokens.peek(d + n)
+		if err != nil || tok.TokenType != token.Dedent {
+			return n
 		}
+		n++
+	}
+}
+
+// textAhead checks whether text or an inline proc starts d tokens ahead.
+//
+// The empty text that follows a final newline does not count.
+func (p *Parser) textAhead(d int) bool {
+	tok, err := p.tokens.peek(d)
+	if err == nil && tok.TokenType == token.Text {
+		_, err = p.tokens.peek(d + 1)
+		return tok.Text != "" || err != io.EOF
+	}
+	return p.inlineProcAhead(d)
+}
 
-		io.WriteString(buf, newl.Text)
-		io.WriteString(buf, tok.Text)
-		p.tokens.accept(2)
+// inlineProcAhead checks whether an inline proc starts d tokens ahead.
+func (p *Parser) inlineProcAhead(d int) bool {
+	mark, err := p.tokens.peek(d)
+	if err != nil || mark.TokenType != token.ProcMark {
+		return false
 	}
+	open, err := p.tokens.peek(d + 2)
+	return err == nil && open.TokenType == token.InlineOpen
+}
+
+// spanBetween is the span from the start of the first token to the end of the last one.
+func (p *Parser) spanBetween(first, last token.Token) (Span, error) {
+	span, ok := position.SpanBetween(first.Span.StartsAt(), last.Span.EndsBefore())
+	if !ok {
+		return Span{}, p.internalError(last, "%s ends before %s starts", last.TokenType, first.TokenType)
+	}
+	return span.In(p.config.Source), nil
+}
+
+func (p *Parser) internalError(at token.Token, msgFmt string, args ...interface{}) error {
+	return ahmerr.Internalf(at.Span.In(p.config.Source), msgFmt, args...)
 }
 
-func (*Parser) unexpectedToken(tok token.Token, typs ...token.TokenType) error {
-	return errors.Errorf("got %s, wanted one of the types %s", tok, typs)
+func (p *Parser) unexpectedToken(tok token.Token, typs ...token.TokenType) error {
+	return ahmerr.Diagnosef(tok.Span.In(p.config.Source), "got %s %q, wanted one of the types %s", tok.TokenType, tok.Text, typs)
 }
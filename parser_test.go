@@ -2,27 +2,32 @@
 // License, v. 2.0. If a copy of the MPL was not distributed with this
 // file, You can obtain one at http://mozilla.org/MPL/2.0/.
 
-package ahm
+package ahm_test
 
 import (
-	"bytes"
 	"io"
+	"os"
+	"path/filepath"
 	"strings"
 	"testing"
+	"testing/iotest"
 
-	"github.com/kr/pretty"
 	"github.com/pkg/errors"
+	"github.com/szabba/ahm"
+	"github.com/szabba/ahm/ahmerr"
+	. "github.com/szabba/ahm/ahmtest"
 	"github.com/szabba/ahm/assert"
+	"github.com/szabba/ahm/position"
 )
 
 func TestSingleLineTextIsRead(t *testing.T) {
 	// given
-	wantNode := &Text{"A line."}
+	wantNode := T("A line.")
 
 	rawInput := "A line."
 	input := strings.NewReader(rawInput)
 
-	parser := NewParser(input)
+	parser := ahm.NewParser(input)
 
 	// when
 	node, err := parser.Parse()
@@ -30,19 +35,19 @@ func TestSingleLineTextIsRead(t *testing.T) {
 	// then
 	assert.That(errors.Cause(err) == io.EOF, t.Fatalf, "got error %q, expected %q", err, io.EOF)
 	assert.That(node != nil, t.Fatalf, "the node returned must not be nil")
-	reportDiffs(t.Fatalf, node, wantNode)
+	ReportNodeDiffs(t.Fatalf, node, wantNode)
 }
 
 func TestMultipleLinesOfTextAreRead(t *testing.T) {
 	// given
-	wantNode := &Text{"Multiple\nlines."}
-	rawInput := multiline(
+	wantNode := T("Multiple\nlines.")
+	rawInput := Lines(
 		"Multiple",
 		"lines.")
 
 	input := strings.NewReader(rawInput)
 
-	parser := NewParser(input)
+	parser := ahm.NewParser(input)
 
 	// when
 	node, err := parser.Parse()
@@ -50,21 +55,21 @@ func TestMultipleLinesOfTextAreRead(t *testing.T) {
 	// then
 	assert.That(errors.Cause(err) == io.EOF, t.Fatalf, "got error %q, expected %q", err, io.EOF)
 	assert.That(node != nil, t.Fatalf, "the node returned must not be nil")
-	reportDiffs(t.Fatalf, node, wantNode)
+	ReportNodeDiffs(t.Fatalf, node, wantNode)
 }
 
 func TestTextReadingStopsAtALinePrefixedWithAnAtSign(t *testing.T) {
 	// given
-	wantNode := &Text{"Some lines\nof text."}
+	wantNode := T("Some lines\nof text.")
 
-	rawInput := multiline(
+	rawInput := Lines(
 		"Some lines",
 		"of text.",
 		"@A-PROC")
 
 	input := strings.NewReader(rawInput)
 
-	parser := NewParser(input)
+	parser := ahm.NewParser(input)
 
 	// when
 	node, err := parser.Parse()
@@ -72,18 +77,18 @@ func TestTextReadingStopsAtALinePrefixedWithAnAtSign(t *testing.T) {
 	// then
 	assert.That(node != nil, t.Fatalf, "the node returned must not be nil")
 	assert.That(err == nil, t.Fatalf, "got error %q, expected none", err)
-	reportDiffs(t.Fatalf, node, wantNode)
+	ReportNodeDiffs(t.Fatalf, node, wantNode)
 }
 
 func TestNameOnlyProcIsRead(t *testing.T) {
 	// given
-	wantNode := &Proc{Name: "A-PROC"}
+	wantNode := P("A-PROC", "")
 
 	rawInput := "@A-PROC"
 
 	input := strings.NewReader(rawInput)
 
-	parser := NewParser(input)
+	parser := ahm.NewParser(input)
 
 	// when
 	node, err := parser.Parse()
@@ -91,18 +96,18 @@ func TestNameOnlyProcIsRead(t *testing.T) {
 	// then
 	assert.That(node != nil, t.Fatalf, "the node returned must not be nil")
 	assert.That(errors.Cause(err) == io.EOF, t.Fatalf, "got error %q, wanted %q", err, io.EOF)
-	reportDiffs(t.Fatalf, node, wantNode)
+	ReportNodeDiffs(t.Fatalf, node, wantNode)
 }
 
 func TestSpacesAreNotIncludedInReadProcName(t *testing.T) {
 	// given
-	wantNode := &Proc{Name: "A-PROC"}
+	wantNode := P("A-PROC", "")
 
 	rawInput := "@A-PROC "
 
 	input := strings.NewReader(rawInput)
 
-	parser := NewParser(input)
+	parser := ahm.NewParser(input)
 
 	// when
 	node, err := parser.Parse()
@@ -110,18 +115,18 @@ func TestSpacesAreNotIncludedInReadProcName(t *testing.T) {
 	// then
 	assert.That(node != nil, t.Fatalf, "the node returned must not be nil")
 	assert.That(errors.Cause(err) == io.EOF, t.Fatalf, "got error %q, wanted %q", err, io.EOF)
-	reportDiffs(t.Fatalf, node, wantNode)
+	ReportNodeDiffs(t.Fatalf, node, wantNode)
 }
 
 func TestProcWithNameAndTitleIsRead(t *testing.T) {
 	// given
-	wantNode := &Proc{Name: "A-PROC", Title: "TITLE"}
+	wantNode := P("A-PROC", "TITLE")
 
 	rawInput := "@A-PROC TITLE"
 
 	input := strings.NewReader(rawInput)
 
-	parser := NewParser(input)
+	parser := ahm.NewParser(input)
 
 	// when
 	node, err := parser.Parse()
@@ -129,19 +134,19 @@ func TestProcWithNameAndTitleIsRead(t *testing.T) {
 	// then
 	assert.That(node != nil, t.Fatalf, "the node returned must not be nil")
 	assert.That(errors.Cause(err) == io.EOF, t.Fatalf, "got error %q, wanted %q", err, io.EOF)
-	reportDiffs(t.Fatalf, node, wantNode)
+	ReportNodeDiffs(t.Fatalf, node, wantNode)
 }
 
 func TestProcReadingStopsAtNextNonindentedLine(t *testing.T) {
 	// given
-	wantNode := &Proc{Name: "A-PROC", Title: "TITLE"}
-	rawInput := multiline(
+	wantNode := P("A-PROC", "TITLE")
+	rawInput := Lines(
 		"@A-PROC TITLE",
 		"Some text.")
 
 	input := strings.NewReader(rawInput)
 
-	parser := NewParser(input)
+	parser := ahm.NewParser(input)
 
 	// when
 	node, err := parser.Parse()
@@ -149,26 +154,20 @@ func TestProcReadingStopsAtNextNonindentedLine(t *testing.T) {
 	// then
 	assert.That(node != nil, t.Fatalf, "the node returned must not be nil")
 	assert.That(err == nil, t.Fatalf, "got error %q, wanted none", err)
-	reportDiffs(t.Fatalf, node, wantNode)
+	ReportNodeDiffs(t.Fatalf, node, wantNode)
 }
 
 func TestProcReadIncludesIndentedTextAsChild(t *testing.T) {
 	// given
-	wantNode := &Proc{
-		Name:  "A-PROC",
-		Title: "TITLE",
-		Children: []Node{
-			&Text{"Some text."},
-		},
-	}
+	wantNode := P("A-PROC", "TITLE", T("Some text."))
 
-	rawInput := multiline(
+	rawInput := Lines(
 		"@A-PROC TITLE",
 		"  Some text.")
 
 	input := strings.NewReader(rawInput)
 
-	parser := NewParser(input)
+	parser := ahm.NewParser(input)
 
 	// when
 	node, err := parser.Parse()
@@ -176,27 +175,21 @@ func TestProcReadIncludesIndentedTextAsChild(t *testing.T) {
 	// then
 	assert.That(node != nil, t.Fatalf, "the node returned must not be nil")
 	assert.That(errors.Cause(err) == io.EOF, t.Fatalf, "got error %q, wanted %q", err, io.EOF)
-	reportDiffs(t.Fatalf, node, wantNode)
+	ReportNodeDiffs(t.Fatalf, node, wantNode)
 }
 
 func TestIndentedTextChildCanSpanMultipleLines(t *testing.T) {
 	// given
-	wantNode := &Proc{
-		Name:  "A-PROC",
-		Title: "TITLE",
-		Children: []Node{
-			&Text{"Some lines\nof text."},
-		},
-	}
+	wantNode := P("A-PROC", "TITLE", T("Some lines\nof text."))
 
-	rawInput := multiline(
+	rawInput := Lines(
 		"@A-PROC TITLE",
 		"  Some lines",
 		"  of text.")
 
 	input := strings.NewReader(rawInput)
 
-	parser := NewParser(input)
+	parser := ahm.NewParser(input)
 
 	// when
 	node, err := parser.Parse()
@@ -204,27 +197,21 @@ func TestIndentedTextChildCanSpanMultipleLines(t *testing.T) {
 	// then
 	assert.That(node != nil, t.Fatalf, "the node returned must not be nil")
 	assert.That(errors.Cause(err) == io.EOF, t.Fatalf, "got error %q, wanted %q", err, io.EOF)
-	reportDiffs(t.Fatalf, node, wantNode)
+	ReportNodeDiffs(t.Fatalf, node, wantNode)
 }
 
 func TestAProcCanHaveMultipleIndentedChildren(t *testing.T) {
 	// given
-	wantNode := &Proc{
-		Name: "A-PARENT",
-		Children: []Node{
-			&Text{"Some text."},
-			&Proc{Name: "A-CHILD"},
-		},
-	}
+	wantNode := P("A-PARENT", "", T("Some text."), P("A-CHILD", ""))
 
-	rawInput := multiline(
+	rawInput := Lines(
 		"@A-PARENT",
 		"  Some text.",
 		"  @A-CHILD")
 
 	input := strings.NewReader(rawInput)
 
-	parser := NewParser(input)
+	parser := ahm.NewParser(input)
 
 	// when
 	node, err := parser.Parse()
@@ -232,31 +219,23 @@ func TestAProcCanHaveMultipleIndentedChildren(t *testing.T) {
 	// then
 	assert.That(node != nil, t.Fatalf, "the node returned must not be nil")
 	assert.That(errors.Cause(err) == io.EOF, t.Fatalf, "got error %q, wanted %q", err, io.EOF)
-	reportDiffs(t.Fatalf, node, wantNode)
+	ReportNodeDiffs(t.Fatalf, node, wantNode)
 }
 
 func TestNodesCanBeNestedBeyondOneLevel(t *testing.T) {
 	// given
-	wantNode := &Proc{
-		Name: "A-GRANDPARENT",
-		Children: []Node{
-			&Proc{
-				Name: "A-PARENT",
-				Children: []Node{
-					&Proc{Name: "A-CHILD"},
-				},
-			},
-		},
-	}
+	wantNode := P("A-GRANDPARENT", "",
+		P("A-PARENT", "",
+			P("A-CHILD", "")))
 
-	rawInput := multiline(
+	rawInput := Lines(
 		"@A-GRANDPARENT",
 		"  @A-PARENT",
 		"    @A-CHILD")
 
 	input := strings.NewReader(rawInput)
 
-	parser := NewParser(input)
+	parser := ahm.NewParser(input)
 
 	// when
 	node, err := parser.Parse()
@@ -264,25 +243,17 @@ func TestNodesCanBeNestedBeyondOneLevel(t *testing.T) {
 	// then
 	assert.That(node != nil, t.Fatalf, "the node returned must not be nil")
 	assert.That(errors.Cause(err) == io.EOF, t.Fatalf, "got error %q, wanted %q", err, io.EOF)
-	reportDiffs(t.Fatalf, node, wantNode)
+	ReportNodeDiffs(t.Fatalf, node, wantNode)
 }
 
 func TestDedentsArePossibleWithinAValidNode(t *testing.T) {
 	// given
-	wantNode := &Proc{
-		Name: "A-GRANDPARENT",
-		Children: []Node{
-			&Proc{
-				Name: "A-PARENT",
-				Children: []Node{
-					&Proc{Name: "A-CHILD"},
-				},
-			},
-			&Proc{Name: "A-PARENT-SIBLING"},
-		},
-	}
+	wantNode := P("A-GRANDPARENT", "",
+		P("A-PARENT", "",
+			P("A-CHILD", "")),
+		P("A-PARENT-SIBLING", ""))
 
-	rawInput := multiline(
+	rawInput := Lines(
 		"@A-GRANDPARENT",
 		"  @A-PARENT",
 		"    @A-CHILD",
@@ -290,7 +261,7 @@ func TestDedentsArePossibleWithinAValidNode(t *testing.T) {
 
 	input := strings.NewReader(rawInput)
 
-	parser := NewParser(input)
+	parser := ahm.NewParser(input)
 
 	// when
 	node, err := parser.Parse()
@@ -298,25 +269,20 @@ func TestDedentsArePossibleWithinAValidNode(t *testing.T) {
 	// then
 	assert.That(node != nil, t.Fatalf, "the node returned must not be nil")
 	assert.That(errors.Cause(err) == io.EOF, t.Fatalf, "got error %q, wanted %q", err, io.EOF)
-	reportDiffs(t.Fatalf, node, wantNode)
+	ReportNodeDiffs(t.Fatalf, node, wantNode)
 }
 
 func TestNestedTextCanBeFollowedByDedentedText(t *testing.T) {
 	// given
-	wantNode := &Proc{
-		Name: "PARENT",
-		Children: []Node{
-			&Text{"A"},
-		},
-	}
+	wantNode := P("PARENT", "", T("A"))
 
-	rawInput := multiline(
+	rawInput := Lines(
 		"@PARENT",
 		"  A",
 		"")
 	input := strings.NewReader(rawInput)
 
-	parser := NewParser(input)
+	parser := ahm.NewParser(input)
 
 	// when
 	node, err := parser.Parse()
@@ -324,28 +290,21 @@ func TestNestedTextCanBeFollowedByDedentedText(t *testing.T) {
 	// then
 	assert.That(node != nil, t.Fatalf, "the node returned must not be nil")
 	assert.That(err == nil, t.Fatalf, "unexpected error: %s", err, io.EOF)
-	reportDiffs(t.Fatalf, node, wantNode)
+	ReportNodeDiffs(t.Fatalf, node, wantNode)
 }
 
 func TestNestedTextCanHaveUnderindentedEmptyLines(t *testing.T) {
 	// given
-	wantNode := &Proc{
-		Name: "PARENT",
-		Children: []Node{
-			&Text{
-				multiline("A", "", "child"),
-			},
-		},
-	}
+	wantNode := P("PARENT", "", T(Lines("A", "", "child")))
 
-	rawInput := multiline(
+	rawInput := Lines(
 		"@PARENT",
 		"  A",
 		"",
 		"  child")
 	input := strings.NewReader(rawInput)
 
-	parser := NewParser(input)
+	parser := ahm.NewParser(input)
 
 	// when
 	node, err := parser.Parse()
@@ -353,28 +312,309 @@ func TestNestedTextCanHaveUnderindentedEmptyLines(t *testing.T) {
 	// then
 	assert.That(node != nil, t.Fatalf, "the node returned must not be nil")
 	assert.That(errors.Cause(err) == io.EOF, t.Fatalf, "got error %q, wanted %q", err, io.EOF)
-	reportDiffs(t.Fatalf, node, wantNode)
+	ReportNodeDiffs(t.Fatalf, node, wantNode)
+}
+
+func TestTextWithAnInlineProcIsReadAsAParagraph(t *testing.T) {
+	// given
+	wantNode := Para(T("Energy is "), P("MATH", "E = m c^2"), T(",\nas you know."))
+
+	rawInput := Lines(
+		"Energy is @MATH{E = m c^2},",
+		"as you know.")
+
+	input := strings.NewReader(rawInput)
+
+	parser := ahm.NewParser(input)
+
+	// when
+	node, err := parser.Parse()
+
+	// then
+	assert.That(errors.Cause(err) == io.EOF, t.Fatalf, "got error %q, wanted %q", err, io.EOF)
+	assert.That(node != nil, t.Fatalf, "the node returned must not be nil")
+	ReportNodeDiffs(t.Fatalf, node, wantNode)
+}
+
+func TestAParagraphCanStartWithAnInlineProc(t *testing.T) {
+	// given
+	wantNode := P("PARENT", "", Para(T("Some\n"), P("EM", "text"), T(" here.")))
+
+	rawInput := Lines(
+		"@PARENT",
+		"  Some",
+		"  @EM{text} here.")
+
+	input := strings.NewReader(rawInput)
+
+	parser := ahm.NewParser(input)
+
+	// when
+	node, err := parser.Parse()
+
+	// then
+	assert.That(errors.Cause(err) == io.EOF, t.Fatalf, "got error %q, wanted %q", err, io.EOF)
+	assert.That(node != nil, t.Fatalf, "the node returned must not be nil")
+	ReportNodeDiffs(t.Fatalf, node, wantNode)
+}
+
+func TestUnterminatedInlineProcIsAnError(t *testing.T) {
+	// given
+	rawInput := "Energy is @MATH{E = m c^2"
+
+	input := strings.NewReader(rawInput)
+
+	parser := ahm.NewParser(input)
+
+	// when
+	_, err := parser.Parse()
+
+	// then
+	diag, ok := err.(*ahmerr.Diagnostic)
+	assert.That(ok, t.Fatalf, "got error %#v, wanted a diagnostic", err)
+	want := position.NewFile("", []byte(rawInput)).PositionOf(1, 11)
+	assert.That(diag.Span.StartsAt() == want, t.Errorf, "got diagnostic at %s, wanted it at the inline proc", diag.Span)
+}
+
+func TestUnexpectedTokensAreReportedWhereTheyAre(t *testing.T) {
+	// given
+	rawInput := "Energy is @MATH{E = m c^2\nmore}"
+
+	input := strings.NewReader(rawInput)
+
+	parser := ahm.Config{Source: "doc.ahm"}.NewParser(input)
+
+	// when
+	_, err := parser.Parse()
+
+	// then
+	diag, ok := err.(*ahmerr.Diagnostic)
+	assert.That(ok, t.Fatalf, "got error %#v, wanted a diagnostic", err)
+	assert.That(diag.Span.Source == "doc.ahm", t.Errorf, "got diagnostic in %q, wanted it in %q", diag.Span.Source, "doc.ahm")
+	assert.That(diag.Span.StartsAt().Line() == 1, t.Errorf, "got diagnostic at %s, wanted it on the first line", diag.Span)
+}
+
+func TestReadErrorsAreReportedWhereReadingStopped(t *testing.T) {
+	// given
+	readErr := errors.New("disk on fire")
+	input := io.MultiReader(strings.NewReader("@PARENT\n  text"), iotest.ErrReader(readErr))
+
+	parser := ahm.Config{Source: "doc.ahm"}.NewParser(input)
+
+	// when
+	_, err := parser.ParseAll()
+
+	// then
+	diag, ok := err.(*ahmerr.Diagnostic)
+	assert.That(ok, t.Fatalf, "got error %#v, wanted a diagnostic", err)
+	assert.That(diag.Span.Source == "doc.ahm", t.Errorf, "got diagnostic in %q, wanted it in %q", diag.Span.Source, "doc.ahm")
+	assert.That(strings.Contains(diag.Message, readErr.Error()), t.Errorf, "got message %q, wanted it to mention %q", diag.Message, readErr)
+}
+
+func TestParseAllKeepsTheLastNode(t *testing.T) {
+	// given
+	wantNodes := []ahm.Node{
+		P("FIRST", ""),
+		P("LAST", "title"),
+	}
+
+	rawInput := Lines(
+		"@FIRST",
+		"@LAST title")
+
+	input := strings.NewReader(rawInput)
+
+	parser := ahm.NewParser(input)
+
+	// when
+	nodes, err := parser.ParseAll()
+
+	// then
+	assert.That(err == nil, t.Fatalf, "unexpected error: %s", err)
+	ReportDiffs(t.Fatalf, nodes, wantNodes)
+}
+
+func TestParseAllIgnoresTheFinalNewline(t *testing.T) {
+	// given
+	wantNodes := []ahm.Node{
+		P("LAST", "title"),
+	}
+
+	rawInput := Lines(
+		"@LAST title",
+		"")
+
+	input := strings.NewReader(rawInput)
+
+	parser := ahm.NewParser(input)
+
+	// when
+	nodes, err := parser.ParseAll()
+
+	// then
+	assert.That(err == nil, t.Fatalf, "unexpected error: %s", err)
+	ReportDiffs(t.Fatalf, nodes, wantNodes)
 }
 
-func multiline(lines ...string) string {
-	var buf bytes.Buffer
-	last := len(lines) - 1
-	for i, line := range lines {
-		buf.WriteString(line)
-		if i != last {
-			buf.WriteRune('\n')
-		}
+func TestDocumentsOfOnlyNewlinesHaveNoNodes(t *testing.T) {
+	for _, rawInput := range []string{"\n", "\n\n", "\n\n\n"} {
+		// given
+		parser := ahm.NewParser(strings.NewReader(rawInput))
+
+		// when
+		nodes, err := parser.ParseAll()
+
+		// then
+		assert.That(err == nil, t.Fatalf, "unexpected error: %s", err)
+		assert.That(len(nodes) == 0, t.Errorf, "got nodes %v for %q, wanted none", nodes, rawInput)
 	}
-	return buf.String()
 }
 
-func reportDiffs(onErr func(string, ...interface{}), got, want interface{}) {
-	diffs := pretty.Diff(got, want)
-	for _, diff := range diffs {
-		onErr(diff)
+func TestTextDoesNotIncludeTheFinalNewline(t *testing.T) {
+	// given
+	wantNodes := []ahm.Node{
+		Para(T("Some "), P("EM", "text"), T(".")),
 	}
-	if len(diffs) > 0 {
-		onErr("got: %# v", pretty.Formatter(got))
-		onErr("wanted: is %# v", pretty.Formatter(want))
+
+	rawInput := Lines(
+		"Some @EM{text}.",
+		"")
+
+	input := strings.NewReader(rawInput)
+
+	parser := ahm.NewParser(input)
+
+	// when
+	nodes, err := parser.ParseAll()
+
+	// then
+	assert.That(err == nil, t.Fatalf, "unexpected error: %s", err)
+	ReportDiffs(t.Fatalf, nodes, wantNodes)
+}
+
+func TestParseAllReadsTextFollowedByAProc(t *testing.T) {
+	// given
+	wantNodes := []ahm.Node{
+		T("Some text."),
+		P("A-PROC", ""),
+	}
+
+	rawInput := Lines(
+		"Some text.",
+		"@A-PROC")
+
+	input := strings.NewReader(rawInput)
+
+	parser := ahm.NewParser(input)
+
+	// when
+	nodes, err := parser.ParseAll()
+
+	// then
+	assert.That(err == nil, t.Fatalf, "unexpected error: %s", err)
+	ReportDiffs(t.Fatalf, nodes, wantNodes)
+}
+
+func TestTextKeepsDeeperIndentation(t *testing.T) {
+	// given
+	wantNode := P("CODE", "", T(Lines(
+		"func main() {",
+		"  if true {",
+		"    run()",
+		"  }",
+		"}")))
+
+	rawInput := Lines(
+		"@CODE",
+		"  func main() {",
+		"    if true {",
+		"      run()",
+		"    }",
+		"  }")
+
+	input := strings.NewReader(rawInput)
+
+	parser := ahm.NewParser(input)
+
+	// when
+	node, err := parser.Parse()
+
+	// then
+	assert.That(errors.Cause(err) == io.EOF, t.Fatalf, "got error %q, wanted %q", err, io.EOF)
+	ReportNodeDiffs(t.Fatalf, node, wantNode)
+}
+
+func TestBlankLinesCanSeparateProcs(t *testing.T) {
+	// given
+	wantNodes := []ahm.Node{
+		P("EPISODE", "", P("CONDITIONS", ""), P("CARD", "")),
+		P("EPILOGUE", ""),
+	}
+
+	rawInput := Lines(
+		"@EPISODE",
+		"  ",
+		"  @CONDITIONS",
+		"",
+		"  @CARD",
+		"",
+		"@EPILOGUE")
+
+	input := strings.NewReader(rawInput)
+
+	parser := ahm.NewParser(input)
+
+	// when
+	nodes, err := parser.ParseAll()
+
+	// then
+	assert.That(err == nil, t.Fatalf, "unexpected error: %s", err)
+	ReportDiffs(t.Fatalf, nodes, wantNodes)
+}
+
+func TestNodeSpansCoverTheirChildren(t *testing.T) {
+	// given
+	rawInput := Lines(
+		"@PARENT title",
+		"  some",
+		"  child",
+		"aunt")
+
+	file := position.NewFile("doc.ahm", []byte(rawInput))
+	wantProcSpan := position.SpanFromTo(file.PositionOf(1, 1), file.PositionOf(3, 8)).In("doc.ahm")
+	wantTextSpan := position.SpanFromTo(file.PositionOf(2, 3), file.PositionOf(3, 8)).In("doc.ahm")
+
+	input := strings.NewReader(rawInput)
+
+	parser := ahm.Config{Source: "doc.ahm"}.NewParser(input)
+
+	// when
+	node, err := parser.Parse()
+
+	// then
+	assert.That(err == nil, t.Fatalf, "unexpected error: %s", err)
+	proc := node.(*ahm.Proc)
+	assert.That(proc.Span == wantProcSpan, t.Errorf, "got proc span %s, wanted %s", proc.Span, wantProcSpan)
+	assert.That(len(proc.Children) == 1, t.Fatalf, "got %d children, wanted 1", len(proc.Children))
+	text := proc.Children[0].(*ahm.Text)
+	assert.That(text.Span == wantTextSpan, t.Errorf, "got text span %s, wanted %s", text.Span, wantTextSpan)
+}
+
+func TestExamplesParseIntoTheirGoldenTrees(t *testing.T) {
+	paths, _ := filepath.Glob(filepath.Join("examples", "*.ahm"))
+	for _, path := range paths {
+		t.Run(path, func(t *testing.T) {
+			// given
+			src, err := os.ReadFile(path)
+			assert.That(err == nil, t.Fatalf, "cannot read example: %s", err)
+
+			// when
+			nodes, err := ahm.NewParser(strings.NewReader(string(src))).ParseAll()
+
+			// then
+			assert.That(err == nil, t.Fatalf, "unexpected error: %s", err)
+			golden := filepath.Join("testdata", "golden", strings.TrimSuffix(filepath.Base(path), ".ahm")+".tree")
+			GoldenTree(t, golden, nodes)
+		})
 	}
 }
@@ -0,0 +1,84 @@
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package position
+
+import "sort"
+
+// Spans are half open: they contain their start, but not their end.
+
+func (span Span) IsEmpty() bool { return span.start.Compare(span.end) == 0 }
+
+// Contains checks whether a position is within a span.
+func (span Span) Contains(pos Position) bool {
+	return !pos.Before(span.start) && pos.Before(span.end)
+}
+
+// Touches checks whether a position is within a span or just past its end, like a cursor after the last character.
+func (span Span) Touches(pos Position) bool {
+	return !pos.Before(span.start) && !pos.After(span.end)
+}
+
+// Encloses checks whether a span lies entirely within this one.
+func (span Span) Encloses(other Span) bool {
+	return !other.start.Before(span.start) && !other.end.After(span.end)
+}
+
+// Overlaps checks whether two spans share a position.
+func (span Span) Overlaps(other Span) bool {
+	return span.start.Before(other.end) && other.start.Before(span.end)
+}
+
+// Intersect is the part two spans share, if they overlap.
+func (span Span) Intersect(other Span) (Span, bool) {
+	if !span.Overlaps(other) {
+		return Span{}, false
+	}
+	return Span{start: later(span.start, other.start), end: earlier(span.end, other.end)}, true
+}
+
+// Union is the smallest span enclosing both spans.
+func (span Span) Union(other Span) Span {
+	return Span{start: earlier(span.start, other.start), end: later(span.end, other.end)}
+}
+
+// Compare orders spans by where they start.
+// Of two spans starting at the same place, the longer one comes first, so that a span comes before those it encloses.
+func (span Span) Compare(other Span) int {
+	if c := span.start.Compare(other.start); c != 0 {
+		return c
+	}
+	return other.end.Compare(span.end)
+}
+
+// Sort orders spans as Compare does.
+func Sort(spans []Span) {
+	sort.SliceStable(spans, func(i, j int) bool { return spans[i].Compare(spans[j]) < 0 })
+}
+
+// Compare orders spans by source and then as Span.Compare does.
+func (spanIn SpanIn) Compare(other SpanIn) int {
+	switch {
+	case spanIn.Source < other.Source:
+		return -1
+	case spanIn.Source > other.Source:
+		return 1
+	default:
+		return spanIn.Span.Compare(other.Span)
+	}
+}
+
+func earlier(left, right Position) Position {
+	if right.Before(left) {
+		return right
+	}
+	return left
+}
+
+func later(left, right Position) Position {
+	if right.After(left) {
+		return right
+	}
+	return left
+}
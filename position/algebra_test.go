@@ -0,0 +1,64 @@
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package position_test
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/szabba/ahm/assert"
+	"github.com/szabba/ahm/position"
+)
+
+func TestSpansContainTheirStartButNotTheirEnd(t *testing.T) {
+	// given
+	span := spanOf(1, 3, 2, 5)
+
+	// then
+	assert.That(span.Contains(position.PositionOf(1, 3)), t.Errorf, "the span should contain its start")
+	assert.That(span.Contains(position.PositionOf(1, 80)), t.Errorf, "the span should contain the rest of its first line")
+	assert.That(!span.Contains(position.PositionOf(2, 5)), t.Errorf, "the span should not contain its end")
+	assert.That(span.Touches(position.PositionOf(2, 5)), t.Errorf, "the span should touch its end")
+	assert.That(!span.Contains(position.PositionOf(1, 2)), t.Errorf, "the span should not contain what comes before it")
+}
+
+func TestSpanOverlapAndIntersection(t *testing.T) {
+	// given
+	left, right, after := spanOf(1, 1, 2, 4), spanOf(2, 1, 3, 1), spanOf(3, 1, 3, 9)
+
+	// when
+	both, ok := left.Intersect(right)
+	_, touching := right.Intersect(after)
+
+	// then
+	assert.That(ok && both == spanOf(2, 1, 2, 4), t.Errorf, "got intersection %s", both)
+	assert.That(!touching && !right.Overlaps(after), t.Errorf, "spans that only touch should not overlap")
+	assert.That(left.Union(after) == spanOf(1, 1, 3, 9), t.Errorf, "got union %s", left.Union(after))
+	assert.That(left.Union(after).Encloses(right), t.Errorf, "the union should enclose the span between")
+}
+
+func TestSortingPutsEnclosingSpansFirst(t *testing.T) {
+	// given
+	spans := []position.Span{spanOf(2, 1, 2, 3), spanOf(1, 1, 1, 2), spanOf(1, 1, 3, 1), spanOf(1, 5, 1, 6)}
+
+	// when
+	position.Sort(spans)
+
+	// then
+	want := []position.Span{spanOf(1, 1, 3, 1), spanOf(1, 1, 1, 2), spanOf(1, 5, 1, 6), spanOf(2, 1, 2, 3)}
+	assert.That(fmt.Sprint(spans) == fmt.Sprint(want), t.Errorf, "got %v, wanted %v", spans, want)
+}
+
+func TestSpansInDifferentSourcesAreOrderedBySource(t *testing.T) {
+	// given
+	early, late := spanOf(9, 1, 9, 2).In("a.ahm"), spanOf(1, 1, 1, 2).In("b.ahm")
+
+	// then
+	assert.That(early.Compare(late) < 0, t.Errorf, "a span in a.ahm should come before one in b.ahm")
+}
+
+func spanOf(startLine, startCol, endLine, endCol int) position.Span {
+	return position.SpanFromTo(position.PositionOf(startLine, startCol), position.PositionOf(endLine, endCol))
+}
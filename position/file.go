@@ -0,0 +1,259 @@
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package position
+
+import (
+	"sort"
+	"unicode"
+	"unicode/utf8"
+)
+
+// A File indexes the lines of a source, to convert between the ways of saying where something is in it.
+//
+// Lines and all kinds of columns count from 1.
+// Conversions clamp positions past the end of a line to its end, and those past the last line to the end of the source.
+type File struct {
+	name  string
+	base  int
+	src   []byte
+	lines []int
+}
+
+func NewFile(name string, src []byte) *File {
+	f := &File{name: name, src: src, lines: []int{0}}
+	for i, b := range src {
+		if b == '\n' {
+			f.lines = append(f.lines, i+1)
+		}
+	}
+	return f
+}
+
+func (f *File) Name() string { return f.name }
+func (f *File) Size() int    { return len(f.src) }
+
+// Source returns the bytes the file was created from.
+func (f *File) Source() []byte { return f.src }
+
+func (f *File) LineCount() int { return len(f.lines) }
+
+// LineStart is the byte offset a line starts at.
+func (f *File) LineStart(line int) int {
+	switch {
+	case line < 1:
+		return 0
+	case line > len(f.lines):
+		return len(f.src)
+	default:
+		return f.lines[line-1]
+	}
+}
+
+// lineEnd is the byte offset of the newline ending a line, or the end of the source.
+func (f *File) lineEnd(line int) int {
+	if line < len(f.lines) {
+		return f.lines[line] - 1
+	}
+	return len(f.src)
+}
+
+// Position locates a byte offset.
+// An offset within a multi-byte rune is moved back to the rune's start.
+func (f *File) Position(offset int) Position {
+	offset = clamp(offset, 0, len(f.src))
+	line := sort.Search(len(f.lines), func(i int) bool { return f.lines[i] > offset })
+	pos := PositionAt(line, 1, f.lines[line-1])
+	for pos.offset < offset {
+		r, size := utf8.DecodeRune(f.src[pos.offset:])
+		if pos.offset+size > offset {
+			break
+		}
+		pos = pos.Advance(r, size)
+	}
+	return pos
+}
+
+// PositionOf locates a rune column on a line.
+func (f *File) PositionOf(line, column int) Position {
+	return f.advance(line, func(pos Position, _ rune) bool { return pos.Column() < column })
+}
+
+// PositionOfUTF16 locates a column counted in UTF-16 code units, as editors speaking LSP do.
+func (f *File) PositionOfUTF16(line, column int) Position {
+	col := 1
+	return f.advance(line, func(_ Position, r rune) bool {
+		if col >= column {
+			return false
+		}
+		col += utf16Len(r)
+		return true
+	})
+}
+
+// PositionOfDisplay locates a column as shown on a terminal, with tabs stopping every tabWidth columns.
+func (f *File) PositionOfDisplay(line, column, tabWidth int) Position {
+	col := 1
+	return f.advance(line, func(_ Position, r rune) bool {
+		next := nextDisplayColumn(col, r, tabWidth)
+		if next > column {
+			return false
+		}
+		col = next
+		return true
+	})
+}
+
+// advance walks the runes of a line for as long as more says so.
+func (f *File) advance(line int, more func(Position, rune) bool) Position {
+	if line < 1 {
+		line = 1
+	}
+	if line > len(f.lines) {
+		return f.Position(len(f.src))
+	}
+	pos := PositionAt(line, 1, f.lines[line-1])
+	end := f.lineEnd(line)
+	for pos.offset < end {
+		r, size := utf8.DecodeRune(f.src[pos.offset:])
+		if !more(pos, r) {
+			break
+		}
+		pos = pos.Advance(r, size)
+	}
+	return pos
+}
+
+// Offset is the byte offset of a rune column on a line.
+func (f *File) Offset(line, column int) int { return f.PositionOf(line, column).offset }
+
+// UTF16Column is the column of a position counted in UTF-16 code units.
+func (f *File) UTF16Column(pos Position) int {
+	col := 1
+	f.walkTo(pos, func(r rune) { col += utf16Len(r) })
+	return col
+}
+
+// DisplayColumn is the column of a position as shown on a terminal.
+//
+// Tabs stop every tabWidth columns, wide East Asian characters and emoji take two columns and combining marks none.
+func (f *File) DisplayColumn(pos Position, tabWidth int) int {
+	col := 1
+	f.walkTo(pos, func(r rune) { col = nextDisplayColumn(col, r, tabWidth) })
+	return col
+}
+
+// walkTo visits the runes on the line of a position that come before it.
+func (f *File) walkTo(pos Position, visit func(rune)) {
+	start := f.LineStart(pos.Line())
+	end := clamp(pos.offset, start, f.lineEnd(pos.Line()))
+	for offset := start; offset < end; {
+		r, size := utf8.DecodeRune(f.src[offset:])
+		visit(r)
+		offset += size
+	}
+}
+
+// Slice returns the bytes of the source within a span.
+func (f *File) Slice(span Span) []byte {
+	start, end := span.Offsets()
+	return f.src[clamp(start, 0, len(f.src)):clamp(end, 0, len(f.src))]
+}
+
+// Pos is the FileSet-wide position of an offset in the file.
+func (f *File) Pos(offset int) Pos { return Pos(f.base + clamp(offset, 0, len(f.src))) }
+
+// A Pos is a compact position in a FileSet.
+// The zero Pos is in none of the files.
+type Pos int
+
+// A FileSet gives the bytes of a number of files distinct Pos values.
+type FileSet struct {
+	files []*File
+	next  int
+}
+
+func NewFileSet() *FileSet { return &FileSet{next: 1} }
+
+// AddFile indexes a source, giving its bytes the Pos values following those of the file added before it.
+func (set *FileSet) AddFile(name string, src []byte) *File {
+	f := NewFile(name, src)
+	f.base = set.next
+	set.next += len(src) + 1
+	set.files = append(set.files, f)
+	return f
+}
+
+// Lookup finds the file with a name.
+func (set *FileSet) Lookup(name string) (*File, bool) {
+	for _, f := range set.files {
+		if f.name == name {
+			return f, true
+		}
+	}
+	return nil, false
+}
+
+// File finds the file a Pos is in.
+func (set *FileSet) File(p Pos) (*File, bool) {
+	i := sort.Search(len(set.files), func(i int) bool { return set.files[i].base > int(p) }) - 1
+	if i < 0 || int(p) > set.files[i].base+len(set.files[i].src) {
+		return nil, false
+	}
+	return set.files[i], true
+}
+
+// Position locates a Pos in its file.
+func (set *FileSet) Position(p Pos) (PositionIn, bool) {
+	f, ok := set.File(p)
+	if !ok {
+		return PositionIn{}, false
+	}
+	return f.Position(int(p) - f.base).In(f.name), true
+}
+
+func clamp(n, min, max int) int {
+	if n < min {
+		return min
+	}
+	if n > max {
+		return max
+	}
+	return n
+}
+
+func utf16Len(r rune) int {
+	if r >= 0x10000 {
+		return 2
+	}
+	return 1
+}
+
+func nextDisplayColumn(col int, r rune, tabWidth int) int {
+	switch {
+	case r == '\t' && tabWidth > 0:
+		return col + tabWidth - (col-1)%tabWidth
+	case unicode.In(r, unicode.Mn, unicode.Me) || r == '\u200B':
+		return col
+	case isWide(r):
+		return col + 2
+	default:
+		return col + 1
+	}
+}
+
+// wide lists the ranges of East Asian wide and full width characters, and of emoji shown as wide.
+var wide = [][2]rune{
+	{0x1100, 0x115F}, {0x231A, 0x231B}, {0x2329, 0x232A}, {0x23E9, 0x23EC},
+	{0x25FD, 0x25FE}, {0x2614, 0x2615}, {0x2E80, 0x303E}, {0x3041, 0x33FF},
+	{0x3400, 0x4DBF}, {0x4E00, 0x9FFF}, {0xA000, 0xA4CF}, {0xA960, 0xA97F},
+	{0xAC00, 0xD7A3}, {0xF900, 0xFAFF}, {0xFE10, 0xFE19}, {0xFE30, 0xFE6F},
+	{0xFF00, 0xFF60}, {0xFFE0, 0xFFE6}, {0x1F300, 0x1F64F}, {0x1F900, 0x1F9FF},
+	{0x20000, 0x2FFFD}, {0x30000, 0x3FFFD},
+}
+
+func isWide(r rune) bool {
+	i := sort.Search(len(wide), func(i int) bool { return wide[i][1] >= r })
+	return i < len(wide) && wide[i][0] <= r
+}
@@ -0,0 +1,97 @@
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package position_test
+
+import (
+	"testing"
+
+	"github.com/szabba/ahm/assert"
+	"github.com/szabba/ahm/position"
+)
+
+const src = "@TITLE Zażółć\n\tgęślą 🙂 jaźń\n日本語 text\n"
+
+func TestFilePositionOfAnOffset(t *testing.T) {
+	// given
+	f := position.NewFile("doc.ahm", []byte(src))
+
+	// when
+	pos := f.Position(len("@TITLE Zażółć\n\tgęś"))
+
+	// then
+	assert.That(pos.Line() == 2 && pos.Column() == 5, t.Errorf, "got %s, wanted 2,5", pos)
+	assert.That(pos.Offset() == len("@TITLE Zażółć\n\tgęś"), t.Errorf, "got offset %d", pos.Offset())
+}
+
+func TestFilePositionWithinARuneMovesToItsStart(t *testing.T) {
+	// given
+	f := position.NewFile("doc.ahm", []byte(src))
+
+	// when
+	pos := f.Position(len("@TITLE Za") + 1)
+
+	// then
+	assert.That(pos.Column() == 10 && pos.Offset() == len("@TITLE Za"), t.Errorf, "got %s at offset %d", pos, pos.Offset())
+}
+
+func TestFileConvertsBetweenKindsOfColumns(t *testing.T) {
+	// given
+	f := position.NewFile("doc.ahm", []byte(src))
+	emoji := f.PositionOf(2, 8)
+	after := f.PositionOf(2, 9)
+
+	// when
+	utf16, display := f.UTF16Column(after), f.DisplayColumn(after, 4)
+
+	// then
+	slice := f.Slice(position.SpanFromTo(emoji, after))
+	assert.That(string(slice) == "🙂", t.Errorf, "got %q, wanted the emoji", slice)
+	assert.That(utf16 == 10, t.Errorf, "got UTF-16 column %d, wanted 10", utf16)
+	assert.That(display == 13, t.Errorf, "got display column %d, wanted 13", display)
+	assert.That(f.PositionOfUTF16(2, 10) == after, t.Errorf, "got %s from the UTF-16 column", f.PositionOfUTF16(2, 10))
+	assert.That(f.PositionOfDisplay(2, 13, 4) == after, t.Errorf, "got %s from the display column", f.PositionOfDisplay(2, 13, 4))
+}
+
+func TestFileDisplayColumnsOfWideCharacters(t *testing.T) {
+	// given
+	f := position.NewFile("doc.ahm", []byte(src))
+
+	// when
+	col := f.DisplayColumn(f.PositionOf(3, 4), 8)
+
+	// then
+	assert.That(col == 7, t.Errorf, "got display column %d, wanted 7", col)
+}
+
+func TestFileClampsPositionsPastTheEndOfALine(t *testing.T) {
+	// given
+	f := position.NewFile("doc.ahm", []byte(src))
+
+	// when
+	pos := f.PositionOf(1, 100)
+
+	// then
+	assert.That(pos.Line() == 1 && pos.Column() == 14, t.Errorf, "got %s, wanted 1,14", pos)
+	assert.That(f.Offset(4, 1) == len(src), t.Errorf, "got offset %d of the last line", f.Offset(4, 1))
+}
+
+func TestFileSetLocatesPosValues(t *testing.T) {
+	// given
+	set := position.NewFileSet()
+	first := set.AddFile("a.ahm", []byte("one\ntwo\n"))
+	second := set.AddFile("b.ahm", []byte("three\n"))
+
+	// when
+	pos, ok := set.Position(second.Pos(2))
+
+	// then
+	assert.That(ok && pos.Source == "b.ahm" && pos.Column() == 3, t.Errorf, "got %s", pos)
+	inFirst, ok := set.File(first.Pos(5))
+	assert.That(ok && inFirst == first, t.Errorf, "got file %v", inFirst)
+	_, ok = set.File(0)
+	assert.That(!ok, t.Errorf, "the zero Pos should be in no file")
+	found, ok := set.Lookup("b.ahm")
+	assert.That(ok && found == second, t.Errorf, "got file %v", found)
+}
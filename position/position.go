@@ -6,9 +6,7 @@ package position
 
 import (
 	"fmt"
-	"log"
-
-	"github.com/szabba/ahm/assert"
+	"unicode/utf8"
 )
 
 type PositionIn struct {
@@ -20,20 +18,41 @@ func (pos PositionIn) String() string {
 	return fmt.Sprintf("%s:%d,%d", pos.Source, pos.Line(), pos.Column())
 }
 
+// A Position is a place in a source.
+//
+// Lines and columns count from 1, with columns counted in runes.
+// The offset counts bytes from the start of the source.
 type Position struct {
-	line, column int
+	line, column, offset int
 }
 
+// PositionOf is a position with an unknown offset, treated as 0.
+//
+// Deprecated: Positions compare equal only when their offsets do, so the result is not equal
+// to the same place found in a source. Use File.PositionOf, or PositionAt when the offset is known.
 func PositionOf(line, column int) Position {
-	assert.That(line >= 1, log.Panicf, "line = %d < 1", line)
-	assert.That(column >= 1, log.Panicf, "column = %d < 1", column)
-	return Position{line - 1, column - 1}
+	return PositionAt(line, column, 0)
+}
+
+// PositionAt is a position with a known offset.
+//
+// Lines and columns below 1 are treated as 1, and negative offsets as 0.
+func PositionAt(line, column, offset int) Position {
+	return Position{atLeast(0, line-1), atLeast(0, column-1), atLeast(0, offset)}
+}
+
+func atLeast(min, n int) int {
+	if n < min {
+		return min
+	}
+	return n
 }
 
 func First() Position { return Position{} }
 
 func (pos Position) Line() int   { return pos.line + 1 }
 func (pos Position) Column() int { return pos.column + 1 }
+func (pos Position) Offset() int { return pos.offset }
 
 func (pos Position) String() string {
 	return fmt.Sprintf("%d,%d", pos.Line(), pos.Column())
@@ -48,16 +67,33 @@ func (pos Position) StartSpan() Span {
 }
 
 func (pos Position) NextAfter(r rune) Position {
+	return pos.Advance(r, utf8.RuneLen(r))
+}
+
+// Advance is the position after a rune that took size bytes in the source.
+// The size differs from the encoded length of the rune for invalid UTF-8, decoded as utf8.RuneError.
+func (pos Position) Advance(r rune, size int) Position {
 	if r == '\n' {
-		return pos.nextLine()
+		return Position{line: pos.line + 1, offset: pos.offset + size}
 	}
-	return pos.nextColumn()
+	return Position{line: pos.line, column: pos.column + 1, offset: pos.offset + size}
 }
 
-func (pos Position) nextLine() Position {
-	return Position{line: pos.line + 1}
+// Shift moves a position a number of lines and bytes, keeping its column.
+func (pos Position) Shift(lines, bytes int) Position {
+	return Position{line: pos.line + lines, column: pos.column, offset: pos.offset + bytes}
 }
 
-func (pos Position) nextColumn() Position {
-	return Position{line: pos.line, column: pos.column + 1}
+// Compare orders positions by line and then by column.
+// It is negative when pos comes before other, positive when after and zero when they are at the same place.
+func (pos Position) Compare(other Position) int {
+	switch {
+	case pos.line != other.line:
+		return pos.line - other.line
+	default:
+		return pos.column - other.column
+	}
 }
+
+func (pos Position) Before(other Position) bool { return pos.Compare(other) < 0 }
+func (pos Position) After(other Position) bool  { return pos.Compare(other) > 0 }
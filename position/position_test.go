@@ -8,6 +8,7 @@ import (
 	"testing"
 
 	"github.com/szabba/ahm/assert"
+	"github.com/szabba/ahm/position"
 )
 
 func TestZeroPosition(t *testing.T) {
@@ -52,3 +53,23 @@ func TestPositionAfterNewline(t *testing.T) {
 		t.Errorf,
 		"the position after a newline must be in the first column")
 }
+
+func TestPositionOffsetsCountBytes(t *testing.T) {
+	// given
+	var first Position
+
+	// when
+	pos := first.NextAfter('a').NextAfter('ł').NextAfter('\n').NextAfter('🙂')
+
+	// then
+	assert.That(pos.Offset() == 8, t.Errorf, "got offset %d, wanted 8", pos.Offset())
+	assert.That(pos.Line() == 2 && pos.Column() == 2, t.Errorf, "got position %s, wanted 2,2", pos)
+}
+
+func TestPositionOfClampsInsteadOfPanicking(t *testing.T) {
+	// when
+	pos := position.PositionOf(0, -3)
+
+	// then
+	assert.That(pos == position.First(), t.Errorf, "got %s, wanted the first position", pos)
+}
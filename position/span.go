@@ -4,12 +4,7 @@
 
 package position
 
-import (
-	"fmt"
-	"log"
-
-	"github.com/szabba/ahm/assert"
-)
+import "fmt"
 
 type SpanIn struct {
 	Source string
@@ -24,22 +19,24 @@ type Span struct {
 	start, end Position
 }
 
+// SpanFromTo is the span from one position up to another.
+//
+// When to comes before from, the span is empty and starts at from.
+// SpanBetween reports that instead.
 func SpanFromTo(from, to Position) Span {
-	assert.That(
-		from.Line() <= to.Line(),
-		log.Panicf,
-		"start position (%s) must not be on a line further than the end position (%s)",
-		from, to)
-
-	if from.Line() == to.Line() {
-		assert.That(
-			from.Column() <= to.Column(),
-			log.Panicf,
-			"start position (%s) must not be on a column further than the end position (%s) that is on the same line",
-			from, to)
+	span, ok := SpanBetween(from, to)
+	if !ok {
+		return from.StartSpan()
 	}
+	return span
+}
 
-	return Span{from, to}
+// SpanBetween is the span from one position up to another, if to does not come before from.
+func SpanBetween(from, to Position) (Span, bool) {
+	if to.Before(from) {
+		return Span{}, false
+	}
+	return Span{from, to}, true
 }
 
 func (span Span) StartsAt() Position { return span.start }
@@ -50,6 +47,11 @@ func (span Span) Add(r rune) Span {
 	return Span{start: span.start, end: span.end.NextAfter(r)}
 }
 
+// Advance extends a span over a rune that took size bytes in the source.
+func (span Span) Advance(r rune, size int) Span {
+	return Span{start: span.start, end: span.end.Advance(r, size)}
+}
+
 func (span Span) String() string {
 	return fmt.Sprintf("%s:%s", span.start, span.EndsBefore())
 }
@@ -57,3 +59,14 @@ func (span Span) String() string {
 func (span Span) In(source string) SpanIn {
 	return SpanIn{source, span}
 }
+
+// Shift moves a span a number of lines and bytes, keeping its columns.
+func (span Span) Shift(lines, bytes int) Span {
+	return Span{start: span.start.Shift(lines, bytes), end: span.end.Shift(lines, bytes)}
+}
+
+// Offsets are the byte offsets of the start and the end of a span.
+func (span Span) Offsets() (start, end int) { return span.start.offset, span.end.offset }
+
+// Len is the number of bytes in a span.
+func (span Span) Len() int { return span.end.offset - span.start.offset }
@@ -8,6 +8,7 @@ import (
 	"testing"
 
 	"github.com/szabba/ahm/assert"
+	"github.com/szabba/ahm/position"
 )
 
 func TestSpanFromPosition(t *testing.T) {
@@ -45,3 +46,16 @@ func TestSpanAdd(t *testing.T) {
 		t.Errorf,
 		"after adds, a span should end where one would get to by traversing the rune sequence")
 }
+
+func TestSpanBetweenPositionsInReverse(t *testing.T) {
+	// given
+	from, to := position.PositionOf(2, 1), position.PositionOf(1, 5)
+
+	// when
+	_, ok := position.SpanBetween(from, to)
+	span := position.SpanFromTo(from, to)
+
+	// then
+	assert.That(!ok, t.Errorf, "SpanBetween should refuse an end before the start")
+	assert.That(span == from.StartSpan(), t.Errorf, "got %s, wanted an empty span at the start", span)
+}
@@ -0,0 +1,221 @@
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package ahm
+
+import (
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/szabba/ahm/internal/lexer"
+	"github.com/szabba/ahm/position"
+)
+
+// A Tree is a parsed document, kept together with its text so that it can be reparsed after edits.
+type Tree struct {
+	Config Config
+	Text   string
+	Nodes  []Node
+	Err    error
+}
+
+func (cfg Config) ParseTree(text string) *Tree {
+	nodes, err := cfg.newStringParser(text).ParseAll()
+	return &Tree{Config: cfg, Text: text, Nodes: nodes, Err: err}
+}
+
+// An Edit replaces the text within a span.
+//
+// The span is located by its lines and columns, so its offsets need not be known.
+type Edit struct {
+	Span    position.Span
+	NewText string
+}
+
+// Reparse applies edits to the text of a tree, each one to the text left by those before it.
+//
+// Only the top level blocks an edit touches are parsed again.
+// The others are reused, with their spans moved when text was added or removed before them.
+// The result is the same as that of parsing the edited text from scratch.
+func (tree *Tree) Reparse(edits ...Edit) *Tree {
+	for _, edit := range edits {
+		tree = tree.reparse(edit)
+	}
+	return tree
+}
+
+func (tree *Tree) reparse(edit Edit) *Tree {
+	old := position.NewFile(tree.Config.Source, []byte(tree.Text))
+	start, end := edit.Span.StartsAt(), edit.Span.EndsBefore()
+	from, to := old.PositionOf(start.Line(), start.Column()), old.PositionOf(end.Line(), end.Column())
+	text := tree.Text[:from.Offset()] + edit.NewText + tree.Text[to.Offset():]
+
+	starts, ok := tree.blockStarts()
+	if !ok || tree.Config.Limits.wholeDocument() {
+		return tree.Config.ParseTree(text)
+	}
+
+	r := &reparser{
+		tree:   tree,
+		text:   text,
+		file:   position.NewFile(tree.Config.Source, []byte(text)),
+		starts: starts,
+		lines:  strings.Count(edit.NewText, "\n") - (to.Line() - from.Line()),
+		bytes:  len(edit.NewText) - (to.Offset() - from.Offset()),
+		first:  blockAt(starts, from.Line()),
+		end:    blockAt(starts, to.Line()) + 1,
+	}
+	return r.run()
+}
+
+// blockStarts lists the lines the top level nodes start at, with the first block starting at the first line.
+//
+// It fails when the tree cannot be reparsed in parts.
+func (tree *Tree) blockStarts() ([]int, bool) {
+	if tree.Err != nil || len(tree.Nodes) == 0 {
+		return nil, false
+	}
+	starts := make([]int, len(tree.Nodes))
+	starts[0] = 1
+	for i := 1; i < len(tree.Nodes); i++ {
+		start := SpanOf(tree.Nodes[i]).StartsAt()
+		if start.Column() != 1 || start.Line() <= starts[i-1] {
+			return nil, false
+		}
+		starts[i] = start.Line()
+	}
+	return starts, true
+}
+
+// blockAt finds the top level block containing a line.
+func blockAt(starts []int, line int) int {
+	i := len(starts) - 1
+	for i > 0 && starts[i] > line {
+		i--
+	}
+	return i
+}
+
+// A reparser reparses the blocks from first up to end, extending the range until the result fits with its neighbours.
+//
+// The edit moved the text after it a number of lines and bytes.
+type reparser struct {
+	tree         *Tree
+	text         string
+	file         *position.File
+	starts       []int
+	lines, bytes int
+	first, end   int
+}
+
+func (r *reparser) run() *Tree {
+	for {
+		if r.first > 0 && !r.startsBlock(r.starts[r.first]) {
+			r.first--
+			continue
+		}
+		if r.end < len(r.starts) && !r.startsBlock(r.starts[r.end]+r.lines) {
+			r.end++
+			continue
+		}
+
+		nodes, err := r.parseSegment()
+		if err != nil {
+			return r.tree.Config.ParseTree(r.text)
+		}
+
+		before, after := r.tree.Nodes[:r.first], r.tree.Nodes[r.end:]
+		switch {
+		case len(before) > 0 && isProse(before[len(before)-1]) && startsWithProse(nodes, after):
+			r.first--
+		case len(after) > 0 && isProse(after[0]) && endsWithProse(before, nodes):
+			r.end++
+		default:
+			joined := make([]Node, 0, len(before)+len(nodes)+len(after))
+			joined = append(joined, before...)
+			joined = append(joined, nodes...)
+			joined = append(joined, shiftNodes(after, r.lines, r.bytes)...)
+			return &Tree{Config: r.tree.Config, Text: r.text, Nodes: joined}
+		}
+	}
+}
+
+// startsBlock checks whether a line of the edited text can start a top level block on its own.
+func (r *reparser) startsBlock(line int) bool {
+	if line > r.file.LineCount() {
+		return false
+	}
+	first, _ := utf8.DecodeRuneInString(r.text[r.file.LineStart(line):])
+	return first != utf8.RuneError && !unicode.IsSpace(first)
+}
+
+func (r *reparser) parseSegment() ([]Node, error) {
+	start := r.file.PositionOf(r.starts[r.first], 1)
+	to := len(r.text)
+	if r.end < len(r.starts) {
+		to = r.file.LineStart(r.starts[r.end] + r.lines)
+	}
+
+	at := lexer.Checkpoint{Position: start}
+	return r.tree.Config.resumeParser(strings.NewReader(r.text[start.Offset():to]), at).ParseAll()
+}
+
+func startsWithProse(nodes, after []Node) bool {
+	if len(nodes) > 0 {
+		return isProse(nodes[0])
+	}
+	return len(after) > 0 && isProse(after[0])
+}
+
+func endsWithProse(before, nodes []Node) bool {
+	if len(nodes) > 0 {
+		return isProse(nodes[len(nodes)-1])
+	}
+	return len(before) > 0 && isProse(before[len(before)-1])
+}
+
+// shiftNodes copies nodes, moving their spans a number of lines and bytes.
+func shiftNodes(nodes []Node, lines, bytes int) []Node {
+	if (lines == 0 && bytes == 0) || nodes == nil {
+		return nodes
+	}
+	shifted := make([]Node, len(nodes))
+	for i, node := range nodes {
+		shifted[i] = shiftNode(node, lines, bytes)
+	}
+	return shifted
+}
+
+func shiftNode(node Node, lines, bytes int) Node {
+	switch node := node.(type) {
+	case *Proc:
+		proc := *node
+		proc.Span = shiftSpan(proc.Span, lines, bytes)
+		proc.Children = shiftNodes(proc.Children, lines, bytes)
+		if proc.Args != nil {
+			proc.Args = append([]Arg(nil), proc.Args...)
+			for i := range proc.Args {
+				proc.Args[i].Span = shiftSpan(proc.Args[i].Span, lines, bytes)
+			}
+		}
+		if proc.Attrs != nil {
+			proc.Attrs = append([]Attr(nil), proc.Attrs...)
+			for i := range proc.Attrs {
+				proc.Attrs[i].Span = shiftSpan(proc.Attrs[i].Span, lines, bytes)
+			}
+		}
+		return &proc
+	case *Text:
+		return &Text{Text: node.Text, Span: shiftSpan(node.Span, lines, bytes)}
+	case *Paragraph:
+		return &Paragraph{Children: shiftNodes(node.Children, lines, bytes), Span: shiftSpan(node.Span, lines, bytes)}
+	default:
+		return node
+	}
+}
+
+func shiftSpan(span Span, lines, bytes int) Span {
+	return span.Span.Shift(lines, bytes).In(span.Source)
+}
@@ -0,0 +1,195 @@
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package ahm_test
+
+import (
+	"math/rand"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/szabba/ahm"
+	"github.com/szabba/ahm/ahmtest"
+	"github.com/szabba/ahm/assert"
+	"github.com/szabba/ahm/position"
+)
+
+const reparsed = `@H1 Title
+
+Some text
+with an @EM{inline} proc.
+
+@LIST
+  @ITEM one
+  @ITEM two
+
+More text.
+`
+
+func TestReparseReusesTheBlocksBeforeAnEdit(t *testing.T) {
+	// given
+	cfg := ahm.Config{Source: "doc.ahm"}
+	tree := cfg.ParseTree(reparsed)
+
+	// when
+	edited := tree.Reparse(ahm.Edit{Span: span(7, 9, 7, 12), NewText: "first\n  @ITEM second"})
+
+	// then
+	expectSameAsFullParse(t, cfg, edited)
+	assert.That(edited.Nodes[0] == tree.Nodes[0], t.Errorf, "the title was parsed again")
+	assert.That(edited.Nodes[1] == tree.Nodes[1], t.Errorf, "the text was parsed again")
+	assert.That(edited.Nodes[2] != tree.Nodes[2], t.Errorf, "the list was not parsed again")
+}
+
+func TestReparseReusesTheBlocksAfterAnEditThatMovesNothing(t *testing.T) {
+	// given
+	cfg := ahm.Config{Source: "doc.ahm"}
+	tree := cfg.ParseTree(reparsed)
+
+	// when
+	edited := tree.Reparse(ahm.Edit{Span: span(1, 5, 1, 10), NewText: "Tales"})
+
+	// then
+	expectSameAsFullParse(t, cfg, edited)
+	assert.That(edited.Nodes[3] == tree.Nodes[3], t.Errorf, "the last text was parsed again")
+}
+
+func TestReparseMergesProseWithItsNeighbours(t *testing.T) {
+	// given
+	cfg := ahm.Config{Source: "doc.ahm"}
+	tree := cfg.ParseTree(reparsed)
+
+	// when
+	edited := tree.Reparse(ahm.Edit{Span: span(6, 1, 8, 15), NewText: "Was a list."})
+
+	// then
+	expectSameAsFullParse(t, cfg, edited)
+	assert.That(len(edited.Nodes) == 2, t.Errorf, "got %d nodes, wanted 2", len(edited.Nodes))
+}
+
+func TestReparseOfEditsInSequence(t *testing.T) {
+	// given
+	cfg := ahm.Config{Source: "doc.ahm", StructuredArgs: true}
+	tree := cfg.ParseTree(reparsed)
+
+	// when
+	edited := tree.Reparse(
+		ahm.Edit{Span: span(10, 1, 10, 1), NewText: "@NOTE kind=aside\n  "},
+		ahm.Edit{Span: span(2, 1, 2, 1), NewText: "\n\n"},
+		ahm.Edit{Span: span(12, 1, 12, 1), NewText: "  indented\n"},
+	)
+
+	// then
+	expectSameAsFullParse(t, cfg, edited)
+}
+
+func TestReparseMatchesAFullParseAfterAnySingleLineEdit(t *testing.T) {
+	paths, _ := filepath.Glob(filepath.Join("examples", "*.ahm"))
+	insertions := []string{"", "x", "@P", "@P title\n", "  ", "\n", "\n\n", "text @EM{x}\n", "  nested\n"}
+	for _, path := range paths {
+		t.Run(path, func(t *testing.T) {
+			src, err := os.ReadFile(path)
+			assert.That(err == nil, t.Fatalf, "cannot read example: %s", err)
+			cfg := ahm.Config{Source: path}
+			tree := cfg.ParseTree(string(src))
+			lines := strings.Count(string(src), "\n") + 1
+
+			for line := 1; line <= lines; line++ {
+				for _, ins := range insertions {
+					// given
+					deleteLine := ahm.Edit{Span: span(line, 1, line+1, 1), NewText: ins}
+					insert := ahm.Edit{Span: span(line, 1, line, 1), NewText: ins}
+
+					for _, edit := range []ahm.Edit{deleteLine, insert} {
+						// when
+						edited := tree.Reparse(edit)
+
+						// then
+						if !expectSameAsFullParse(t, cfg, edited) {
+							t.Fatalf("after replacing %s with %q", edit.Span, edit.NewText)
+						}
+					}
+				}
+			}
+		})
+	}
+}
+
+func expectSameAsFullParse(t *testing.T, cfg ahm.Config, tree *ahm.Tree) bool {
+	t.Helper()
+	full := cfg.ParseTree(tree.Text)
+	ok := true
+	onErr := func(msgFmt string, args ...interface{}) {
+		ok = false
+		t.Errorf(msgFmt, args...)
+	}
+	assert.That((tree.Err == nil) == (full.Err == nil), onErr, "got error %v, wanted %v", tree.Err, full.Err)
+	ahmtest.ReportExactDiffs(onErr, tree.Nodes, full.Nodes)
+	return ok
+}
+
+// span is a span for an edit, which does not need the offsets.
+func span(startLine, startCol, endLine, endCol int) position.Span {
+	return position.SpanFromTo(position.PositionAt(startLine, startCol, 0), position.PositionAt(endLine, endCol, 0))
+}
+
+func TestReparseNestsTheLinesAnEditLeavesUnderABlock(t *testing.T) {
+	cases := []struct {
+		text     string
+		from, to int
+		newText  string
+	}{
+		{"@B x\n\n    @C{y}\n\t\t@B x", 3, 14, "@B x"},
+		{"@A\n x\n\n\t", 1, 6, ""},
+	}
+
+	for _, c := range cases {
+		// given
+		cfg := ahm.Config{Source: "doc.ahm"}
+		tree := cfg.ParseTree(c.text)
+		file := position.NewFile("", []byte(c.text))
+		edit := ahm.Edit{Span: position.SpanFromTo(file.Position(c.from), file.Position(c.to)), NewText: c.newText}
+
+		// when
+		edited := tree.Reparse(edit)
+
+		// then
+		if !expectSameAsFullParse(t, cfg, edited) {
+			t.Errorf("after replacing [%d,%d) of %q with %q", c.from, c.to, c.text, c.newText)
+		}
+	}
+}
+
+func TestReparseMatchesAFullParseAfterRandomEdits(t *testing.T) {
+	pieces := []string{"@B x", "@C{y}", "x", " ", "  ", "    ", "\t", "\n", "\n\n", "@A\n", " x\n"}
+	rnd := rand.New(rand.NewSource(1))
+	random := func(n int) string {
+		var text strings.Builder
+		for i := 0; i < n; i++ {
+			text.WriteString(pieces[rnd.Intn(len(pieces))])
+		}
+		return text.String()
+	}
+
+	for i := 0; i < 5000; i++ {
+		// given
+		cfg := ahm.Config{Source: "doc.ahm"}
+		text := random(1 + rnd.Intn(12))
+		tree := cfg.ParseTree(text)
+		file := position.NewFile("", []byte(text))
+		from := rnd.Intn(len(text) + 1)
+		to := from + rnd.Intn(len(text)-from+1)
+		edit := ahm.Edit{Span: position.SpanFromTo(file.Position(from), file.Position(to)), NewText: random(rnd.Intn(3))}
+
+		// when
+		edited := tree.Reparse(edit)
+
+		// then
+		if !expectSameAsFullParse(t, cfg, edited) {
+			t.Fatalf("after replacing [%d,%d) of %q with %q", from, to, text, edit.NewText)
+		}
+	}
+}
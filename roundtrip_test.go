@@ -0,0 +1,81 @@
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package ahm_test
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/szabba/ahm"
+	"github.com/szabba/ahm/ahmtest"
+	"github.com/szabba/ahm/internal/seeds"
+)
+
+// FuzzRoundTrip checks the printer against the parser.
+//
+// Whatever the printer accepts must parse back into an equal tree, and print the same again.
+func FuzzRoundTrip(f *testing.F) {
+	examples, err := seeds.Examples("examples")
+	if err != nil {
+		f.Fatal(err)
+	}
+	cases, err := seeds.FromTests("parser_test.go")
+	if err != nil {
+		f.Fatal(err)
+	}
+	for _, input := range append(examples, cases...) {
+		f.Add(input)
+	}
+	gen := ahmtest.NewGenerator(1)
+	for i := 0; i < 20; i++ {
+		doc, err := gen.Document()
+		if err != nil {
+			f.Fatal(err)
+		}
+		f.Add(doc)
+	}
+
+	f.Fuzz(func(t *testing.T, input string) {
+		for _, cfg := range []ahm.Config{{}, {StructuredArgs: true}} {
+			checkRoundTrip(t, cfg, input)
+		}
+	})
+}
+
+func checkRoundTrip(t *testing.T, cfg ahm.Config, input string) {
+	nodes, err := cfg.NewParser(strings.NewReader(input)).ParseAll()
+	if err != nil {
+		return
+	}
+	printed, err := ahm.FormatString(nodes)
+	if err != nil {
+		if cfg.StructuredArgs {
+			// Structured args must not keep what prints without them from printing.
+			_, plainErr := ahm.FormatString(ahmtest.Parse(t, ahm.Config{}, input))
+			if plainErr == nil {
+				t.Fatalf("cannot print %q parsed with structured args: %s", input, err)
+			}
+		}
+		return
+	}
+
+	reparsed, err := cfg.NewParser(strings.NewReader(printed)).ParseAll()
+	if err != nil {
+		t.Fatalf("cannot parse %q, printed from %q: %s", printed, input, err)
+	}
+	if len(reparsed) != len(nodes) {
+		t.Fatalf("got %d nodes from %q, printed from %q with %d", len(reparsed), printed, input, len(nodes))
+	}
+	for i := range nodes {
+		if !ahm.Equals(reparsed[i], nodes[i]) {
+			t.Fatalf("node %d of %q changed when printed as %q", i, input, printed)
+		}
+	}
+
+	again, err := ahm.FormatString(reparsed)
+	if err != nil || again != printed {
+		t.Fatalf("printing %q again gave %q, %v", printed, again, err)
+	}
+}
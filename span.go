@@ -0,0 +1,76 @@
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package ahm
+
+import "github.com/szabba/ahm/position"
+
+// SpanOf returns the span covered by a node, or the zero span for nil.
+//
+// The span of a proc covers its children too.
+func SpanOf(node Node) Span {
+	switch node := node.(type) {
+	case *Proc:
+		return node.Span
+	case *Text:
+		return node.Span
+	case *Paragraph:
+		return node.Span
+	default:
+		return Span{}
+	}
+}
+
+// PathTo lists the nodes whose spans contain a position, from the outermost to the innermost one.
+func PathTo(nodes []Node, pos position.Position) []Node {
+	var path []Node
+	for {
+		node, ok := childAt(nodes, pos)
+		if !ok {
+			return path
+		}
+		path = append(path, node)
+		nodes = childrenOf(node)
+	}
+}
+
+// NodeAt finds the innermost node whose span contains a position.
+func NodeAt(nodes []Node, pos position.Position) (Node, bool) {
+	path := PathTo(nodes, pos)
+	if len(path) == 0 {
+		return nil, false
+	}
+	return path[len(path)-1], true
+}
+
+// ProcAt finds the innermost proc whose span contains a position.
+func ProcAt(nodes []Node, pos position.Position) (*Proc, bool) {
+	path := PathTo(nodes, pos)
+	for i := len(path) - 1; i >= 0; i-- {
+		if proc, ok := path[i].(*Proc); ok {
+			return proc, true
+		}
+	}
+	return nil, false
+}
+
+func childAt(nodes []Node, pos position.Position) (Node, bool) {
+	for _, node := range nodes {
+		if node != nil && SpanOf(node).Contains(pos) {
+			return node, true
+		}
+	}
+	return nil, false
+}
+
+func childrenOf(node Node) []Node {
+	switch node := node.(type) {
+	case *Proc:
+		return node.Children
+	case *Paragraph:
+		return node.Children
+	default:
+		return nil
+	}
+}
@@ -0,0 +1,62 @@
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package ahm
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/szabba/ahm/assert"
+	"github.com/szabba/ahm/position"
+)
+
+const nested = `@LIST
+  @ITEM first
+    with @EM{emphasis}
+  @ITEM second
+`
+
+var nestedFile = position.NewFile("", []byte(nested))
+
+func TestPathToAnInlineProc(t *testing.T) {
+	// given
+	nodes, err := NewParser(strings.NewReader(nested)).ParseAll()
+	assert.That(err == nil, t.Fatalf, "unexpected error: %s", err)
+
+	// when
+	path := PathTo(nodes, nestedFile.PositionOf(3, 14))
+
+	// then
+	assert.That(len(path) == 4, t.Fatalf, "got path %#v, wanted 4 nodes", path)
+	assert.That(path[1].(*Proc).Title == "first", t.Errorf, "got %#v, wanted the first item", path[1])
+	assert.That(path[3].(*Proc).Name == "EM", t.Errorf, "got %#v, wanted the inline proc", path[3])
+}
+
+func TestNodeAtPrefersTheInnermostNode(t *testing.T) {
+	// given
+	nodes, err := NewParser(strings.NewReader(nested)).ParseAll()
+	assert.That(err == nil, t.Fatalf, "unexpected error: %s", err)
+
+	// when
+	node, ok := NodeAt(nodes, nestedFile.PositionOf(3, 6))
+	proc, procOK := ProcAt(nodes, nestedFile.PositionOf(3, 6))
+
+	// then
+	text, isText := node.(*Text)
+	assert.That(ok && isText && text.Text == "with ", t.Errorf, "got %#v, wanted the text", node)
+	assert.That(procOK && proc.Title == "first", t.Errorf, "got proc %#v, wanted the first item", proc)
+}
+
+func TestNodeAtOutsideAllNodes(t *testing.T) {
+	// given
+	nodes, err := NewParser(strings.NewReader(nested)).ParseAll()
+	assert.That(err == nil, t.Fatalf, "unexpected error: %s", err)
+
+	// when
+	_, ok := NodeAt(nodes, nestedFile.PositionOf(5, 1))
+
+	// then
+	assert.That(!ok, t.Errorf, "there should be no node after the end of the document")
+}
@@ -0,0 +1,167 @@
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package story
+
+import (
+	"sort"
+
+	"github.com/szabba/ahm/ahmerr"
+)
+
+// Analyze looks for mistakes in an episode that would only show up while playing it:
+//
+//   - cards that cannot be reached from the first one,
+//   - GOTOs to cards that do not exist,
+//   - values that are used without being declared,
+//   - values that are declared, but never read or changed.
+//
+// The findings are in the order of the document.
+// Only references to missing cards and undeclared values are errors, the rest are warnings.
+func Analyze(ep *Episode) ahmerr.Diagnostics {
+	a := &analysis{episode: ep, used: map[string]bool{}}
+	for _, card := range ep.Cards {
+		for _, option := range card.Options {
+			a.checkEffects(option.Effects)
+		}
+	}
+	a.checkReachable()
+	a.checkUnused()
+	a.sort()
+	return a.diags
+}
+
+// Graph maps the name of each card to the names of the cards that can follow it.
+func Graph(ep *Episode) map[string][]string {
+	graph := map[string][]string{}
+	for i, card := range ep.Cards {
+		next := ""
+		if i+1 < len(ep.Cards) {
+			next = ep.Cards[i+1].Name
+		}
+		graph[card.Name] = successors(card, next)
+	}
+	return graph
+}
+
+// successors lists the cards that can follow a card, given the one after it.
+func successors(card *Card, next string) []string {
+	var cards []string
+	seen := map[string]bool{}
+	add := func(name string) {
+		if name != "" && !seen[name] {
+			seen[name] = true
+			cards = append(cards, name)
+		}
+	}
+
+	if len(card.Options) == 0 {
+		add(next)
+	}
+	for _, option := range card.Options {
+		if !alwaysJumps(option.Effects) {
+			add(next)
+		}
+		walkEffects(option.Effects, func(effect Effect) {
+			switch effect := effect.(type) {
+			case *Goto:
+				add(effect.Card)
+			case *Next:
+				add(next)
+			}
+		})
+	}
+	return cards
+}
+
+// alwaysJumps checks whether effects pick the next card whatever the values are.
+func alwaysJumps(effects []Effect) bool {
+	for _, effect := range effects {
+		switch effect.(type) {
+		case *Goto, *Next:
+			return true
+		}
+	}
+	return false
+}
+
+func walkEffects(effects []Effect, visit func(Effect)) {
+	for _, effect := range effects {
+		visit(effect)
+		if cond, ok := effect.(*If); ok {
+			walkEffects(cond.Then, visit)
+		}
+	}
+}
+
+type analysis struct {
+	episode *Episode
+	used    map[string]bool
+	diags   ahmerr.Diagnostics
+}
+
+func (a *analysis) checkEffects(effects []Effect) {
+	walkEffects(effects, func(effect Effect) {
+		switch effect := effect.(type) {
+		case *Set:
+			a.useValue(effect, effect.Value)
+		case *Add:
+			a.useValue(effect, effect.Value)
+		case *If:
+			a.useValue(effect, effect.Condition.Value)
+		case *Goto:
+			if _, ok := a.episode.Card(effect.Card); !ok {
+				a.diags = append(a.diags, ahmerr.Diagnosef(effect.Proc.Span, "there is no card named %s", effect.Card))
+			}
+		}
+	})
+}
+
+func (a *analysis) useValue(effect Effect, name string) {
+	a.used[name] = true
+	if _, ok := a.episode.Value(name); !ok {
+		a.diags = append(a.diags, ahmerr.Diagnosef(effect.EffectProc().Span, "value %s is not declared", name))
+	}
+}
+
+func (a *analysis) checkReachable() {
+	if len(a.episode.Cards) == 0 {
+		return
+	}
+	graph := Graph(a.episode)
+	reached := map[string]bool{}
+	queue := []string{a.episode.Cards[0].Name}
+	reached[queue[0]] = true
+	for len(queue) > 0 {
+		card := queue[0]
+		queue = queue[1:]
+		for _, next := range graph[card] {
+			if !reached[next] {
+				reached[next] = true
+				queue = append(queue, next)
+			}
+		}
+	}
+
+	for _, card := range a.episode.Cards {
+		if !reached[card.Name] {
+			a.diags = append(a.diags, ahmerr.Warnf(card.Proc.Span, "card %s cannot be reached", card.Name))
+		}
+	}
+}
+
+func (a *analysis) checkUnused() {
+	for _, value := range a.episode.Values {
+		if !a.used[value.Name] {
+			a.diags = append(a.diags, ahmerr.Warnf(value.Proc.Span, "value %s is never read or changed", value.Name))
+		}
+	}
+}
+
+// sort orders the findings by where they start.
+func (a *analysis) sort() {
+	sort.SliceStable(a.diags, func(i, j int) bool {
+		return a.diags[i].Span.StartsAt().Before(a.diags[j].Span.StartsAt())
+	})
+}
@@ -0,0 +1,88 @@
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package story_test
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/szabba/ahm/ahmerr"
+	"github.com/szabba/ahm/assert"
+	"github.com/szabba/ahm/story"
+)
+
+func TestAnalyzeFindsNothingWrongWithAConsistentEpisode(t *testing.T) {
+	// given
+	ep := load(t, episode)
+
+	// when
+	diags := story.Analyze(ep)
+
+	// then
+	assert.That(len(diags) == 0, t.Errorf, "got findings:\n%s", diags)
+}
+
+func TestAnalyzeReportsProblemsWithSpans(t *testing.T) {
+	// given
+	ep := load(t, strings.Join([]string{
+		"@EPISODE Broken",
+		"  @CONDITIONS",
+		"    @VALUE unused 1",
+		"    @VALUE hope 1",
+		"  @CARD start",
+		"    @CHOICE",
+		"      @OPTION Jump",
+		"        @EFFECT",
+		"          @IF hope > 0",
+		"            @GOTO nowhere",
+		"          @ADD despair 1",
+		"          @GOTO start",
+		"  @CARD orphan",
+		"    Nobody comes here."}, "\n"))
+
+	// when
+	diags := story.Analyze(ep)
+
+	// then
+	want := []finding{
+		{3, ahmerr.Warning, "value unused is never read or changed"},
+		{10, ahmerr.Error, "there is no card named nowhere"},
+		{11, ahmerr.Error, "value despair is not declared"},
+		{13, ahmerr.Warning, "card orphan cannot be reached"},
+	}
+	got := findings(diags)
+	assert.That(reflect.DeepEqual(got, want), t.Errorf, "got %v, wanted %v", got, want)
+}
+
+func TestGraphFollowsConditionalJumpsAndFallsThrough(t *testing.T) {
+	// given
+	ep := load(t, episode)
+
+	// when
+	graph := story.Graph(ep)
+
+	// then
+	want := map[string][]string{
+		"start":    {"training", "vision"},
+		"training": {"vision"},
+		"vision":   {"start"},
+	}
+	assert.That(reflect.DeepEqual(graph, want), t.Errorf, "got %v, wanted %v", graph, want)
+}
+
+type finding struct {
+	line     int
+	severity ahmerr.Severity
+	message  string
+}
+
+func findings(diags ahmerr.Diagnostics) []finding {
+	var out []finding
+	for _, diag := range diags {
+		out = append(out, finding{diag.Span.StartsAt().Line(), diag.Severity, diag.Message})
+	}
+	return out
+}
@@ -0,0 +1,405 @@
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+// Package story runs interactive fiction episodes:
+//
+//	@EPISODE A New Hope
+//	  @CONDITIONS
+//	    @VALUE Force.disturbance 10
+//	  @CARD title-screen
+//	    A long time ago, in a Galaxy not so far from here.
+//	    @CHOICE
+//	      @OPTION "Sense a disturbance in the Force"
+//	        @EFFECT
+//	          @ADD Force.disturbance 10
+//	          @IF Force.disturbance >= 20
+//	            @GOTO vader
+//	      @OPTION "Ignore it"
+//
+// A game shows a card and its options.
+// Choosing an option applies its effects, in order.
+// Effects can change the named values declared in the CONDITIONS, and pick the next card:
+//
+//	@SET name value
+//	@ADD name delta
+//	@GOTO card
+//	@NEXT
+//	@IF name op number
+//
+// An IF applies the effects nested in it when the condition holds.
+// The condition compares a value to a number with one of == != < <= > >=.
+// Unless an effect says otherwise, the next card is the one after the current one,
+// and a game ends when there is none.
+// Text in an EFFECT is a note for the writers, and is ignored.
+package story
+
+import (
+	"bytes"
+	"io"
+	"strconv"
+	"strings"
+
+	"github.com/szabba/ahm"
+	"github.com/szabba/ahm/ahmerr"
+)
+
+const (
+	EpisodeProc    = "EPISODE"
+	ConditionsProc = "CONDITIONS"
+	ValueProc      = "VALUE"
+	CardProc       = "CARD"
+	ChoiceProc     = "CHOICE"
+	OptionProc     = "OPTION"
+	EffectProc     = "EFFECT"
+
+	SetProc  = "SET"
+	AddProc  = "ADD"
+	GotoProc = "GOTO"
+	NextProc = "NEXT"
+	IfProc   = "IF"
+)
+
+type Episode struct {
+	Title  string
+	Values []*Value
+	Cards  []*Card
+	Proc   *ahm.Proc
+}
+
+// A Value is a named number that effects can change.
+type Value struct {
+	Name    string
+	Initial int
+	Proc    *ahm.Proc
+}
+
+type Card struct {
+	Name string
+	// Text is the text of the card, with the inline procs replaced by their arguments.
+	Text    string
+	Options []*Option
+	Proc    *ahm.Proc
+}
+
+type Option struct {
+	Text    string
+	Effects []Effect
+	Proc    *ahm.Proc
+}
+
+// An Effect is one of Set, Add, Goto, Next and If.
+type Effect interface {
+	EffectProc() *ahm.Proc
+}
+
+type Set struct {
+	Value string
+	To    int
+	Proc  *ahm.Proc
+}
+
+type Add struct {
+	Value string
+	By    int
+	Proc  *ahm.Proc
+}
+
+type Goto struct {
+	Card string
+	Proc *ahm.Proc
+}
+
+type Next struct {
+	Proc *ahm.Proc
+}
+
+type If struct {
+	Condition Condition
+	Then      []Effect
+	Proc      *ahm.Proc
+}
+
+func (effect *Set) EffectProc() *ahm.Proc  { return effect.Proc }
+func (effect *Add) EffectProc() *ahm.Proc  { return effect.Proc }
+func (effect *Goto) EffectProc() *ahm.Proc { return effect.Proc }
+func (effect *Next) EffectProc() *ahm.Proc { return effect.Proc }
+func (effect *If) EffectProc() *ahm.Proc   { return effect.Proc }
+
+// A Condition compares a value to a number.
+type Condition struct {
+	Value string
+	Op    string
+	Than  int
+}
+
+func (cond Condition) holds(value int) bool {
+	switch cond.Op {
+	case "==":
+		return value == cond.Than
+	case "!=":
+		return value != cond.Than
+	case "<":
+		return value < cond.Than
+	case "<=":
+		return value <= cond.Than
+	case ">":
+		return value > cond.Than
+	case ">=":
+		return value >= cond.Than
+	default:
+		return false
+	}
+}
+
+// Card finds a card by name.
+func (ep *Episode) Card(name string) (*Card, bool) {
+	for _, card := range ep.Cards {
+		if card.Name == name {
+			return card, true
+		}
+	}
+	return nil, false
+}
+
+// Value finds a value declaration by name.
+func (ep *Episode) Value(name string) (*Value, bool) {
+	for _, value := range ep.Values {
+		if value.Name == name {
+			return value, true
+		}
+	}
+	return nil, false
+}
+
+// Load reads the first episode in a document.
+// The source names the input in diagnostics.
+func Load(r io.Reader, source string) (*Episode, error) {
+	src, err := io.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+	nodes, err := ahm.Config{Source: source, StructuredArgs: true}.NewParser(bytes.NewReader(src)).ParseAll()
+	if err != nil {
+		return nil, err
+	}
+	return FromNodes(nodes)
+}
+
+// FromNodes reads the first episode among parsed nodes.
+// The nodes must have been parsed with structured arguments.
+func FromNodes(nodes []ahm.Node) (*Episode, error) {
+	for _, node := range nodes {
+		if proc, ok := node.(*ahm.Proc); ok && proc.Name == EpisodeProc {
+			l := new(loader)
+			ep := l.episode(proc)
+			return ep, l.errs.Err()
+		}
+	}
+	return nil, errNoEpisode
+}
+
+// A loader collects the problems found while reading an episode.
+type loader struct {
+	errs ahmerr.Diagnostics
+}
+
+func (l *loader) errorf(proc *ahm.Proc, msgFmt string, args ...interface{}) {
+	l.errs = append(l.errs, ahmerr.Diagnosef(proc.Span, msgFmt, args...))
+}
+
+func (l *loader) episode(proc *ahm.Proc) *Episode {
+	ep := &Episode{Title: proc.Title, Proc: proc}
+	for _, child := range procsIn(proc.Children) {
+		switch child.Name {
+		case ConditionsProc:
+			ep.Values = append(ep.Values, l.values(child)...)
+		case CardProc:
+			card := l.card(child)
+			if _, dup := ep.Card(card.Name); dup {
+				l.errorf(child, "there already is a card named %s", card.Name)
+			}
+			ep.Cards = append(ep.Cards, card)
+		default:
+			l.errorf(child, "unexpected %s in an %s", child.Name, EpisodeProc)
+		}
+	}
+	return ep
+}
+
+func (l *loader) values(proc *ahm.Proc) []*Value {
+	var values []*Value
+	for _, child := range procsIn(proc.Children) {
+		if child.Name != ValueProc {
+			l.errorf(child, "unexpected %s in %s", child.Name, ConditionsProc)
+			continue
+		}
+		if len(child.Args) != 2 {
+			l.errorf(child, "a %s needs a name and an initial value", ValueProc)
+			continue
+		}
+		initial, ok := l.number(child, child.Arg(1))
+		if ok {
+			values = append(values, &Value{Name: child.Arg(0), Initial: initial, Proc: child})
+		}
+	}
+	return values
+}
+
+func (l *loader) card(proc *ahm.Proc) *Card {
+	card := &Card{Name: strings.TrimSpace(proc.Title), Proc: proc}
+	if card.Name == "" {
+		l.errorf(proc, "a %s needs a name", CardProc)
+	}
+
+	var text []string
+	for _, child := range proc.Children {
+		choice, ok := child.(*ahm.Proc)
+		if !ok {
+			text = append(text, plainText(child))
+			continue
+		}
+		if choice.Name != ChoiceProc {
+			l.errorf(choice, "unexpected %s in a %s", choice.Name, CardProc)
+			continue
+		}
+		for _, option := range procsIn(choice.Children) {
+			card.Options = append(card.Options, l.option(option))
+		}
+	}
+	card.Text = strings.TrimSpace(strings.Join(text, "\n\n"))
+	return card
+}
+
+func (l *loader) option(proc *ahm.Proc) *Option {
+	option := &Option{Text: proc.Title, Proc: proc}
+	if len(proc.Args) == 1 {
+		option.Text = proc.Arg(0)
+	}
+	if proc.Name != OptionProc {
+		l.errorf(proc, "unexpected %s in a %s", proc.Name, ChoiceProc)
+		return option
+	}
+	for _, child := range procsIn(proc.Children) {
+		if child.Name != EffectProc {
+			l.errorf(child, "unexpected %s in an %s", child.Name, OptionProc)
+			continue
+		}
+		option.Effects = append(option.Effects, l.effects(child.Children)...)
+	}
+	return option
+}
+
+func (l *loader) effects(nodes []ahm.Node) []Effect {
+	var effects []Effect
+	for _, proc := range procsIn(nodes) {
+		effect := l.effect(proc)
+		if effect != nil {
+			effects = append(effects, effect)
+		}
+	}
+	return effects
+}
+
+func (l *loader) effect(proc *ahm.Proc) Effect {
+	switch proc.Name {
+	case SetProc, AddProc:
+		if len(proc.Args) != 2 {
+			l.errorf(proc, "%s needs a value name and a number", proc.Name)
+			return nil
+		}
+		n, ok := l.number(proc, proc.Arg(1))
+		if !ok {
+			return nil
+		}
+		if proc.Name == SetProc {
+			return &Set{Value: proc.Arg(0), To: n, Proc: proc}
+		}
+		return &Add{Value: proc.Arg(0), By: n, Proc: proc}
+
+	case GotoProc:
+		if len(proc.Args) != 1 {
+			l.errorf(proc, "%s needs a card name", GotoProc)
+			return nil
+		}
+		return &Goto{Card: proc.Arg(0), Proc: proc}
+
+	case NextProc:
+		return &Next{Proc: proc}
+
+	case IfProc:
+		cond, ok := l.condition(proc)
+		if !ok {
+			return nil
+		}
+		return &If{Condition: cond, Then: l.effects(proc.Children), Proc: proc}
+
+	default:
+		l.errorf(proc, "unknown effect %s", proc.Name)
+		return nil
+	}
+}
+
+func (l *loader) condition(proc *ahm.Proc) (Condition, bool) {
+	fields := strings.Fields(proc.Title)
+	if len(fields) != 3 {
+		l.errorf(proc, "an %s needs a condition like: name >= 10", IfProc)
+		return Condition{}, false
+	}
+	cond := Condition{Value: fields[0], Op: fields[1]}
+	if !cond.knownOp() {
+		l.errorf(proc, "unknown comparison %s", cond.Op)
+		return Condition{}, false
+	}
+	var ok bool
+	cond.Than, ok = l.number(proc, fields[2])
+	return cond, ok
+}
+
+func (cond Condition) knownOp() bool {
+	switch cond.Op {
+	case "==", "!=", "<", "<=", ">", ">=":
+		return true
+	default:
+		return false
+	}
+}
+
+func (l *loader) number(proc *ahm.Proc, s string) (int, bool) {
+	n, err := strconv.Atoi(s)
+	if err != nil {
+		l.errorf(proc, "%q is not a whole number", s)
+		return 0, false
+	}
+	return n, true
+}
+
+func procsIn(nodes []ahm.Node) []*ahm.Proc {
+	var procs []*ahm.Proc
+	for _, node := range nodes {
+		if proc, ok := node.(*ahm.Proc); ok {
+			procs = append(procs, proc)
+		}
+	}
+	return procs
+}
+
+func plainText(node ahm.Node) string {
+	switch node := node.(type) {
+	case *ahm.Text:
+		return node.Text
+	case *ahm.Paragraph:
+		var text strings.Builder
+		for _, child := range node.Children {
+			switch child := child.(type) {
+			case *ahm.Text:
+				text.WriteString(child.Text)
+			case *ahm.Proc:
+				text.WriteString(child.Title)
+			}
+		}
+		return text.String()
+	default:
+		return ""
+	}
+}
@@ -0,0 +1,174 @@
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package story
+
+import (
+	"encoding/json"
+	"io"
+
+	"github.com/pkg/errors"
+
+	"github.com/szabba/ahm/ahmerr"
+)
+
+var errNoEpisode = errors.New("no EPISODE in the document")
+
+// A State is everything about a game in progress that can change.
+type State struct {
+	// Card is the name of the current card, or empty when the game has ended.
+	Card   string         `json:"card"`
+	Values map[string]int `json:"values"`
+}
+
+// A Game is an episode being played.
+type Game struct {
+	episode *Episode
+	state   State
+}
+
+// NewGame starts an episode from its first card, with all values at their initial settings.
+func NewGame(ep *Episode) *Game {
+	game := &Game{episode: ep}
+	game.state.Values = map[string]int{}
+	for _, value := range ep.Values {
+		game.state.Values[value.Name] = value.Initial
+	}
+	if len(ep.Cards) > 0 {
+		game.state.Card = ep.Cards[0].Name
+	}
+	return game
+}
+
+func (game *Game) Episode() *Episode { return game.episode }
+
+// Card returns the current card.
+func (game *Game) Card() (*Card, bool) {
+	if game.Ended() {
+		return nil, false
+	}
+	return game.episode.Card(game.state.Card)
+}
+
+func (game *Game) Ended() bool { return game.state.Card == "" }
+
+// Value returns the current setting of a value.
+func (game *Game) Value(name string) int { return game.state.Values[name] }
+
+// State returns a copy of the state of the game.
+func (game *Game) State() State {
+	state := State{Card: game.state.Card, Values: map[string]int{}}
+	for name, value := range game.state.Values {
+		state.Values[name] = value
+	}
+	return state
+}
+
+// Restore continues the game from a state.
+// The card in the state must exist in the episode.
+func (game *Game) Restore(state State) error {
+	if state.Card != "" {
+		if _, ok := game.episode.Card(state.Card); !ok {
+			return errors.Errorf("the saved card %q is not in the episode", state.Card)
+		}
+	}
+	restored := NewGame(game.episode).State()
+	restored.Card = state.Card
+	for name, value := range state.Values {
+		restored.Values[name] = value
+	}
+	game.state = restored
+	return nil
+}
+
+// Save writes the state of the game.
+func (game *Game) Save(w io.Writer) error {
+	return json.NewEncoder(w).Encode(game.State())
+}
+
+// Load continues the game from a state written by Save.
+func (game *Game) Load(r io.Reader) error {
+	var state State
+	err := json.NewDecoder(r).Decode(&state)
+	if err != nil {
+		return errors.Wrap(err, "cannot read the saved state")
+	}
+	return game.Restore(state)
+}
+
+// Choose picks an option of the current card, applies its effects and moves on to the next card.
+// A card without options has to be chosen with Continue.
+func (game *Game) Choose(i int) error {
+	card, ok := game.Card()
+	if !ok {
+		return errors.New("the game has ended")
+	}
+	if i < 0 || i >= len(card.Options) {
+		return errors.Errorf("card %s has no option %d", card.Name, i+1)
+	}
+	return game.moveOn(card, card.Options[i].Effects)
+}
+
+// Continue moves on from a card without options.
+func (game *Game) Continue() error {
+	card, ok := game.Card()
+	if !ok {
+		return errors.New("the game has ended")
+	}
+	if len(card.Options) > 0 {
+		return errors.Errorf("card %s needs an option to be chosen", card.Name)
+	}
+	return game.moveOn(card, nil)
+}
+
+func (game *Game) moveOn(card *Card, effects []Effect) error {
+	next := game.cardAfter(card)
+	// The effects change a copy, so that a failing one leaves the game as it was.
+	values := game.State().Values
+	err := game.apply(effects, values, &next)
+	if err != nil {
+		return err
+	}
+	game.state.Values = values
+	game.state.Card = next
+	return nil
+}
+
+// apply applies effects to values, keeping track of what the next card should be.
+func (game *Game) apply(effects []Effect, values map[string]int, next *string) error {
+	for _, effect := range effects {
+		switch effect := effect.(type) {
+		case *Set:
+			values[effect.Value] = effect.To
+		case *Add:
+			values[effect.Value] += effect.By
+		case *Goto:
+			if _, ok := game.episode.Card(effect.Card); !ok {
+				return ahmerr.Diagnosef(effect.Proc.Span, "there is no card named %s", effect.Card)
+			}
+			*next = effect.Card
+		case *Next:
+			card, _ := game.Card()
+			*next = game.cardAfter(card)
+		case *If:
+			if !effect.Condition.holds(values[effect.Condition.Value]) {
+				continue
+			}
+			err := game.apply(effect.Then, values, next)
+			if err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func (game *Game) cardAfter(card *Card) string {
+	for i, other := range game.episode.Cards {
+		if other == card && i+1 < len(game.episode.Cards) {
+			return game.episode.Cards[i+1].Name
+		}
+	}
+	return ""
+}
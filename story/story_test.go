@@ -0,0 +1,183 @@
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package story_test
+
+import (
+	"bytes"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/szabba/ahm/ahmerr"
+	"github.com/szabba/ahm/assert"
+	"github.com/szabba/ahm/story"
+)
+
+const episode = `@EPISODE Test
+  @CONDITIONS
+    @VALUE force 10
+    @VALUE courage 0
+  @CARD start
+    It begins.
+    @CHOICE
+      @OPTION "Meditate"
+        @EFFECT
+          @ADD force 15
+          @IF force >= 20
+            @GOTO vision
+      @OPTION "Train"
+        @EFFECT
+          @SET courage 5
+  @CARD training
+    You train.
+  @CARD vision
+    You see @EM{things}.
+    @CHOICE
+      @OPTION "Go back"
+        @EFFECT
+          @GOTO start
+`
+
+func TestLoadReadsTheEpisode(t *testing.T) {
+	// when
+	ep := load(t, episode)
+
+	// then
+	assert.That(ep.Title == "Test", t.Errorf, "got title %q", ep.Title)
+	assert.That(len(ep.Values) == 2, t.Errorf, "got %d values, wanted 2", len(ep.Values))
+	assert.That(len(ep.Cards) == 3, t.Fatalf, "got %d cards, wanted 3", len(ep.Cards))
+	assert.That(ep.Cards[2].Text == "You see things.", t.Errorf, "got text %q", ep.Cards[2].Text)
+	assert.That(ep.Cards[0].Options[0].Text == "Meditate", t.Errorf, "got option %q", ep.Cards[0].Options[0].Text)
+}
+
+func TestEffectsPickTheNextCard(t *testing.T) {
+	// given
+	game := story.NewGame(load(t, episode))
+
+	// when
+	err := game.Choose(0)
+
+	// then
+	assert.That(err == nil, t.Fatalf, "unexpected error: %s", err)
+	card, _ := game.Card()
+	assert.That(card.Name == "vision", t.Errorf, "got card %s, wanted vision", card.Name)
+	assert.That(game.Value("force") == 25, t.Errorf, "got force %d, wanted 25", game.Value("force"))
+}
+
+func TestWithoutAGotoTheNextCardFollows(t *testing.T) {
+	// given
+	game := story.NewGame(load(t, episode))
+
+	// when
+	err := game.Choose(1)
+
+	// then
+	assert.That(err == nil, t.Fatalf, "unexpected error: %s", err)
+	card, _ := game.Card()
+	assert.That(card.Name == "training", t.Errorf, "got card %s, wanted training", card.Name)
+	assert.That(game.Value("courage") == 5, t.Errorf, "got courage %d, wanted 5", game.Value("courage"))
+}
+
+func TestAFailedChoiceLeavesTheGameAsItWas(t *testing.T) {
+	// given
+	game := story.NewGame(load(t, strings.Join([]string{
+		"@EPISODE Broken",
+		"  @CONDITIONS",
+		"    @VALUE courage 0",
+		"  @CARD first",
+		"    Hi.",
+		"    @CHOICE",
+		"      @OPTION \"Leap\"",
+		"        @EFFECT",
+		"          @SET courage 5",
+		"          @GOTO nowhere"}, "\n")))
+
+	// when
+	err := game.Choose(0)
+
+	// then
+	assert.That(err != nil, t.Fatalf, "expected an error")
+	card, _ := game.Card()
+	assert.That(card.Name == "first", t.Errorf, "got card %s, wanted first", card.Name)
+	assert.That(game.Value("courage") == 0, t.Errorf, "got courage %d, wanted 0", game.Value("courage"))
+}
+
+func TestTheGameEndsAfterTheLastCard(t *testing.T) {
+	// given
+	game := story.NewGame(load(t, strings.Join([]string{
+		"@EPISODE Short",
+		"  @CARD first",
+		"    Hi.",
+		"  @CARD last",
+		"    Bye."}, "\n")))
+	game.Continue()
+
+	// when
+	err := game.Continue()
+
+	// then
+	assert.That(err == nil, t.Fatalf, "unexpected error: %s", err)
+	assert.That(game.Ended(), t.Errorf, "the game should have ended")
+}
+
+func TestSaveAndLoad(t *testing.T) {
+	// given
+	ep := load(t, episode)
+	game := story.NewGame(ep)
+	game.Choose(0)
+	var saved bytes.Buffer
+	err := game.Save(&saved)
+	assert.That(err == nil, t.Fatalf, "cannot save: %s", err)
+
+	restored := story.NewGame(ep)
+
+	// when
+	err = restored.Load(&saved)
+
+	// then
+	assert.That(err == nil, t.Fatalf, "cannot load: %s", err)
+	card, _ := restored.Card()
+	assert.That(card.Name == "vision", t.Errorf, "got card %s, wanted vision", card.Name)
+	assert.That(restored.Value("force") == 25, t.Errorf, "got force %d, wanted 25", restored.Value("force"))
+}
+
+func TestUnknownEffectsAreReported(t *testing.T) {
+	// given
+	src := strings.Join([]string{
+		"@EPISODE Test",
+		"  @CARD start",
+		"    @CHOICE",
+		"      @OPTION Go",
+		"        @EFFECT",
+		"          @TELEPORT somewhere"}, "\n")
+
+	// when
+	_, err := story.Load(strings.NewReader(src), "test.ahm")
+
+	// then
+	diags, _ := err.(ahmerr.Diagnostics)
+	assert.That(len(diags) == 1, t.Fatalf, "got %v, wanted 1 diagnostic", err)
+	assert.That(diags[0].Span.StartsAt().Line() == 6, t.Errorf, "got diagnostic at %v, wanted line 6", diags[0].Span)
+}
+
+func TestTheExampleLoads(t *testing.T) {
+	// given
+	f, err := os.Open("../examples/cards.ahm")
+	assert.That(err == nil, t.Fatalf, "cannot open the example: %s", err)
+	defer f.Close()
+
+	// when
+	ep, err := story.Load(f, "cards.ahm")
+
+	// then
+	assert.That(err == nil, t.Fatalf, "unexpected error: %s", err)
+	assert.That(len(ep.Cards) == 1 && len(ep.Cards[0].Options) == 2, t.Errorf, "got %d cards", len(ep.Cards))
+}
+
+func load(t *testing.T, src string) *story.Episode {
+	ep, err := story.Load(strings.NewReader(src), "test.ahm")
+	assert.That(err == nil, t.Fatalf, "cannot load the episode: %s", err)
+	return ep
+}
@@ -20,5 +20,5 @@ func (builder *textBuilder) Write(p []byte) (int, error) {
 func (builder *textBuilder) Build() *Text {
 	content := builder.buf.String()
 	content = strings.TrimSuffix(content, "\n")
-	return &Text{content}
+	return &Text{Text: content}
 }
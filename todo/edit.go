@@ -0,0 +1,56 @@
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package todo
+
+import (
+	"sort"
+	"unicode/utf8"
+
+	"github.com/szabba/ahm"
+	"github.com/szabba/ahm/position"
+)
+
+// An edit replaces a number of runes at a position in the source.
+type edit struct {
+	at    position.Position
+	runes int
+	text  string
+}
+
+// renameProc changes the name after the proc mark.
+func renameProc(proc *ahm.Proc, name string) edit {
+	at := proc.Span.StartsAt().NextAfter('@')
+	return edit{at: at, runes: utf8.RuneCountInString(proc.Name), text: name}
+}
+
+func applyEdits(src []byte, edits []edit) []byte {
+	type located struct {
+		from, to int
+		text     string
+	}
+	var byOffset []located
+	for _, e := range edits {
+		from := e.at.Offset()
+		to := from
+		for i := 0; i < e.runes && to < len(src); i++ {
+			_, size := utf8.DecodeRune(src[to:])
+			to += size
+		}
+		byOffset = append(byOffset, located{from, to, e.text})
+	}
+	sort.SliceStable(byOffset, func(i, j int) bool { return byOffset[i].from < byOffset[j].from })
+
+	var out []byte
+	last := 0
+	for _, e := range byOffset {
+		if e.from < last {
+			continue
+		}
+		out = append(out, src[last:e.from]...)
+		out = append(out, e.text...)
+		last = e.to
+	}
+	return append(out, src[last:]...)
+}
@@ -0,0 +1,44 @@
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package todo
+
+import (
+	"strings"
+	"time"
+)
+
+// A Filter selects items.
+type Filter func(item *Item) bool
+
+// And matches the items that match all the filters.
+func And(filters ...Filter) Filter {
+	return func(item *Item) bool {
+		for _, f := range filters {
+			if !f(item) {
+				return false
+			}
+		}
+		return true
+	}
+}
+
+func WithStatus(status Status) Filter {
+	return func(item *Item) bool { return item.Status == status }
+}
+
+func WithTag(tag string) Filter {
+	return func(item *Item) bool { return item.HasTag(tag) }
+}
+
+// DueBy matches the items due on the day or earlier.
+func DueBy(day time.Time) Filter {
+	return func(item *Item) bool { return !item.Due.IsZero() && !item.Due.After(day) }
+}
+
+// Containing matches the items with text containing the substring, ignoring case.
+func Containing(substr string) Filter {
+	substr = strings.ToLower(substr)
+	return func(item *Item) bool { return strings.Contains(strings.ToLower(item.Text), substr) }
+}
@@ -0,0 +1,230 @@
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+// Package todo works with TODO lists:
+//
+//	@DONE Buy milk #shopping
+//	@TODO Bake cake due=2020-01-31
+//	  @TODO Get recipe
+//
+// Items nested in an item are its subtasks.
+// In an item's title, words starting with # are tags, and due=YYYY-MM-DD sets the due date.
+// Other nodes are left alone.
+package todo
+
+import (
+	"bytes"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/szabba/ahm"
+	"github.com/szabba/ahm/ahmerr"
+)
+
+const (
+	TodoProc = "TODO"
+	DoneProc = "DONE"
+
+	// DateLayout is the layout of due dates.
+	DateLayout = "2006-01-02"
+
+	duePrefix = "due="
+	tagPrefix = "#"
+)
+
+type Status int
+
+const (
+	Open Status = iota
+	Done
+)
+
+func (status Status) String() string {
+	if status == Done {
+		return "done"
+	}
+	return "open"
+}
+
+// An Item is a single task.
+type Item struct {
+	// ID is the position of the item in the list, like 2 or 2.1 for the first subtask of the second item.
+	ID     string
+	Status Status
+	// Text is the title, without the tags and the due date.
+	Text string
+	// Due is the zero time when the item has no due date.
+	Due      time.Time
+	Tags     []string
+	Subtasks []*Item
+	Parent   *Item
+	Proc     *ahm.Proc
+}
+
+func (item *Item) HasTag(tag string) bool {
+	tag = strings.TrimPrefix(tag, tagPrefix)
+	for _, have := range item.Tags {
+		if have == tag {
+			return true
+		}
+	}
+	return false
+}
+
+// A List is a loaded TODO list, together with its source.
+type List struct {
+	Items []*Item
+
+	source string
+	src    []byte
+	edits  []edit
+}
+
+// Load reads a TODO list.
+// The source names the input in diagnostics.
+func Load(r io.Reader, source string) (*List, error) {
+	src, err := io.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+	nodes, err := ahm.Config{Source: source}.NewParser(bytes.NewReader(src)).ParseAll()
+	if err != nil {
+		return nil, err
+	}
+
+	list := &List{source: source, src: src}
+	list.Items, err = itemsOf(nodes, nil)
+	return list, err
+}
+
+func itemsOf(nodes []ahm.Node, parent *Item) ([]*Item, error) {
+	var items []*Item
+	var errs ahmerr.Diagnostics
+	for _, node := range nodes {
+		proc, ok := node.(*ahm.Proc)
+		if !ok || proc.Name != TodoProc && proc.Name != DoneProc {
+			continue
+		}
+
+		item := &Item{Parent: parent, Proc: proc, ID: childID(parent, len(items)+1)}
+		if proc.Name == DoneProc {
+			item.Status = Done
+		}
+		err := item.parseTitle()
+		if diag, ok := err.(*ahmerr.Diagnostic); ok {
+			errs = append(errs, diag)
+		}
+
+		item.Subtasks, err = itemsOf(proc.Children, item)
+		if diags, ok := err.(ahmerr.Diagnostics); ok {
+			errs = append(errs, diags...)
+		}
+		items = append(items, item)
+	}
+	return items, errs.Err()
+}
+
+func childID(parent *Item, n int) string {
+	id := strconv.Itoa(n)
+	if parent != nil {
+		id = parent.ID + "." + id
+	}
+	return id
+}
+
+func (item *Item) parseTitle() error {
+	var text []string
+	for _, word := range strings.Fields(item.Proc.Title) {
+		switch {
+		case strings.HasPrefix(word, tagPrefix) && len(word) > len(tagPrefix):
+			item.Tags = append(item.Tags, strings.TrimPrefix(word, tagPrefix))
+
+		case strings.HasPrefix(word, duePrefix):
+			due, err := time.Parse(DateLayout, strings.TrimPrefix(word, duePrefix))
+			if err != nil {
+				return ahmerr.Diagnosef(item.Proc.Span, "bad due date %q, wanted one like %s", word, DateLayout)
+			}
+			item.Due = due
+
+		default:
+			text = append(text, word)
+		}
+	}
+	item.Text = strings.Join(text, " ")
+	return nil
+}
+
+// All lists all the items, with each item followed by its subtasks.
+func (list *List) All() []*Item {
+	var all []*Item
+	var walk func(items []*Item)
+	walk = func(items []*Item) {
+		for _, item := range items {
+			all = append(all, item)
+			walk(item.Subtasks)
+		}
+	}
+	walk(list.Items)
+	return all
+}
+
+// Find finds an item by its ID.
+func (list *List) Find(id string) (*Item, bool) {
+	for _, item := range list.All() {
+		if item.ID == id {
+			return item, true
+		}
+	}
+	return nil, false
+}
+
+// Filter lists the items that match all the filters, in the order of All.
+func (list *List) Filter(filters ...Filter) []*Item {
+	var matching []*Item
+	for _, item := range list.All() {
+		if And(filters...)(item) {
+			matching = append(matching, item)
+		}
+	}
+	return matching
+}
+
+// Complete marks an item as done.
+func (list *List) Complete(item *Item) { list.setStatus(item, Done, DoneProc) }
+
+// Reopen marks an item as not done.
+func (list *List) Reopen(item *Item) { list.setStatus(item, Open, TodoProc) }
+
+func (list *List) setStatus(item *Item, status Status, name string) {
+	if item.Status == status {
+		return
+	}
+	item.Status = status
+	list.addEdit(renameProc(item.Proc, name))
+	item.Proc.Name = name
+}
+
+// addEdit adds an edit, replacing an earlier one at the same position.
+func (list *List) addEdit(e edit) {
+	for i, earlier := range list.edits {
+		if earlier.at == e.at {
+			list.edits[i].text = e.text
+			return
+		}
+	}
+	list.edits = append(list.edits, e)
+}
+
+// Bytes returns the source of the list with the changes applied.
+// Everything except for the changed procs stays as it was.
+func (list *List) Bytes() []byte {
+	return applyEdits(list.src, list.edits)
+}
+
+func (list *List) WriteTo(w io.Writer) (int64, error) {
+	n, err := w.Write(list.Bytes())
+	return int64(n), err
+}
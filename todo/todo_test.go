@@ -0,0 +1,116 @@
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package todo_test
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/szabba/ahm/ahmerr"
+	"github.com/szabba/ahm/assert"
+	"github.com/szabba/ahm/todo"
+)
+
+const example = `@DONE Buy milk #shopping
+@TODO Bake cake due=2020-01-31 #home
+
+  Make it a chocolate one.
+  @TODO Get recipe
+  @TODO   Buy  flour #shopping
+`
+
+func TestLoadReadsItemsWithSubtasks(t *testing.T) {
+	// given
+	r := strings.NewReader(example)
+
+	// when
+	list, err := todo.Load(r, "todos.ahm")
+
+	// then
+	assert.That(err == nil, t.Fatalf, "unexpected error: %s", err)
+	all := list.All()
+	assert.That(len(all) == 4, t.Fatalf, "got %d items, wanted 4", len(all))
+
+	cake := all[1]
+	assert.That(cake.ID == "2", t.Errorf, "got ID %q, wanted 2", cake.ID)
+	assert.That(cake.Text == "Bake cake", t.Errorf, "got text %q", cake.Text)
+	assert.That(cake.Due.Equal(date(2020, 1, 31)), t.Errorf, "got due date %v", cake.Due)
+	assert.That(cake.HasTag("home"), t.Errorf, "got tags %v, wanted home", cake.Tags)
+	assert.That(len(cake.Subtasks) == 2, t.Fatalf, "got %d subtasks, wanted 2", len(cake.Subtasks))
+	assert.That(cake.Subtasks[1].ID == "2.2", t.Errorf, "got ID %q, wanted 2.2", cake.Subtasks[1].ID)
+	assert.That(cake.Subtasks[1].Parent == cake, t.Errorf, "the subtask has the wrong parent")
+}
+
+func TestFilter(t *testing.T) {
+	// given
+	list, err := todo.Load(strings.NewReader(example), "todos.ahm")
+	assert.That(err == nil, t.Fatalf, "unexpected error: %s", err)
+
+	// when
+	shopping := list.Filter(todo.WithStatus(todo.Open), todo.WithTag("#shopping"))
+	due := list.Filter(todo.DueBy(date(2020, 2, 1)))
+
+	// then
+	assert.That(len(shopping) == 1 && shopping[0].Text == "Buy flour", t.Errorf, "got %v, wanted just the flour", texts(shopping))
+	assert.That(len(due) == 1 && due[0].Text == "Bake cake", t.Errorf, "got %v, wanted just the cake", texts(due))
+}
+
+func TestCompleteAndReopenOnlyTouchTheProcNames(t *testing.T) {
+	// given
+	list, err := todo.Load(strings.NewReader(example), "todos.ahm")
+	assert.That(err == nil, t.Fatalf, "unexpected error: %s", err)
+	milk, _ := list.Find("1")
+	flour, _ := list.Find("2.2")
+
+	// when
+	list.Reopen(milk)
+	list.Complete(flour)
+
+	// then
+	want := strings.NewReplacer("@DONE Buy milk", "@TODO Buy milk", "@TODO   Buy  flour", "@DONE   Buy  flour").Replace(example)
+	got := string(list.Bytes())
+	assert.That(got == want, t.Errorf, "got\n%s\nwanted\n%s", got, want)
+}
+
+func TestToggledItemsAreWrittenWithTheirLastStatus(t *testing.T) {
+	// given
+	list, err := todo.Load(strings.NewReader(example), "todos.ahm")
+	assert.That(err == nil, t.Fatalf, "unexpected error: %s", err)
+	recipe, _ := list.Find("2.1")
+
+	// when
+	list.Complete(recipe)
+	list.Reopen(recipe)
+
+	// then
+	got := string(list.Bytes())
+	assert.That(got == example, t.Errorf, "got\n%s\nwanted\n%s", got, example)
+}
+
+func TestBadDueDatesAreReported(t *testing.T) {
+	// given
+	r := strings.NewReader("@TODO Something\n@TODO Other due=tomorrow")
+
+	// when
+	_, err := todo.Load(r, "todos.ahm")
+
+	// then
+	diags, _ := err.(ahmerr.Diagnostics)
+	assert.That(len(diags) == 1, t.Fatalf, "got %v, wanted 1 diagnostic", err)
+	assert.That(diags[0].Span.StartsAt().Line() == 2, t.Errorf, "got diagnostic at %v, wanted line 2", diags[0].Span)
+}
+
+func date(year int, month time.Month, day int) time.Time {
+	return time.Date(year, month, day, 0, 0, 0, 0, time.UTC)
+}
+
+func texts(items []*todo.Item) []string {
+	var out []string
+	for _, item := range items {
+		out = append(out, item.Text)
+	}
+	return out
+}
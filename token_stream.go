@@ -5,36 +5,54 @@
 package ahm
 
 import (
-	"log"
+	"io"
 
-	"github.com/szabba/ahm/assert"
+	"github.com/szabba/ahm/ahmerr"
 	"github.com/szabba/ahm/internal/lexer"
 	"github.com/szabba/ahm/internal/token"
+	"github.com/szabba/ahm/position"
 )
 
 type tokenStream struct {
+	source string
+	limits Limits
 	lexer  *lexer.Lexer
 	tokens []token.Token
 	err    error
+	// read and depth are the number of tokens read so far and the nesting depth after the last one.
+	read, depth int
 }
 
-func newStream(lexer *lexer.Lexer) *tokenStream {
+func newStream(source string, limits Limits, lexer *lexer.Lexer) *tokenStream {
 	stream := new(tokenStream)
+	stream.source = source
+	stream.limits = limits
 	stream.lexer = lexer
 	return stream
 }
 
-func (stream *tokenStream) accept(d int) {
-	assert.That(d > 0, log.Panicf, "cannot accept %d tokens because %d <= 0", d, d)
-	assert.That(
-		d <= len(stream.tokens), log.Panicf,
-		"cannot accept %d tokens when there are %d in the lookahead buffer",
-		d, len(stream.tokens))
+// accept drops d tokens from the lookahead buffer.
+//
+// Only tokens that were peeked at can be accepted.
+// Asking for others is a parser bug, reported as an internal error at the next token.
+func (stream *tokenStream) accept(d int) error {
+	if d <= 0 || d > len(stream.tokens) {
+		return ahmerr.Internalf(stream.nextSpan(), "cannot accept %d tokens with %d peeked at", d, len(stream.tokens))
+	}
 
 	rest := stream.tokens[d:]
 
 	copy(stream.tokens, rest)
 	stream.tokens = stream.tokens[:len(rest)]
+	return nil
+}
+
+// nextSpan is the span of the next token, or an empty span when none was peeked at.
+func (stream *tokenStream) nextSpan() position.SpanIn {
+	if len(stream.tokens) == 0 {
+		return position.SpanIn{Source: stream.source}
+	}
+	return stream.tokens[0].Span.In(stream.source)
 }
 
 func (stream *tokenStream) peek(d int) (token.Token, error) {
@@ -49,7 +67,7 @@ func (stream *tokenStream) readForPeek(d int) {
 	if d < len(stream.tokens) {
 		return
 	}
-	extraNeeded := len(stream.tokens) + 1 - d
+	extraNeeded := d + 1 - len(stream.tokens)
 	stream.readMore(extraNeeded)
 }
 
@@ -65,8 +83,49 @@ func (stream *tokenStream) readOne() {
 	}
 	var tok token.Token
 	tok, stream.err = stream.lexer.Next()
+	stream.locateError(tok)
 	if tok.TokenType == token.Invalid {
 		return
 	}
 	stream.tokens = append(stream.tokens, tok)
+	stream.checkLimits(tok)
+}
+
+// checkLimits stops the stream when a token takes it over the limits on the number of tokens or on nesting.
+func (stream *tokenStream) checkLimits(tok token.Token) {
+	stream.read++
+	switch tok.TokenType {
+	case token.Indent:
+		stream.depth++
+	case token.Dedent:
+		stream.depth--
+	}
+
+	limit, max := ahmerr.Limit(0), 0
+	switch {
+	case stream.limits.MaxTokens > 0 && stream.read > stream.limits.MaxTokens:
+		limit, max = ahmerr.TokenCount, stream.limits.MaxTokens
+	case stream.limits.MaxDepth > 0 && stream.depth > stream.limits.MaxDepth:
+		limit, max = ahmerr.NestingDepth, stream.limits.MaxDepth
+	default:
+		return
+	}
+	stream.tokens = stream.tokens[:len(stream.tokens)-1]
+	stream.err = &ahmerr.LimitError{Limit: limit, Max: max, Span: tok.Span.In(stream.source)}
+}
+
+// locateError turns a lexer error into a diagnostic at the position where lexing failed.
+func (stream *tokenStream) locateError(tok token.Token) {
+	if stream.err == nil || stream.err == io.EOF {
+		return
+	}
+	switch err := stream.err.(type) {
+	case *ahmerr.Diagnostic, *ahmerr.LimitError:
+		return
+	case *ahmerr.InternalError:
+		err.Span.Source = stream.source
+		return
+	}
+	at := tok.Span.EndsBefore().StartSpan().In(stream.source)
+	stream.err = ahmerr.Diagnosef(at, "%s", stream.err)
 }